@@ -26,6 +26,12 @@ func main() {
 	)
 	defer cancel()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		code := runMigrate(ctx, cfg, os.Args[2:])
+		cancel()
+		os.Exit(code)
+	}
+
 	srv := server.NewServer(ctx, cfg)
 
 	srv.Run()
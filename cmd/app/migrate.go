@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"log_shelter/internal/config"
+	"log_shelter/internal/infra"
+)
+
+const migrateUsage = `usage: app migrate <command>
+
+commands:
+  up [version]   apply pending migrations, up to version if given
+  down [steps]   revert the last steps migrations, 1 by default
+  status         list migrations and when they were applied`
+
+// runMigrate handles the "migrate" command and returns the exit code.
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	var n int64
+	if len(args) == 2 {
+		var err error
+		n, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil || n < 0 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+	}
+
+	pg, err := infra.NewPostgresInfra(ctx, &cfg.Postgres)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer pg.Close()
+	m, db, err := pg.OpenMigrator()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	var result any
+	switch args[0] {
+	case "up":
+		result, err = m.Up(ctx, n)
+	case "down":
+		if len(args) == 1 {
+			n = 1
+		}
+		result, err = m.Down(ctx, int(n))
+	case "status":
+		result, err = m.Status(ctx)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(result)
+	return 0
+}
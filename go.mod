@@ -7,6 +7,7 @@ require github.com/lmittmann/tint v1.1.2
 require (
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/nats-io/nats.go v1.47.0
 	github.com/pelletier/go-toml/v2 v2.2.4
 )
@@ -11,6 +11,22 @@ type LoggerConfig struct {
 	DeveloperMode bool `toml:"developer_mode"`
 }
 
+// PoolConfig limits a connection pool. Zero values keep the database/sql
+// defaults.
+type PoolConfig struct {
+	MaxOpenConns    int      `toml:"max_open_conns"`
+	MaxIdleConns    int      `toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `toml:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `toml:"conn_max_idle_time"`
+}
+
+// ReplicaConfig is a read replica of the primary. Credentials and the
+// database name are shared with the primary.
+type ReplicaConfig struct {
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+}
+
 type PostgresConfig struct {
 	Host     string `toml:"host"`
 	Port     int    `toml:"port"`
@@ -18,17 +34,39 @@ type PostgresConfig struct {
 	Password string `toml:"password"`
 	Database string `toml:"database"`
 	Driver   string `toml:"driver"`
+
+	MigrateOnStartup bool `toml:"migrate_on_startup"`
+
+	Pool PoolConfig `toml:"pool"`
+	// ConnectRetries and ConnectBackoff bound the startup ping of the
+	// primary, the backoff doubles after every failed attempt.
+	ConnectRetries int      `toml:"connect_retries"`
+	ConnectBackoff Duration `toml:"connect_backoff"`
+
+	// Replicas serve read-only requests, falling back to the primary
+	// while none of them is reachable.
+	Replicas    []ReplicaConfig `toml:"replicas"`
+	ReplicaPool PoolConfig      `toml:"replica_pool"`
 }
 
 func (p *PostgresConfig) Dsn() string {
+	return p.dsn(p.Host, p.Port)
+}
+
+func (p *PostgresConfig) ReplicaDsn(r ReplicaConfig) string {
+	return p.dsn(r.Host, r.Port)
+}
+
+func (p *PostgresConfig) dsn(host string, port int) string {
 	url := "host=%v port=%v dbname=%v user=%v password=%v sslmode=disable"
-	return fmt.Sprintf(url, p.Host, p.Port, p.Database, p.Username, p.Password)
+	return fmt.Sprintf(url, host, port, p.Database, p.Username, p.Password)
 }
 
 type TelegramConfig struct {
 	Enabled      bool     `toml:"enabled"`
 	APIKey       string   `toml:"api_key"`
 	Levels       []string `toml:"levels"`
+	MinLevel     string   `toml:"min_level"`
 	NotificateTo []int64  `toml:"notificate_to"`
 }
 
@@ -38,10 +76,137 @@ type NatsConfig struct {
 	Password string `toml:"password"`
 }
 
+// RetentionRuleConfig expires the logs matching Sources, LoggerNames and
+// the level range once they are older than MaxAge, or once their source
+// holds more than MaxRows rows or MaxBytes bytes. Empty matchers match
+// everything, sources and logger names accept "*" patterns.
+type RetentionRuleConfig struct {
+	Name        string   `toml:"name"`
+	Priority    int      `toml:"priority"`
+	Sources     []string `toml:"sources"`
+	LoggerNames []string `toml:"logger_names"`
+	MinLevel    string   `toml:"min_level"`
+	MaxLevel    string   `toml:"max_level"`
+	MaxAge      Duration `toml:"max_age"`
+	MaxRows     uint64   `toml:"max_rows"`
+	MaxBytes    uint64   `toml:"max_bytes"`
+	// Delete is "soft" (the default) or "hard".
+	Delete string `toml:"delete"`
+}
+
 type LogConfig struct {
-	RetencionPolicy string   `toml:"retencion_policy"`
-	DeleteAfter     Duration `toml:"delete_after"`
-	CycleTime       Duration `toml:"cycle_time"`
+	RetencionPolicy string                `toml:"retencion_policy"`
+	DeleteAfter     Duration              `toml:"delete_after"`
+	CycleTime       Duration              `toml:"cycle_time"`
+	Rules           []RetentionRuleConfig `toml:"rules"`
+	PurgeAfter      Duration              `toml:"purge_after"`
+	PurgeCycleTime  Duration              `toml:"purge_cycle_time"`
+	PurgeBatchSize  uint64                `toml:"purge_batch_size"`
+	// RestoreRetention keeps restored logs from retention rules and
+	// partition drops, 30 days when unset.
+	RestoreRetention Duration `toml:"restore_retention"`
+}
+
+type FacetsConfig struct {
+	CacheTTL Duration `toml:"cache_ttl"`
+}
+
+type ExportConfig struct {
+	Bucket    string `toml:"bucket"`
+	BatchSize uint64 `toml:"batch_size"`
+}
+
+type QueryConfig struct {
+	StatementTimeout Duration `toml:"statement_timeout"`
+	MaxTimeRange     Duration `toml:"max_time_range"`
+	MaxPageSize      uint64   `toml:"max_page_size"`
+	MaxCost          float64  `toml:"max_cost"`
+}
+
+type PartitionsConfig struct {
+	Enabled   bool     `toml:"enabled"`
+	Interval  string   `toml:"interval"`
+	Premake   int      `toml:"premake"`
+	Retention Duration `toml:"retention"`
+	CycleTime Duration `toml:"cycle_time"`
+}
+
+type ArchiveS3Config struct {
+	Endpoint  string `toml:"endpoint"`
+	Region    string `toml:"region"`
+	Bucket    string `toml:"bucket"`
+	AccessKey string `toml:"access_key"`
+	SecretKey string `toml:"secret_key"`
+}
+
+// ArchiveConfig makes retention and purge archive logs before deleting
+// them. Format is "ndjson" or "parquet", Store is "dir" or "s3".
+type ArchiveConfig struct {
+	Enabled   bool            `toml:"enabled"`
+	Format    string          `toml:"format"`
+	Store     string          `toml:"store"`
+	Dir       string          `toml:"dir"`
+	S3        ArchiveS3Config `toml:"s3"`
+	BatchSize uint64          `toml:"batch_size"`
+}
+
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+)
+
+type SQLiteConfig struct {
+	Path        string   `toml:"path"`
+	BusyTimeout Duration `toml:"busy_timeout"`
+}
+
+// StorageConfig selects where logs are kept. Backend is "postgres" (the
+// default) or "sqlite", a single file for edge and local deployments that
+// supports appending, searching, timelines and retention only.
+type StorageConfig struct {
+	Backend string       `toml:"backend"`
+	SQLite  SQLiteConfig `toml:"sqlite"`
+}
+
+func (s *StorageConfig) IsSQLite() bool {
+	return s.Backend == StorageSQLite
+}
+
+// IntegrityConfig links the logs of Sources, "*" patterns included, into
+// per-source hash chains. With SigningKeyFile set, a base64 Ed25519 seed
+// or private key, the chain heads are signed every CheckpointInterval.
+type IntegrityConfig struct {
+	Sources            []string `toml:"sources"`
+	SigningKeyFile     string   `toml:"signing_key_file"`
+	CheckpointInterval Duration `toml:"checkpoint_interval"`
+}
+
+// EncryptionConfig encrypts raw_log and attributes of the logs of Sources,
+// "*" patterns included, with per-source data keys wrapped by the master
+// keys read from MasterKeyFile or from the MasterKeyEnv variable. Every
+// RotationCycleTime data keys are rewrapped by the current master key,
+// retired once older than DataKeyMaxAge and their logs re-encrypted.
+// Reads only decrypt logs for requests carrying one of DecryptTokens.
+type EncryptionConfig struct {
+	Sources           []string `toml:"sources"`
+	MasterKeyFile     string   `toml:"master_key_file"`
+	MasterKeyEnv      string   `toml:"master_key_env"`
+	DecryptTokens     []string `toml:"decrypt_tokens"`
+	DataKeyMaxAge     Duration `toml:"data_key_max_age"`
+	RotationCycleTime Duration `toml:"rotation_cycle_time"`
+	RotationBatchSize uint64   `toml:"rotation_batch_size"`
+}
+
+func (e *EncryptionConfig) HasMasterKey() bool {
+	return e.MasterKeyFile != "" || e.MasterKeyEnv != ""
+}
+
+// RollupsConfig downsamples the per-minute rollups to hours once older than
+// HourlyAfter and to days once older than DailyAfter, every CycleTime.
+type RollupsConfig struct {
+	HourlyAfter Duration `toml:"hourly_after"`
+	DailyAfter  Duration `toml:"daily_after"`
+	CycleTime   Duration `toml:"cycle_time"`
 }
 
 type Config struct {
@@ -50,6 +215,16 @@ type Config struct {
 	Nats     NatsConfig     `toml:"nats"`
 	Telegram TelegramConfig `toml:"telegram"`
 	Logs     LogConfig
+	Facets   FacetsConfig `toml:"facets"`
+	Export   ExportConfig `toml:"export"`
+	Query    QueryConfig  `toml:"query"`
+
+	Partitions PartitionsConfig `toml:"partitions"`
+	Archive    ArchiveConfig    `toml:"archive"`
+	Storage    StorageConfig    `toml:"storage"`
+	Integrity  IntegrityConfig  `toml:"integrity"`
+	Encryption EncryptionConfig `toml:"encryption"`
+	Rollups    RollupsConfig    `toml:"rollups"`
 }
 
 func readConfigFile(filename string) []byte {
@@ -2,23 +2,66 @@ package factory
 
 import (
 	"context"
-	"log_shelter/internal/infra"
+	"database/sql"
+
+	"log_shelter/internal/config"
+	"log_shelter/internal/infra/envelope"
+	"log_shelter/internal/infra/integrity"
+	"log_shelter/internal/model"
 )
 
+// Database hands out transactions of the configured storage backend.
+// Read transactions may run on a replica. Connections outside of any
+// transaction are for statements that can't run in one.
+type Database interface {
+	GetTranscation() (*sql.Tx, error)
+	GetReadTranscation() (*sql.Tx, error)
+	GetConnection() (*sql.Conn, error)
+	Health() model.Health
+}
+
 type Factory struct {
-	postgres_infra *infra.PostgresInfra
+	db  Database
+	cfg *config.Config
+	// keys is nil without a master key.
+	keys *envelope.Keyring
+	// signer is nil without a checkpoint signing key.
+	signer *integrity.Signer
 }
 
-func NewFactory(postgres_infra *infra.PostgresInfra) *Factory {
+func NewFactory(
+	db Database,
+	cfg *config.Config,
+	keys *envelope.Keyring,
+	signer *integrity.Signer,
+) *Factory {
 	return &Factory{
-		postgres_infra: postgres_infra,
+		db:     db,
+		cfg:    cfg,
+		keys:   keys,
+		signer: signer,
 	}
 }
 
 func (f *Factory) GetUsecaseFactory(ctx context.Context) (*UsecaseFactory, error) {
-	conn, tx, err := f.postgres_infra.GetTranscation()
+	tx, err := f.db.GetTranscation()
+	if err != nil {
+		return nil, err
+	}
+	ret := NewUsecaseFactory(ctx, f.cfg, tx, f.keys, f.signer)
+	ret.db = f.db
+	ret.fresh = func() (*UsecaseFactory, error) {
+		return f.GetUsecaseFactory(ctx)
+	}
+	return ret, nil
+}
+
+// GetReadUsecaseFactory is GetUsecaseFactory for read-only usecases, which
+// may see a replica lagging behind the primary.
+func (f *Factory) GetReadUsecaseFactory(ctx context.Context) (*UsecaseFactory, error) {
+	tx, err := f.db.GetReadTranscation()
 	if err != nil {
 		return nil, err
 	}
-	return NewUsecaseFactory(ctx, conn, tx), nil
+	return NewUsecaseFactory(ctx, f.cfg, tx, f.keys, f.signer), nil
 }
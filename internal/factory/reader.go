@@ -3,25 +3,115 @@ package factory
 import (
 	"context"
 	"database/sql"
+	"time"
 
+	"log_shelter/internal/config"
+	"log_shelter/internal/infra/envelope"
 	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/sqlite"
 )
 
 type ReaderFactory struct {
 	ctx        context.Context
 	tx         *sql.Tx
+	guard      reader.Guardrails
+	keys       *envelope.Keyring
 	log_reader *reader.LogReader
+
+	saved_search_reader *reader.SavedSearchReader
+	restore_reader      *reader.RestoreReader
+	partition_reader    *reader.PartitionReader
+	archive_reader      *reader.ArchiveReader
+	legal_hold_reader   *reader.LegalHoldReader
+	chain_reader        *reader.ChainReader
+	data_key_reader     *reader.DataKeyReader
+	rollup_reader       *reader.RollupReader
+	log_store           *sqlite.LogStore
 }
 
 func NewReaderFactory(ctx context.Context,
 	tx *sql.Tx,
+	cfg *config.QueryConfig,
+	keys *envelope.Keyring,
 ) *ReaderFactory {
-	return &ReaderFactory{tx: tx, ctx: ctx}
+	return &ReaderFactory{tx: tx, ctx: ctx, guard: guardrails(cfg), keys: keys}
+}
+
+func guardrails(cfg *config.QueryConfig) reader.Guardrails {
+	return reader.Guardrails{
+		StatementTimeout: time.Duration(cfg.StatementTimeout),
+		MaxTimeRange:     time.Duration(cfg.MaxTimeRange),
+		MaxPageSize:      cfg.MaxPageSize,
+		MaxCost:          cfg.MaxCost,
+	}
 }
 
 func (f *ReaderFactory) GetLogReader() *reader.LogReader {
 	if f.log_reader == nil {
-		f.log_reader = reader.NewLogReader(f.ctx, f.tx)
+		f.log_reader = reader.NewLogReader(f.ctx, f.tx, f.guard, f.keys)
 	}
 	return f.log_reader
 }
+
+func (f *ReaderFactory) GetSavedSearchReader() *reader.SavedSearchReader {
+	if f.saved_search_reader == nil {
+		f.saved_search_reader = reader.NewSavedSearchReader(f.ctx, f.tx)
+	}
+	return f.saved_search_reader
+}
+
+func (f *ReaderFactory) GetRestoreReader() *reader.RestoreReader {
+	if f.restore_reader == nil {
+		f.restore_reader = reader.NewRestoreReader(f.ctx, f.tx)
+	}
+	return f.restore_reader
+}
+
+func (f *ReaderFactory) GetPartitionReader() *reader.PartitionReader {
+	if f.partition_reader == nil {
+		f.partition_reader = reader.NewPartitionReader(f.ctx, f.tx)
+	}
+	return f.partition_reader
+}
+
+func (f *ReaderFactory) GetArchiveReader() *reader.ArchiveReader {
+	if f.archive_reader == nil {
+		f.archive_reader = reader.NewArchiveReader(f.ctx, f.tx)
+	}
+	return f.archive_reader
+}
+
+func (f *ReaderFactory) GetLegalHoldReader() *reader.LegalHoldReader {
+	if f.legal_hold_reader == nil {
+		f.legal_hold_reader = reader.NewLegalHoldReader(f.ctx, f.tx)
+	}
+	return f.legal_hold_reader
+}
+
+func (f *ReaderFactory) GetChainReader() *reader.ChainReader {
+	if f.chain_reader == nil {
+		f.chain_reader = reader.NewChainReader(f.ctx, f.tx, f.keys)
+	}
+	return f.chain_reader
+}
+
+func (f *ReaderFactory) GetDataKeyReader() *reader.DataKeyReader {
+	if f.data_key_reader == nil {
+		f.data_key_reader = reader.NewDataKeyReader(f.ctx, f.tx)
+	}
+	return f.data_key_reader
+}
+
+func (f *ReaderFactory) GetRollupReader() *reader.RollupReader {
+	if f.rollup_reader == nil {
+		f.rollup_reader = reader.NewRollupReader(f.ctx, f.tx)
+	}
+	return f.rollup_reader
+}
+
+func (f *ReaderFactory) GetLogStore() *sqlite.LogStore {
+	if f.log_store == nil {
+		f.log_store = sqlite.NewLogStore(f.ctx, f.tx, f.guard)
+	}
+	return f.log_store
+}
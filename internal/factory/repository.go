@@ -4,24 +4,84 @@ import (
 	"context"
 	"database/sql"
 
+	"log_shelter/internal/infra/integrity"
 	"log_shelter/internal/infra/repository"
 )
 
 type RepositoryFactory struct {
 	ctx      context.Context
 	tx       *sql.Tx
+	signer   *integrity.Signer
 	log_repo *repository.LogRepository
+
+	saved_search_repo *repository.SavedSearchRepository
+	partition_repo    *repository.PartitionRepository
+	archive_repo      *repository.ArchiveRepository
+	legal_hold_repo   *repository.LegalHoldRepository
+	chain_repo        *repository.ChainRepository
+	data_key_repo     *repository.DataKeyRepository
+	rollup_repo       *repository.RollupRepository
 }
 
 func NewRepositoryFactory(ctx context.Context,
 	tx *sql.Tx,
+	signer *integrity.Signer,
 ) *RepositoryFactory {
-	return &RepositoryFactory{tx: tx, ctx: ctx}
+	return &RepositoryFactory{tx: tx, ctx: ctx, signer: signer}
 }
 
 func (f *RepositoryFactory) GetLogRepository() *repository.LogRepository {
 	if f.log_repo == nil {
-		f.log_repo = repository.NewLogRepository(f.ctx, f.tx)
+		f.log_repo = repository.NewLogRepository(f.ctx, f.tx, f.signer)
 	}
 	return f.log_repo
 }
+
+func (f *RepositoryFactory) GetSavedSearchRepository() *repository.SavedSearchRepository {
+	if f.saved_search_repo == nil {
+		f.saved_search_repo = repository.NewSavedSearchRepository(f.ctx, f.tx)
+	}
+	return f.saved_search_repo
+}
+
+func (f *RepositoryFactory) GetPartitionRepository() *repository.PartitionRepository {
+	if f.partition_repo == nil {
+		f.partition_repo = repository.NewPartitionRepository(f.ctx, f.tx, f.signer)
+	}
+	return f.partition_repo
+}
+
+func (f *RepositoryFactory) GetArchiveRepository() *repository.ArchiveRepository {
+	if f.archive_repo == nil {
+		f.archive_repo = repository.NewArchiveRepository(f.ctx, f.tx)
+	}
+	return f.archive_repo
+}
+
+func (f *RepositoryFactory) GetLegalHoldRepository() *repository.LegalHoldRepository {
+	if f.legal_hold_repo == nil {
+		f.legal_hold_repo = repository.NewLegalHoldRepository(f.ctx, f.tx)
+	}
+	return f.legal_hold_repo
+}
+
+func (f *RepositoryFactory) GetChainRepository() *repository.ChainRepository {
+	if f.chain_repo == nil {
+		f.chain_repo = repository.NewChainRepository(f.ctx, f.tx)
+	}
+	return f.chain_repo
+}
+
+func (f *RepositoryFactory) GetDataKeyRepository() *repository.DataKeyRepository {
+	if f.data_key_repo == nil {
+		f.data_key_repo = repository.NewDataKeyRepository(f.ctx, f.tx)
+	}
+	return f.data_key_repo
+}
+
+func (f *RepositoryFactory) GetRollupRepository() *repository.RollupRepository {
+	if f.rollup_repo == nil {
+		f.rollup_repo = repository.NewRollupRepository(f.ctx, f.tx)
+	}
+	return f.rollup_repo
+}
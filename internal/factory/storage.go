@@ -0,0 +1,45 @@
+package factory
+
+import (
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/usecase"
+)
+
+// The getters below pick the implementation of the storage interfaces for
+// the configured backend. Usecases not built on them are Postgres only.
+
+func (f *UsecaseFactory) logAppender() usecase.LogAppender {
+	if f.cfg.Storage.IsSQLite() {
+		return f.reader_factory.GetLogStore()
+	}
+	return f.repo_factory.GetLogRepository()
+}
+
+// rollups returns nil with the sqlite backend, which keeps no rollups.
+func (f *UsecaseFactory) rollups() *repository.RollupRepository {
+	if f.cfg.Storage.IsSQLite() {
+		return nil
+	}
+	return f.repo_factory.GetRollupRepository()
+}
+
+func (f *UsecaseFactory) logSearcher() usecase.LogSearcher {
+	if f.cfg.Storage.IsSQLite() {
+		return f.reader_factory.GetLogStore()
+	}
+	return f.reader_factory.GetLogReader()
+}
+
+func (f *UsecaseFactory) timelineReader() usecase.TimelineReader {
+	if f.cfg.Storage.IsSQLite() {
+		return f.reader_factory.GetLogStore()
+	}
+	return f.reader_factory.GetLogReader()
+}
+
+func (f *UsecaseFactory) logExpirer() usecase.LogExpirer {
+	if f.cfg.Storage.IsSQLite() {
+		return f.reader_factory.GetLogStore()
+	}
+	return f.repo_factory.GetLogRepository()
+}
@@ -1,38 +1,440 @@
 package factory
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"fmt"
+	"slices"
+	"time"
 
+	"log_shelter/internal/config"
+	"log_shelter/internal/infra/archive"
+	"log_shelter/internal/infra/cache"
+	"log_shelter/internal/infra/envelope"
+	"log_shelter/internal/infra/integrity"
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
 	"log_shelter/internal/usecase"
 )
 
 type UsecaseFactory struct {
 	ctx            context.Context
-	conn           *sql.Conn
+	cfg            *config.Config
 	tx             *sql.Tx
 	repo_factory   *RepositoryFactory
 	reader_factory *ReaderFactory
+	keys           *envelope.Keyring
+	signer         *integrity.Signer
+	// db and fresh are nil for read factories. fresh returns a factory over
+	// a new write transaction, for usecases committing in batches.
+	db    Database
+	fresh func() (*UsecaseFactory, error)
 }
 
-func NewUsecaseFactory(ctx context.Context, conn *sql.Conn,
+func NewUsecaseFactory(
+	ctx context.Context,
+	cfg *config.Config,
 	tx *sql.Tx,
+	keys *envelope.Keyring,
+	signer *integrity.Signer,
 ) *UsecaseFactory {
 	return &UsecaseFactory{
-		tx: tx, ctx: ctx, conn: conn,
-		repo_factory:   NewRepositoryFactory(ctx, tx),
-		reader_factory: NewReaderFactory(ctx, tx),
+		tx: tx, ctx: ctx, cfg: cfg, keys: keys, signer: signer,
+		repo_factory:   NewRepositoryFactory(ctx, tx, signer),
+		reader_factory: NewReaderFactory(ctx, tx, &cfg.Query, keys),
 	}
 }
 
+// Close rolls back the transaction unless the usecase already committed
+// it, which returns its connection to the pool.
 func (f *UsecaseFactory) Close() {
-	f.conn.Close()
+	f.tx.Rollback()
 }
 
 func (f *UsecaseFactory) GetAppendLogUsecase() *usecase.AppendLogUsecase {
-	return &usecase.AppendLogUsecase{Tx: f.tx, LogRepo: f.repo_factory.GetLogRepository()}
+	return &usecase.AppendLogUsecase{
+		Tx:         f.tx,
+		LogRepo:    f.logAppender(),
+		Chain:      f.chaining(),
+		Encryption: f.encryption(),
+		Rollups:    f.rollups(),
+	}
+}
+
+// chaining returns nil when no source is hash chained.
+func (f *UsecaseFactory) chaining() *usecase.Chaining {
+	if len(f.cfg.Integrity.Sources) == 0 {
+		return nil
+	}
+	return &usecase.Chaining{
+		Sources:   f.cfg.Integrity.Sources,
+		ChainRepo: f.repo_factory.GetChainRepository(),
+	}
+}
+
+// encryption returns nil without a master key.
+func (f *UsecaseFactory) encryption() *usecase.Encryption {
+	if f.keys == nil {
+		return nil
+	}
+	return &usecase.Encryption{
+		Sources:       f.cfg.Encryption.Sources,
+		Keys:          f.keys,
+		DataKeyReader: f.reader_factory.GetDataKeyReader(),
+		DataKeyRepo:   f.repo_factory.GetDataKeyRepository(),
+	}
 }
 
 func (f *UsecaseFactory) GetGetLogUsecase() *usecase.GetLogUsecase {
-	return &usecase.GetLogUsecase{Tx: f.tx, LogReader: f.reader_factory.GetLogReader()}
+	return &usecase.GetLogUsecase{Tx: f.tx, LogReader: f.logSearcher()}
+}
+
+func (f *UsecaseFactory) GetGetTimelineUsecase() *usecase.GetTimelineUsecase {
+	return &usecase.GetTimelineUsecase{Tx: f.tx, LogReader: f.timelineReader()}
+}
+
+func (f *UsecaseFactory) GetGetContextUsecase() *usecase.GetContextUsecase {
+	return &usecase.GetContextUsecase{Tx: f.tx, LogReader: f.reader_factory.GetLogReader()}
+}
+
+func (f *UsecaseFactory) GetGetTraceUsecase() *usecase.GetTraceUsecase {
+	return &usecase.GetTraceUsecase{Tx: f.tx, LogReader: f.reader_factory.GetLogReader()}
+}
+
+func (f *UsecaseFactory) GetGetFacetsUsecase(
+	cache *cache.MemoryCache[[]byte],
+) *usecase.GetFacetsUsecase {
+	return &usecase.GetFacetsUsecase{
+		Tx: f.tx, LogReader: f.reader_factory.GetLogReader(), Cache: cache,
+	}
+}
+
+func (f *UsecaseFactory) GetGetTopUsecase() *usecase.GetTopUsecase {
+	return &usecase.GetTopUsecase{Tx: f.tx, LogReader: f.reader_factory.GetLogReader()}
+}
+
+func (f *UsecaseFactory) GetGetSimilarUsecase() *usecase.GetSimilarUsecase {
+	return &usecase.GetSimilarUsecase{Tx: f.tx, LogReader: f.reader_factory.GetLogReader()}
+}
+
+func (f *UsecaseFactory) GetCompareWindowsUsecase() *usecase.CompareWindowsUsecase {
+	return &usecase.CompareWindowsUsecase{Tx: f.tx, LogReader: f.reader_factory.GetLogReader()}
+}
+
+func (f *UsecaseFactory) GetCreateSavedSearchUsecase() *usecase.CreateSavedSearchUsecase {
+	return &usecase.CreateSavedSearchUsecase{
+		Tx: f.tx, SavedSearchRepo: f.repo_factory.GetSavedSearchRepository(),
+	}
+}
+
+func (f *UsecaseFactory) GetUpdateSavedSearchUsecase() *usecase.UpdateSavedSearchUsecase {
+	return &usecase.UpdateSavedSearchUsecase{
+		Tx: f.tx, SavedSearchRepo: f.repo_factory.GetSavedSearchRepository(),
+	}
+}
+
+func (f *UsecaseFactory) GetDeleteSavedSearchUsecase() *usecase.DeleteSavedSearchUsecase {
+	return &usecase.DeleteSavedSearchUsecase{
+		Tx: f.tx, SavedSearchRepo: f.repo_factory.GetSavedSearchRepository(),
+	}
+}
+
+func (f *UsecaseFactory) GetGetSavedSearchUsecase() *usecase.GetSavedSearchUsecase {
+	return &usecase.GetSavedSearchUsecase{
+		Tx: f.tx, SavedSearchReader: f.reader_factory.GetSavedSearchReader(),
+	}
+}
+
+func (f *UsecaseFactory) GetListSavedSearchesUsecase() *usecase.ListSavedSearchesUsecase {
+	return &usecase.ListSavedSearchesUsecase{
+		Tx: f.tx, SavedSearchReader: f.reader_factory.GetSavedSearchReader(),
+	}
+}
+
+func (f *UsecaseFactory) GetRunSavedSearchUsecase() *usecase.RunSavedSearchUsecase {
+	return &usecase.RunSavedSearchUsecase{
+		Tx:                f.tx,
+		SavedSearchReader: f.reader_factory.GetSavedSearchReader(),
+		GetLog:            f.GetGetLogUsecase(),
+	}
+}
+
+func (f *UsecaseFactory) GetExportLogsUsecase() *usecase.ExportLogsUsecase {
+	return &usecase.ExportLogsUsecase{
+		Tx:        f.tx,
+		LogReader: f.reader_factory.GetLogReader(),
+		BatchSize: f.cfg.Export.BatchSize,
+	}
+}
+
+func (f *UsecaseFactory) GetRestoreLogsUsecase() *usecase.RestoreLogsUsecase {
+	return &usecase.RestoreLogsUsecase{
+		Tx:        f.tx,
+		LogReader: f.reader_factory.GetLogReader(),
+		LogRepo:   f.repo_factory.GetLogRepository(),
+		RetainFor: time.Duration(f.cfg.Logs.RestoreRetention),
+	}
+}
+
+func (f *UsecaseFactory) GetListRestoresUsecase() *usecase.ListRestoresUsecase {
+	return &usecase.ListRestoresUsecase{
+		Tx: f.tx, RestoreReader: f.reader_factory.GetRestoreReader(),
+	}
+}
+
+func (f *UsecaseFactory) GetGetPartitionsUsecase() *usecase.GetPartitionsUsecase {
+	return &usecase.GetPartitionsUsecase{
+		Tx: f.tx, PartitionReader: f.reader_factory.GetPartitionReader(),
+	}
+}
+
+func (f *UsecaseFactory) GetMaintainPartitionsUsecase(
+	archiver *archive.Archiver,
+) *usecase.MaintainPartitionsUsecase {
+	return &usecase.MaintainPartitionsUsecase{
+		Tx:              f.tx,
+		PartitionReader: f.reader_factory.GetPartitionReader(),
+		PartitionRepo:   f.repo_factory.GetPartitionRepository(),
+		Plan: usecase.PartitionPlan{
+			Interval:  f.cfg.Partitions.Interval,
+			Premake:   f.cfg.Partitions.Premake,
+			Retention: time.Duration(f.cfg.Partitions.Retention),
+		},
+		Archive: f.archival(archiver),
+		Detach:  f.detachPartition,
+		Fresh: func() (*usecase.MaintainPartitionsUsecase, error) {
+			next, err := f.fresh()
+			if err != nil {
+				return nil, err
+			}
+			return next.GetMaintainPartitionsUsecase(archiver), nil
+		},
+	}
+}
+
+// RetentionRules compiles the retention rules of cfg in priority order,
+// lower priorities first and config order between equal ones. The legacy
+// "after_time" policy becomes a final rule matching every log.
+func RetentionRules(cfg config.LogConfig) ([]usecase.RetentionRule, error) {
+	rules := slices.Clone(cfg.Rules)
+	slices.SortStableFunc(rules, func(a, b config.RetentionRuleConfig) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+
+	ret := make([]usecase.RetentionRule, 0, len(rules)+1)
+	for i, r := range rules {
+		rule := usecase.RetentionRule{
+			Name: r.Name,
+			Match: reader.LogFilter{
+				Sources:     r.Sources,
+				LoggerNames: r.LoggerNames,
+				Deleted:     reader.DeletedInclude,
+			},
+			MaxAge:   time.Duration(r.MaxAge),
+			MaxRows:  r.MaxRows,
+			MaxBytes: r.MaxBytes,
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule_%d", i)
+		}
+
+		switch r.Delete {
+		case "", "soft":
+		case "hard":
+			rule.Hard = true
+		default:
+			return nil, fmt.Errorf("retention rule %s: unknown delete mode %q", rule.Name, r.Delete)
+		}
+
+		if r.MinLevel != "" {
+			level, ok := model.ParseLevel(r.MinLevel)
+			if !ok {
+				return nil, fmt.Errorf("retention rule %s: unknown min_level %q", rule.Name, r.MinLevel)
+			}
+			rule.Match.MinLevel = &level
+		}
+		if r.MaxLevel != "" {
+			level, ok := model.ParseLevel(r.MaxLevel)
+			if !ok {
+				return nil, fmt.Errorf("retention rule %s: unknown max_level %q", rule.Name, r.MaxLevel)
+			}
+			rule.Match.MaxLevel = &level
+		}
+
+		ret = append(ret, rule)
+	}
+
+	switch cfg.RetencionPolicy {
+	case "", "rules":
+	case "after_time":
+		ret = append(ret, usecase.RetentionRule{
+			Name:   "after_time",
+			Match:  reader.LogFilter{Deleted: reader.DeletedInclude},
+			MaxAge: time.Duration(cfg.DeleteAfter),
+		})
+	default:
+		return nil, fmt.Errorf("unknown retencion_policy %q", cfg.RetencionPolicy)
+	}
+	return ret, nil
+}
+
+// CheckPartitionRetention rejects a partition retention shorter than the
+// max_age of a retention rule, as dropping a partition deletes its logs
+// whatever rule they fall under. Soft-deleted logs stay for purge_after on
+// top of max_age. Rules without max_age only bound volume and don't count.
+func CheckPartitionRetention(cfg *config.Config) error {
+	retention := time.Duration(cfg.Partitions.Retention)
+	if !cfg.Partitions.Enabled || retention == 0 {
+		return nil
+	}
+	rules, err := RetentionRules(cfg.Logs)
+	if err != nil {
+		// Retention is disabled then, which logRetention reports.
+		return nil
+	}
+	for _, rule := range rules {
+		if rule.MaxAge == 0 {
+			continue
+		}
+		keep := rule.MaxAge
+		if !rule.Hard {
+			keep += time.Duration(cfg.Logs.PurgeAfter)
+		}
+		if keep > retention {
+			return fmt.Errorf(
+				"partition retention %s is shorter than the %s retention rule %s keeps logs for",
+				retention, keep, rule.Name)
+		}
+	}
+	return nil
+}
+
+// archival returns nil when archiving is disabled.
+func (f *UsecaseFactory) archival(archiver *archive.Archiver) *usecase.Archival {
+	if archiver == nil {
+		return nil
+	}
+	return &usecase.Archival{
+		Ctx:         f.ctx,
+		Tx:          f.tx,
+		Archiver:    archiver,
+		LogReader:   f.reader_factory.GetLogReader(),
+		LogRepo:     f.repo_factory.GetLogRepository(),
+		ArchiveRepo: f.repo_factory.GetArchiveRepository(),
+		BatchSize:   f.cfg.Archive.BatchSize,
+		Fresh: func() (*usecase.Archival, error) {
+			next, err := f.fresh()
+			if err != nil {
+				return nil, err
+			}
+			return next.archival(archiver), nil
+		},
+	}
+}
+
+// detachPartition detaches a logs partition concurrently, which Postgres
+// only does outside of transactions.
+func (f *UsecaseFactory) detachPartition(name string) error {
+	conn, err := f.db.GetConnection()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return repository.DetachPartitionConcurrently(f.ctx, conn, name)
+}
+
+func (f *UsecaseFactory) GetApplyRetentionUsecase(
+	rules []usecase.RetentionRule,
+	archiver *archive.Archiver,
+) *usecase.ApplyRetentionUsecase {
+	return &usecase.ApplyRetentionUsecase{
+		Tx:      f.tx,
+		LogRepo: f.logExpirer(),
+		Rules:   rules,
+		Archive: f.archival(archiver),
+	}
+}
+
+func (f *UsecaseFactory) GetPurgeLogsUsecase(archiver *archive.Archiver) *usecase.PurgeLogsUsecase {
+	return &usecase.PurgeLogsUsecase{
+		Tx:      f.tx,
+		LogRepo: f.logExpirer(),
+		Archive: f.archival(archiver),
+	}
+}
+
+func (f *UsecaseFactory) GetListSegmentsUsecase() *usecase.ListSegmentsUsecase {
+	return &usecase.ListSegmentsUsecase{
+		Tx: f.tx, ArchiveReader: f.reader_factory.GetArchiveReader(),
+	}
+}
+
+func (f *UsecaseFactory) GetRehydrateUsecase(archiver *archive.Archiver) *usecase.RehydrateUsecase {
+	return &usecase.RehydrateUsecase{
+		Ctx:           f.ctx,
+		Tx:            f.tx,
+		Archiver:      archiver,
+		ArchiveReader: f.reader_factory.GetArchiveReader(),
+		ArchiveRepo:   f.repo_factory.GetArchiveRepository(),
+	}
+}
+
+func (f *UsecaseFactory) GetCreateLegalHoldUsecase() *usecase.CreateLegalHoldUsecase {
+	return &usecase.CreateLegalHoldUsecase{
+		Tx:              f.tx,
+		LegalHoldReader: f.reader_factory.GetLegalHoldReader(),
+		LegalHoldRepo:   f.repo_factory.GetLegalHoldRepository(),
+	}
+}
+
+func (f *UsecaseFactory) GetListLegalHoldsUsecase() *usecase.ListLegalHoldsUsecase {
+	return &usecase.ListLegalHoldsUsecase{
+		Tx: f.tx, LegalHoldReader: f.reader_factory.GetLegalHoldReader(),
+	}
+}
+
+func (f *UsecaseFactory) GetReleaseLegalHoldUsecase() *usecase.ReleaseLegalHoldUsecase {
+	return &usecase.ReleaseLegalHoldUsecase{
+		Tx: f.tx, LegalHoldRepo: f.repo_factory.GetLegalHoldRepository(),
+	}
+}
+
+func (f *UsecaseFactory) GetCheckpointChainsUsecase() *usecase.CheckpointChainsUsecase {
+	return &usecase.CheckpointChainsUsecase{
+		Tx:          f.tx,
+		ChainReader: f.reader_factory.GetChainReader(),
+		ChainRepo:   f.repo_factory.GetChainRepository(),
+		Signer:      f.signer,
+	}
+}
+
+func (f *UsecaseFactory) GetVerifyChainUsecase() *usecase.VerifyChainUsecase {
+	return &usecase.VerifyChainUsecase{
+		Tx: f.tx, ChainReader: f.reader_factory.GetChainReader(), Signer: f.signer,
+	}
+}
+
+func (f *UsecaseFactory) GetRotateKeysUsecase() *usecase.RotateKeysUsecase {
+	return &usecase.RotateKeysUsecase{
+		Tx:         f.tx,
+		Encryption: f.encryption(),
+		LogRepo:    f.repo_factory.GetLogRepository(),
+		MaxAge:     time.Duration(f.cfg.Encryption.DataKeyMaxAge),
+		BatchSize:  f.cfg.Encryption.RotationBatchSize,
+	}
+}
+
+func (f *UsecaseFactory) GetGetStatsUsecase() *usecase.GetStatsUsecase {
+	return &usecase.GetStatsUsecase{Tx: f.tx, RollupReader: f.reader_factory.GetRollupReader()}
+}
+
+func (f *UsecaseFactory) GetDownsampleRollupsUsecase() *usecase.DownsampleRollupsUsecase {
+	return &usecase.DownsampleRollupsUsecase{
+		Tx:          f.tx,
+		RollupRepo:  f.repo_factory.GetRollupRepository(),
+		HourlyAfter: time.Duration(f.cfg.Rollups.HourlyAfter),
+		DailyAfter:  time.Duration(f.cfg.Rollups.DailyAfter),
+	}
 }
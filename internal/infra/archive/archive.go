@@ -0,0 +1,181 @@
+package archive
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"log_shelter/internal/config"
+	"log_shelter/internal/infra/export"
+	"log_shelter/internal/model"
+)
+
+const dayLayout = "2006-01-02"
+
+// Archiver writes logs about to be deleted into compressed segments, one
+// per source and UTC day of every batch.
+type Archiver struct {
+	store  Store
+	format export.Format
+}
+
+// NewArchiver returns nil when archiving is disabled.
+func NewArchiver(cfg *config.ArchiveConfig) (*Archiver, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	format := export.Format(cfg.Format)
+	switch format {
+	case "":
+		format = export.FormatNDJSON
+	case export.FormatNDJSON, export.FormatParquet:
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", cfg.Format)
+	}
+
+	var store Store
+	var err error
+	switch cfg.Store {
+	case "", "dir":
+		store, err = NewDirStore(cfg.Dir)
+	case "s3":
+		store, err = NewS3Store(cfg.S3.Endpoint, cfg.S3.Region, cfg.S3.Bucket,
+			cfg.S3.AccessKey, cfg.S3.SecretKey)
+	default:
+		err = fmt.Errorf("unknown archive store %q", cfg.Store)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archiver{store: store, format: format}, nil
+}
+
+// keySafe keeps a source usable as a path element.
+func keySafe(source string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' ||
+			r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, source)
+}
+
+// Archive stores logs and returns the manifest entries of the written
+// segments. Keys derive from the id range, so archiving the same logs again
+// after a failed transaction overwrites the previous segment.
+func (a *Archiver) Archive(ctx context.Context, logs []model.LogModel) ([]model.ArchiveSegment, error) {
+	type group struct {
+		source string
+		day    time.Time
+	}
+	groups := map[group][]model.LogModel{}
+	for _, l := range logs {
+		day := l.CreatedAt.UTC().Truncate(24 * time.Hour)
+		g := group{source: l.Source, day: day}
+		groups[g] = append(groups[g], l)
+	}
+
+	ret := make([]model.ArchiveSegment, 0, len(groups))
+	for g, logs := range groups {
+		slices.SortFunc(logs, func(a, b model.LogModel) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+		segment, err := a.write(ctx, g.source, g.day, logs)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, segment)
+	}
+	slices.SortFunc(ret, func(a, b model.ArchiveSegment) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return ret, nil
+}
+
+func (a *Archiver) write(
+	ctx context.Context,
+	source string,
+	day time.Time,
+	logs []model.LogModel,
+) (model.ArchiveSegment, error) {
+	first, last := logs[0], logs[len(logs)-1]
+	segment := model.ArchiveSegment{
+		Key: fmt.Sprintf("%s/%s/%d-%d.%s", keySafe(source), day.Format(dayLayout),
+			first.ID, last.ID, a.format.Extension(true)),
+		Source:         source,
+		Day:            day,
+		Format:         string(a.format),
+		Rows:           int64(len(logs)),
+		FirstID:        first.ID,
+		LastID:         last.ID,
+		FirstCreatedAt: first.CreatedAt,
+		LastCreatedAt:  first.CreatedAt,
+	}
+
+	var buf bytes.Buffer
+	h := sha256.New()
+	enc, err := export.NewEncoder(io.MultiWriter(&buf, h), a.format, true)
+	if err != nil {
+		return segment, err
+	}
+	for i := range logs {
+		if logs[i].CreatedAt.Before(segment.FirstCreatedAt) {
+			segment.FirstCreatedAt = logs[i].CreatedAt
+		}
+		if logs[i].CreatedAt.After(segment.LastCreatedAt) {
+			segment.LastCreatedAt = logs[i].CreatedAt
+		}
+		if logs[i].Sealed != nil {
+			id := int64(logs[i].Sealed.DataKeyID)
+			if !slices.Contains(segment.DataKeyIDs, id) {
+				segment.DataKeyIDs = append(segment.DataKeyIDs, id)
+			}
+		}
+		err = enc.Write(&logs[i])
+		if err != nil {
+			return segment, err
+		}
+	}
+	err = enc.Close()
+	if err != nil {
+		return segment, err
+	}
+	segment.SizeBytes = int64(buf.Len())
+	segment.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	err = a.store.Put(ctx, segment.Key, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return segment, fmt.Errorf("archive %s: %w", segment.Key, err)
+	}
+	return segment, nil
+}
+
+// Open returns a decoder over the logs of an archived segment, after
+// checking it against the manifest checksum.
+func (a *Archiver) Open(ctx context.Context, segment model.ArchiveSegment) (export.Decoder, error) {
+	body, err := a.store.Get(ctx, segment.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != segment.SHA256 {
+		return nil, fmt.Errorf("archive %s: checksum mismatch", segment.Key)
+	}
+	return export.NewDecoder(bytes.NewReader(data), export.Format(segment.Format), true)
+}
@@ -0,0 +1,226 @@
+package archive
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm   = "AWS4-HMAC-SHA256"
+	s3DateLayout  = "20060102T150405Z"
+	s3EmptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Store stores segments in an S3 compatible bucket, addressed path-style
+// as MinIO expects, with requests signed by AWS Signature Version 4.
+type S3Store struct {
+	endpoint   *url.URL
+	region     string
+	bucket     string
+	access_key string
+	secret_key string
+	client     *http.Client
+}
+
+func NewS3Store(endpoint, region, bucket, access_key, secret_key string) (*S3Store, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint %q needs a scheme and a host", endpoint)
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is not set")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Store{
+		endpoint:   u,
+		region:     region,
+		bucket:     bucket,
+		access_key: access_key,
+		secret_key: secret_key,
+		client:     &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// uriEncode escapes everything but the unreserved characters, keeping
+// slashes, the way SigV4 canonical URIs require.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// canonicalQuery sorts the query parameters by name and value and escapes
+// them, slashes included.
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, strings.ReplaceAll(uriEncode(name), "/", "%2F")+"="+
+				strings.ReplaceAll(uriEncode(v), "/", "%2F"))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (s *S3Store) request(
+	ctx context.Context,
+	method string,
+	key string,
+	body io.Reader,
+	payload_hash string,
+) (*http.Request, error) {
+	path := strings.TrimSuffix(s.endpoint.Path, "/") + "/" + s.bucket + "/" + key
+	u := *s.endpoint
+	u.Path = path
+	u.RawPath = uriEncode(path)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Amz-Content-Sha256", payload_hash)
+	signV4(req, s.access_key, s.secret_key, s.region, "s3", payload_hash, time.Now())
+	return req, nil
+}
+
+// signV4 signs req with AWS Signature Version 4 at now. The host and every
+// header already set on req are signed; payload_hash is the hex SHA-256 of
+// the body, or UNSIGNED-PAYLOAD.
+func signV4(
+	req *http.Request,
+	access_key string,
+	secret_key string,
+	region string,
+	service string,
+	payload_hash string,
+	now time.Time,
+) {
+	now = now.UTC()
+	amz_date := now.Format(s3DateLayout)
+	scope := now.Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+	req.Header.Set("X-Amz-Date", amz_date)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical_headers strings.Builder
+	for _, name := range names {
+		canonical_headers.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signed_headers := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonical_headers.String(),
+		signed_headers,
+		payload_hash,
+	}, "\n")
+	canonical_hash := sha256.Sum256([]byte(canonical))
+	to_sign := s3Algorithm + "\n" + amz_date + "\n" + scope + "\n" +
+		hex.EncodeToString(canonical_hash[:])
+
+	signing_key := hmacSHA256([]byte("AWS4"+secret_key), now.Format("20060102"))
+	signing_key = hmacSHA256(signing_key, region)
+	signing_key = hmacSHA256(signing_key, service)
+	signing_key = hmacSHA256(signing_key, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, access_key, scope, signed_headers,
+		hex.EncodeToString(hmacSHA256(signing_key, to_sign))))
+}
+
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, msg)
+	}
+	return resp, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.ReadSeeker) error {
+	h := sha256.New()
+	size, err := io.Copy(h, body)
+	if err != nil {
+		return err
+	}
+	_, err = body.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	req, err := s.request(ctx, http.MethodPut, key, body, hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, s3EmptySHA256)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
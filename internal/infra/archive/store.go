@@ -0,0 +1,63 @@
+package archive
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Store keeps archived segments under slash separated keys.
+type Store interface {
+	Put(ctx context.Context, key string, body io.ReadSeeker) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// DirStore stores segments as files below a local directory.
+type DirStore struct {
+	root string
+}
+
+func NewDirStore(root string) (*DirStore, error) {
+	err := os.MkdirAll(root, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &DirStore{root: root}, nil
+}
+
+func (s *DirStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// Put writes the segment next to its final path first, so readers never
+// see a partially written file.
+func (s *DirStore) Put(_ context.Context, key string, body io.ReadSeeker) error {
+	path := s.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".segment-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, body)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func (s *DirStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+type MemoryCache[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]entry[V]
+}
+
+func NewMemoryCache[V any](ttl time.Duration) *MemoryCache[V] {
+	return &MemoryCache[V]{
+		ttl:     ttl,
+		entries: make(map[string]entry[V]),
+	}
+}
+
+func (c *MemoryCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *MemoryCache[V]) Set(key string, value V) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
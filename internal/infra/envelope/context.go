@@ -0,0 +1,17 @@
+package envelope
+
+import "context"
+
+type decryptKey struct{}
+
+// AllowDecrypt returns a copy of ctx under which reads may decrypt logs,
+// for callers that proved they're allowed to see encrypted payloads.
+func AllowDecrypt(ctx context.Context) context.Context {
+	return context.WithValue(ctx, decryptKey{}, true)
+}
+
+// MayDecrypt tells whether reads made under ctx may decrypt logs.
+func MayDecrypt(ctx context.Context) bool {
+	allowed, _ := ctx.Value(decryptKey{}).(bool)
+	return allowed
+}
@@ -0,0 +1,73 @@
+// Package envelope encrypts log payloads with per-source data keys, which
+// are stored wrapped by a master key. Only the master keyring lives
+// outside the database.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// KeySize is the size of master and data keys, AES-256.
+const KeySize = 32
+
+var ErrDecrypt = errors.New("cannot decrypt")
+
+// Fields of a log that get encrypted.
+const (
+	FieldRawLog     = "raw_log"
+	FieldAttributes = "attributes"
+)
+
+// AAD binds a ciphertext to the source, field and creation time of the log
+// it was sealed for, so it can't be moved to another log or another field.
+// created_at counts in microseconds, the precision Postgres keeps.
+func AAD(source string, field string, created_at time.Time) []byte {
+	micros := strconv.FormatInt(created_at.UnixMicro(), 10)
+	return []byte("log_shelter.v1\x00" + source + "\x00" + field + "\x00" + micros)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts plaintext with AES-GCM under key, authenticating aad too.
+// The random nonce is prepended to the ciphertext.
+func Seal(key []byte, plaintext []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open decrypts what Seal returned for the same key and aad.
+func Open(key []byte, ciphertext []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrDecrypt)
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	ret, err := gcm.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,144 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// wrapAAD binds wrapped data keys to their purpose.
+var wrapAAD = []byte("log_shelter.data_key.v1")
+
+// Keyring holds the master keys and caches the data keys they unwrapped.
+// The first master key is the current one, which wraps new data keys; the
+// others are kept to unwrap data keys until the rotation rewraps them.
+type Keyring struct {
+	current string
+	masters map[string][]byte
+
+	mu   sync.RWMutex
+	data map[uint64][]byte
+}
+
+// LoadKeyring reads base64 AES-256 master keys, one per line, from path,
+// or from the variable env when path is empty, where commas separate keys
+// too.
+func LoadKeyring(path string, env string) (*Keyring, error) {
+	var data string
+	var origin string
+	switch {
+	case path != "" && env != "":
+		return nil, errors.New("master key: set either a file or an env variable")
+	case path != "":
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data, origin = string(raw), path
+	case env != "":
+		data, origin = os.Getenv(env), "$"+env
+	default:
+		return nil, errors.New("master key: no file or env variable set")
+	}
+
+	ret := &Keyring{masters: make(map[string][]byte), data: make(map[uint64][]byte)}
+	fields := strings.FieldsFunc(data, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ','
+	})
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			return nil, fmt.Errorf("master key %s: %w", origin, err)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("master key %s: expected %d bytes, got %d",
+				origin, KeySize, len(key))
+		}
+		id := keyID(key)
+		if ret.current == "" {
+			ret.current = id
+		}
+		ret.masters[id] = key
+	}
+	if ret.current == "" {
+		return nil, fmt.Errorf("master key %s: no key found", origin)
+	}
+	return ret, nil
+}
+
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// MasterKeyID identifies the current master key.
+func (k *Keyring) MasterKeyID() string {
+	return k.current
+}
+
+// NewDataKey returns a random data key and the key wrapped by the current
+// master key.
+func (k *Keyring) NewDataKey() ([]byte, []byte, error) {
+	key := make([]byte, KeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapped, err := Seal(k.masters[k.current], key, wrapAAD)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, wrapped, nil
+}
+
+func (k *Keyring) unwrap(wrapped []byte, master_key_id string) ([]byte, error) {
+	master, ok := k.masters[master_key_id]
+	if !ok {
+		return nil, fmt.Errorf("%w: master key %s is not in the keyring", ErrDecrypt, master_key_id)
+	}
+	return Open(master, wrapped, wrapAAD)
+}
+
+// DataKey returns the data key with id, unwrapping what load returns, the
+// wrapped key and its master key ID, unless it's cached already.
+func (k *Keyring) DataKey(id uint64, load func() ([]byte, string, error)) ([]byte, error) {
+	k.mu.RLock()
+	key, ok := k.data[id]
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	wrapped, master_key_id, err := load()
+	if err != nil {
+		return nil, err
+	}
+	key, err = k.unwrap(wrapped, master_key_id)
+	if err != nil {
+		return nil, fmt.Errorf("data key %d: %w", id, err)
+	}
+	k.mu.Lock()
+	k.data[id] = key
+	k.mu.Unlock()
+	return key, nil
+}
+
+// Rewrap wraps a data key wrapped by an older master key with the current
+// one.
+func (k *Keyring) Rewrap(wrapped []byte, master_key_id string) ([]byte, error) {
+	key, err := k.unwrap(wrapped, master_key_id)
+	if err != nil {
+		return nil, err
+	}
+	return Seal(k.masters[k.current], key, wrapAAD)
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"log_shelter/internal/model"
+)
+
+var csvHeader = []string{
+	"id",
+	"created_at",
+	"log_level",
+	"source",
+	"logger_name",
+	"request_id",
+	"trace_id",
+	"span_id",
+	"parent_span_id",
+	"raw_log",
+	"attributes",
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func (e *csvEncoder) Write(entry *model.LogModel) error {
+	if !e.header {
+		err := e.w.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+		e.header = true
+	}
+
+	attributes := ""
+	if len(entry.Attributes) != 0 {
+		data, err := json.Marshal(entry.Attributes)
+		if err != nil {
+			return err
+		}
+		attributes = string(data)
+	}
+
+	return e.w.Write([]string{
+		strconv.FormatUint(entry.ID, 10),
+		entry.CreatedAt.Format(time.RFC3339Nano),
+		entry.LogLevel,
+		entry.Source,
+		entry.LoggerName,
+		deref(entry.RequestID),
+		deref(entry.TraceID),
+		deref(entry.SpanID),
+		deref(entry.ParentSpanID),
+		entry.RawLog,
+		attributes,
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	if !e.header {
+		err := e.w.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
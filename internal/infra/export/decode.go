@@ -0,0 +1,306 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"log_shelter/internal/model"
+)
+
+// Decoder reads back log entries written by an Encoder, returning io.EOF
+// after the last one.
+type Decoder interface {
+	Read() (*model.LogModel, error)
+}
+
+// NewDecoder returns a decoder for NDJSON and Parquet written by this
+// package. Parquet files are read into memory as a whole.
+func NewDecoder(r io.Reader, format Format, compressed bool) (Decoder, error) {
+	switch format {
+	case FormatNDJSON:
+		if compressed {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			r = gz
+		}
+		return &ndjsonDecoder{dec: json.NewDecoder(bufio.NewReader(r))}, nil
+	case FormatParquet:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return newParquetDecoder(data)
+	default:
+		return nil, fmt.Errorf("cannot decode export format %q", format)
+	}
+}
+
+type ndjsonDecoder struct {
+	dec *json.Decoder
+}
+
+func (d *ndjsonDecoder) Read() (*model.LogModel, error) {
+	var entry model.LogModel
+	err := d.dec.Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+var errParquet = errors.New("malformed parquet file")
+
+// parquetDecoder reads Parquet files with the layout of parquetEncoder:
+// flat INT64 and BYTE_ARRAY columns, PLAIN values and RLE definition
+// levels, uncompressed or gzipped, one row group at a time.
+type parquetDecoder struct {
+	data       []byte
+	columns    []parquetColumn
+	row_groups []any
+	rows       []model.LogModel
+}
+
+func field[T any](s map[int16]any, id int16) T {
+	v, _ := s[id].(T)
+	return v
+}
+
+func newParquetDecoder(data []byte) (*parquetDecoder, error) {
+	if len(data) < 12 || string(data[:4]) != parquetMagic ||
+		string(data[len(data)-4:]) != parquetMagic {
+		return nil, errParquet
+	}
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if size > len(data)-12 {
+		return nil, errParquet
+	}
+	t := thriftReader{data: data[len(data)-8-size : len(data)-8]}
+	meta := t.Struct()
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	d := &parquetDecoder{data: data, row_groups: field[[]any](meta, 4)}
+	for i, el := range field[[]any](meta, 2) {
+		schema, _ := el.(map[int16]any)
+		if i == 0 {
+			continue
+		}
+		d.columns = append(d.columns, parquetColumn{
+			name:     string(field[[]byte](schema, 4)),
+			typ:      int32(field[int64](schema, 1)),
+			optional: field[int64](schema, 3) == parquetOptional,
+		})
+	}
+	return d, nil
+}
+
+func (d *parquetDecoder) Read() (*model.LogModel, error) {
+	for len(d.rows) == 0 {
+		if len(d.row_groups) == 0 {
+			return nil, io.EOF
+		}
+		group, _ := d.row_groups[0].(map[int16]any)
+		d.row_groups = d.row_groups[1:]
+		err := d.readRowGroup(group)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret := &d.rows[0]
+	d.rows = d.rows[1:]
+	return ret, nil
+}
+
+func (d *parquetDecoder) readRowGroup(group map[int16]any) error {
+	rows := make([]model.LogModel, field[int64](group, 3))
+	chunks := field[[]any](group, 1)
+	if len(chunks) != len(d.columns) {
+		return errParquet
+	}
+
+	for i, el := range chunks {
+		chunk, _ := el.(map[int16]any)
+		meta := field[map[int16]any](chunk, 3)
+		page, err := d.page(field[int64](meta, 9), field[int64](meta, 4))
+		if err != nil {
+			return err
+		}
+		err = decodeColumn(d.columns[i], page, rows)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", d.columns[i].name, err)
+		}
+	}
+	d.rows = rows
+	return nil
+}
+
+// page returns the uncompressed content of the data page at offset.
+func (d *parquetDecoder) page(offset int64, codec int64) ([]byte, error) {
+	if offset <= 0 || offset >= int64(len(d.data)) {
+		return nil, errParquet
+	}
+	t := thriftReader{data: d.data[offset:]}
+	header := t.Struct()
+	if t.err != nil {
+		return nil, t.err
+	}
+	if field[int64](header, 1) != parquetDataPage {
+		return nil, fmt.Errorf("%w: unsupported page type", errParquet)
+	}
+	page := t.bytes(int(field[int64](header, 3)))
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	switch codec {
+	case parquetUncompressed:
+		return page, nil
+	case parquetGzip:
+		gz, err := gzip.NewReader(bytes.NewReader(page))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(gz)
+	default:
+		return nil, fmt.Errorf("%w: unsupported codec %d", errParquet, codec)
+	}
+}
+
+// decodeLevels reads count definition levels of bit width 1 encoded with
+// the RLE/bit-packed hybrid encoding and returns the rest of the page.
+func decodeLevels(page []byte, count int) ([]byte, []byte, error) {
+	if len(page) < 4 {
+		return nil, nil, errParquet
+	}
+	size := int(binary.LittleEndian.Uint32(page))
+	if size > len(page)-4 {
+		return nil, nil, errParquet
+	}
+	runs, rest := page[4:4+size], page[4+size:]
+
+	levels := make([]byte, 0, count)
+	for len(runs) != 0 && len(levels) < count {
+		header, n := binary.Uvarint(runs)
+		if n <= 0 || n >= len(runs) {
+			return nil, nil, errParquet
+		}
+		runs = runs[n:]
+		if header&1 == 0 {
+			for range header >> 1 {
+				levels = append(levels, runs[0]&1)
+			}
+			runs = runs[1:]
+			continue
+		}
+		groups := int(header >> 1)
+		if groups > len(runs) {
+			return nil, nil, errParquet
+		}
+		for _, b := range runs[:groups] {
+			for bit := range 8 {
+				levels = append(levels, b>>bit&1)
+			}
+		}
+		runs = runs[groups:]
+	}
+	if len(levels) < count {
+		return nil, nil, errParquet
+	}
+	return levels[:count], rest, nil
+}
+
+func decodeColumn(col parquetColumn, page []byte, rows []model.LogModel) error {
+	var levels []byte
+	if col.optional {
+		var err error
+		levels, page, err = decodeLevels(page, len(rows))
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range rows {
+		if levels != nil && levels[i] == 0 {
+			continue
+		}
+		if col.typ == parquetInt64 {
+			if len(page) < 8 {
+				return errParquet
+			}
+			setParquetInt64(&rows[i], col.name, int64(binary.LittleEndian.Uint64(page)))
+			page = page[8:]
+			continue
+		}
+		if len(page) < 4 {
+			return errParquet
+		}
+		size := int(binary.LittleEndian.Uint32(page))
+		if size > len(page)-4 {
+			return errParquet
+		}
+		err := setParquetBytes(&rows[i], col.name, page[4:4+size])
+		if err != nil {
+			return err
+		}
+		page = page[4+size:]
+	}
+	return nil
+}
+
+// sealed returns the encrypted payload of m, which is set up on first use.
+func sealed(m *model.LogModel) *model.Sealed {
+	if m.Sealed == nil {
+		m.Sealed = &model.Sealed{}
+	}
+	return m.Sealed
+}
+
+func setParquetInt64(m *model.LogModel, name string, v int64) {
+	switch name {
+	case "id":
+		m.ID = uint64(v)
+	case "created_at":
+		m.CreatedAt = time.UnixMicro(v).UTC()
+	case "data_key_id":
+		sealed(m).DataKeyID = uint64(v)
+	}
+}
+
+func setParquetBytes(m *model.LogModel, name string, v []byte) error {
+	s := string(v)
+	switch name {
+	case "log_level":
+		m.LogLevel = s
+	case "source":
+		m.Source = s
+	case "logger_name":
+		m.LoggerName = s
+	case "request_id":
+		m.RequestID = &s
+	case "trace_id":
+		m.TraceID = &s
+	case "span_id":
+		m.SpanID = &s
+	case "parent_span_id":
+		m.ParentSpanID = &s
+	case "raw_log":
+		m.RawLog = s
+	case "attributes":
+		return json.Unmarshal(v, &m.Attributes)
+	case "raw_log_enc":
+		sealed(m).RawLog = bytes.Clone(v)
+	case "attributes_enc":
+		sealed(m).Attributes = bytes.Clone(v)
+	}
+	return nil
+}
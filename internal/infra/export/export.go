@@ -0,0 +1,90 @@
+package export
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"log_shelter/internal/model"
+)
+
+type Format string
+
+const (
+	FormatNDJSON  Format = "ndjson"
+	FormatCSV     Format = "csv"
+	FormatParquet Format = "parquet"
+)
+
+// Encoder writes log entries one by one. Close must be called to flush
+// buffered rows and trailers; it does not close the underlying writer.
+type Encoder interface {
+	Write(entry *model.LogModel) error
+	Close() error
+}
+
+func (f Format) Extension(compressed bool) string {
+	ext := string(f)
+	if compressed && f != FormatParquet {
+		ext += ".gz"
+	}
+	return ext
+}
+
+func (f Format) ContentType(compressed bool) string {
+	if compressed && f != FormatParquet {
+		return "application/gzip"
+	}
+	switch f {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatCSV:
+		return "text/csv"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// gzipEncoder compresses the output of a row oriented encoder as a whole.
+type gzipEncoder struct {
+	Encoder
+	gz *gzip.Writer
+}
+
+func (e *gzipEncoder) Close() error {
+	err := e.Encoder.Close()
+	if err != nil {
+		return err
+	}
+	return e.gz.Close()
+}
+
+// NewEncoder returns an encoder for the format. NDJSON and CSV are gzipped
+// as a stream when compressed is set, Parquet compresses its pages with the
+// GZIP codec instead.
+func NewEncoder(w io.Writer, format Format, compressed bool) (Encoder, error) {
+	if format == FormatParquet {
+		return newParquetEncoder(w, compressed), nil
+	}
+
+	var gz *gzip.Writer
+	if compressed {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+
+	var enc Encoder
+	switch format {
+	case FormatNDJSON:
+		enc = newNDJSONEncoder(w)
+	case FormatCSV:
+		enc = newCSVEncoder(w)
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+
+	if gz != nil {
+		return &gzipEncoder{Encoder: enc, gz: gz}, nil
+	}
+	return enc, nil
+}
@@ -0,0 +1,24 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"log_shelter/internal/model"
+)
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	return &ndjsonEncoder{enc: json.NewEncoder(w)}
+}
+
+func (e *ndjsonEncoder) Write(entry *model.LogModel) error {
+	return e.enc.Encode(entry)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
@@ -0,0 +1,368 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+
+	"log_shelter/internal/model"
+)
+
+// Subset of the Parquet format enums, see parquet.thrift.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetUncompressed = 0
+	parquetGzip         = 2
+
+	parquetDataPage = 0
+)
+
+const (
+	parquetMagic        = "PAR1"
+	parquetRowGroupSize = 8192
+	parquetCreatedBy    = "log_shelter"
+)
+
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	optional  bool
+	int64     func(*model.LogModel) (int64, bool)
+	bytes     func(*model.LogModel) ([]byte, bool)
+}
+
+func utf8Column(name string, optional bool, fn func(*model.LogModel) ([]byte, bool)) parquetColumn {
+	return parquetColumn{
+		name:      name,
+		typ:       parquetByteArray,
+		converted: parquetUTF8,
+		optional:  optional,
+		bytes:     fn,
+	}
+}
+
+func requiredString(fn func(*model.LogModel) string) func(*model.LogModel) ([]byte, bool) {
+	return func(m *model.LogModel) ([]byte, bool) {
+		return []byte(fn(m)), true
+	}
+}
+
+func optionalString(fn func(*model.LogModel) *string) func(*model.LogModel) ([]byte, bool) {
+	return func(m *model.LogModel) ([]byte, bool) {
+		v := fn(m)
+		if v == nil {
+			return nil, false
+		}
+		return []byte(*v), true
+	}
+}
+
+var parquetColumns = []parquetColumn{
+	{
+		name:      "id",
+		typ:       parquetInt64,
+		converted: -1,
+		int64:     func(m *model.LogModel) (int64, bool) { return int64(m.ID), true },
+	},
+	{
+		name:      "created_at",
+		typ:       parquetInt64,
+		converted: parquetTimestampMicros,
+		int64:     func(m *model.LogModel) (int64, bool) { return m.CreatedAt.UnixMicro(), true },
+	},
+	utf8Column("log_level", false,
+		requiredString(func(m *model.LogModel) string { return m.LogLevel })),
+	utf8Column("source", false,
+		requiredString(func(m *model.LogModel) string { return m.Source })),
+	utf8Column("logger_name", true, func(m *model.LogModel) ([]byte, bool) {
+		return []byte(m.LoggerName), m.LoggerName != ""
+	}),
+	utf8Column("request_id", true,
+		optionalString(func(m *model.LogModel) *string { return m.RequestID })),
+	utf8Column("trace_id", true,
+		optionalString(func(m *model.LogModel) *string { return m.TraceID })),
+	utf8Column("span_id", true,
+		optionalString(func(m *model.LogModel) *string { return m.SpanID })),
+	utf8Column("parent_span_id", true,
+		optionalString(func(m *model.LogModel) *string { return m.ParentSpanID })),
+	utf8Column("raw_log", false,
+		requiredString(func(m *model.LogModel) string { return m.RawLog })),
+	utf8Column("attributes", true, func(m *model.LogModel) ([]byte, bool) {
+		if len(m.Attributes) == 0 {
+			return nil, false
+		}
+		data, err := json.Marshal(m.Attributes)
+		return data, err == nil
+	}),
+	// Encrypted logs are archived as stored, their payload stays sealed.
+	{
+		name:      "data_key_id",
+		typ:       parquetInt64,
+		converted: -1,
+		optional:  true,
+		int64: func(m *model.LogModel) (int64, bool) {
+			if m.Sealed == nil {
+				return 0, false
+			}
+			return int64(m.Sealed.DataKeyID), true
+		},
+	},
+	{
+		name:      "raw_log_enc",
+		typ:       parquetByteArray,
+		converted: -1,
+		optional:  true,
+		bytes: func(m *model.LogModel) ([]byte, bool) {
+			if m.Sealed == nil {
+				return nil, false
+			}
+			return m.Sealed.RawLog, true
+		},
+	},
+	{
+		name:      "attributes_enc",
+		typ:       parquetByteArray,
+		converted: -1,
+		optional:  true,
+		bytes: func(m *model.LogModel) ([]byte, bool) {
+			if m.Sealed == nil || m.Sealed.Attributes == nil {
+				return nil, false
+			}
+			return m.Sealed.Attributes, true
+		},
+	},
+}
+
+type parquetChunk struct {
+	offset            int64
+	uncompressed_size int64
+	compressed_size   int64
+}
+
+type parquetRowGroup struct {
+	rows   int64
+	chunks []parquetChunk
+}
+
+// parquetEncoder writes a single Parquet file with one uncompressed or
+// gzipped PLAIN data page per column chunk. Rows are buffered per row group
+// only, so memory use doesn't depend on the size of the export.
+type parquetEncoder struct {
+	w          io.Writer
+	offset     int64
+	compressed bool
+	rows       []model.LogModel
+	row_groups []parquetRowGroup
+	err        error
+}
+
+func newParquetEncoder(w io.Writer, compressed bool) *parquetEncoder {
+	return &parquetEncoder{
+		w:          w,
+		compressed: compressed,
+		rows:       make([]model.LogModel, 0, parquetRowGroupSize),
+	}
+}
+
+func (e *parquetEncoder) write(data []byte) {
+	if e.err != nil {
+		return
+	}
+	n, err := e.w.Write(data)
+	e.offset += int64(n)
+	e.err = err
+}
+
+func (e *parquetEncoder) Write(entry *model.LogModel) error {
+	if e.offset == 0 {
+		e.write([]byte(parquetMagic))
+	}
+	e.rows = append(e.rows, *entry)
+	if len(e.rows) == parquetRowGroupSize {
+		e.flushRowGroup()
+	}
+	return e.err
+}
+
+// encodeLevels encodes definition levels with the RLE/bit-packed hybrid
+// encoding (bit width 1) prefixed by its length, as data page v1 expects.
+func encodeLevels(levels []byte) []byte {
+	var runs []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		runs = binary.AppendUvarint(runs, uint64(j-i)<<1)
+		runs = append(runs, levels[i])
+		i = j
+	}
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(runs))), runs...)
+}
+
+func (e *parquetEncoder) encodeColumn(col parquetColumn) []byte {
+	var levels []byte
+	var values bytes.Buffer
+
+	for i := range e.rows {
+		row := &e.rows[i]
+		var v int64
+		var data []byte
+		var ok bool
+		if col.typ == parquetInt64 {
+			v, ok = col.int64(row)
+		} else {
+			data, ok = col.bytes(row)
+		}
+		if col.optional {
+			if !ok {
+				levels = append(levels, 0)
+				continue
+			}
+			levels = append(levels, 1)
+		}
+		if col.typ == parquetInt64 {
+			values.Write(binary.LittleEndian.AppendUint64(nil, uint64(v)))
+			continue
+		}
+		values.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(data))))
+		values.Write(data)
+	}
+
+	if col.optional {
+		return append(encodeLevels(levels), values.Bytes()...)
+	}
+	return values.Bytes()
+}
+
+func (e *parquetEncoder) compress(data []byte) []byte {
+	if !e.compressed {
+		return data
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(data)
+	gz.Close()
+	return buf.Bytes()
+}
+
+func (e *parquetEncoder) flushRowGroup() {
+	if len(e.rows) == 0 || e.err != nil {
+		return
+	}
+
+	group := parquetRowGroup{rows: int64(len(e.rows))}
+	for _, col := range parquetColumns {
+		page := e.encodeColumn(col)
+		compressed := e.compress(page)
+
+		var header thriftWriter
+		header.I32(1, parquetDataPage)
+		header.I32(2, int32(len(page)))
+		header.I32(3, int32(len(compressed)))
+		header.Struct(5, func() {
+			header.I32(1, int32(len(e.rows)))
+			header.I32(2, parquetPlain)
+			header.I32(3, parquetRLE)
+			header.I32(4, parquetRLE)
+		})
+		header_bytes := header.Bytes()
+
+		chunk := parquetChunk{
+			offset:            e.offset,
+			uncompressed_size: int64(len(header_bytes) + len(page)),
+			compressed_size:   int64(len(header_bytes) + len(compressed)),
+		}
+		e.write(header_bytes)
+		e.write(compressed)
+		group.chunks = append(group.chunks, chunk)
+	}
+
+	e.row_groups = append(e.row_groups, group)
+	e.rows = e.rows[:0]
+}
+
+func (e *parquetEncoder) footer() []byte {
+	codec := int32(parquetUncompressed)
+	if e.compressed {
+		codec = parquetGzip
+	}
+
+	var rows int64
+	for _, g := range e.row_groups {
+		rows += g.rows
+	}
+
+	var t thriftWriter
+	t.I32(1, 1)
+	t.StructList(2, len(parquetColumns)+1, func(i int) {
+		if i == 0 {
+			t.String(4, "schema")
+			t.I32(5, int32(len(parquetColumns)))
+			return
+		}
+		col := parquetColumns[i-1]
+		repetition := int32(parquetRequired)
+		if col.optional {
+			repetition = parquetOptional
+		}
+		t.I32(1, col.typ)
+		t.I32(3, repetition)
+		t.String(4, col.name)
+		if col.converted >= 0 {
+			t.I32(6, col.converted)
+		}
+	})
+	t.I64(3, rows)
+	t.StructList(4, len(e.row_groups), func(i int) {
+		group := e.row_groups[i]
+		var total int64
+		t.StructList(1, len(group.chunks), func(j int) {
+			chunk := group.chunks[j]
+			col := parquetColumns[j]
+			total += chunk.uncompressed_size
+			t.I64(2, chunk.offset)
+			t.Struct(3, func() {
+				t.I32(1, col.typ)
+				t.I32List(2, []int32{parquetPlain, parquetRLE})
+				t.StringList(3, []string{col.name})
+				t.I32(4, codec)
+				t.I64(5, group.rows)
+				t.I64(6, chunk.uncompressed_size)
+				t.I64(7, chunk.compressed_size)
+				t.I64(9, chunk.offset)
+			})
+		})
+		t.I64(2, total)
+		t.I64(3, group.rows)
+	})
+	t.String(6, parquetCreatedBy)
+	return t.Bytes()
+}
+
+func (e *parquetEncoder) Close() error {
+	if e.offset == 0 {
+		e.write([]byte(parquetMagic))
+	}
+	e.flushRowGroup()
+
+	footer := e.footer()
+	e.write(footer)
+	e.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	e.write([]byte(parquetMagic))
+	return e.err
+}
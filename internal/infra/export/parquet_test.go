@@ -0,0 +1,370 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"log_shelter/internal/model"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// sampleLogs returns n logs alternating set and unset optional columns.
+func sampleLogs(n int) []model.LogModel {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	ret := make([]model.LogModel, n)
+	for i := range ret {
+		ret[i] = model.LogModel{
+			ID:        uint64(i + 1),
+			RawLog:    fmt.Sprintf("request %d served in %dms", i, i%250),
+			LogLevel:  []string{"DEBUG", "INFO", "ERROR"}[i%3],
+			Source:    []string{"payments", "billing-api"}[i%2],
+			CreatedAt: start.Add(time.Duration(i) * 1500 * time.Microsecond),
+		}
+		if i%2 == 0 {
+			ret[i].LoggerName = "http"
+			ret[i].RequestID = ptr(fmt.Sprintf("req-%d", i))
+		}
+		if i%3 == 0 {
+			ret[i].TraceID = ptr("4bf92f3577b34da6a3ce929d0e0e4736")
+			ret[i].SpanID = ptr("00f067aa0ba902b7")
+			ret[i].ParentSpanID = ptr("53995c3f42cd8ad8")
+		}
+		if i%5 == 0 {
+			ret[i].Attributes = map[string]any{
+				"status": float64(500),
+				"amount": 1.5e6,
+				"user":   "łukasz",
+				"tags":   []any{"a", "b"},
+			}
+		}
+		if i%7 == 3 {
+			ret[i].RawLog, ret[i].Attributes = "", nil
+			ret[i].Sealed = &model.Sealed{
+				DataKeyID: uint64(i%4 + 1),
+				RawLog:    []byte{0, 1, 2, byte(i)},
+			}
+			if i%2 == 0 {
+				ret[i].Sealed.Attributes = []byte{0xff, byte(i), 0}
+			}
+		}
+	}
+	return ret
+}
+
+func TestNDJSONRoundTrip(t *testing.T) {
+	logs := sampleLogs(50)
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, FormatNDJSON, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range logs {
+		err := enc.Write(&logs[i])
+		if err != nil {
+			t.Fatalf("write row %d: %v", i, err)
+		}
+	}
+	err = enc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := NewDecoder(&buf, FormatNDJSON, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range logs {
+		got, err := dec.Read()
+		if err != nil {
+			t.Fatalf("read row %d: %v", i, err)
+		}
+		got.CreatedAt = got.CreatedAt.UTC()
+		if !reflect.DeepEqual(*got, logs[i]) {
+			t.Fatalf("row %d:\n got %+v\nwant %+v", i, *got, logs[i])
+		}
+	}
+	_, err = dec.Read()
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("read past the last row: got %v, want io.EOF", err)
+	}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows       int
+		compressed bool
+	}{
+		{name: "empty", rows: 0},
+		{name: "single row", rows: 1},
+		{name: "single row gzip", rows: 1, compressed: true},
+		{name: "several row groups", rows: 2*parquetRowGroupSize + 7},
+		{name: "several row groups gzip", rows: parquetRowGroupSize + 1, compressed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := sampleLogs(tt.rows)
+
+			var buf bytes.Buffer
+			enc, err := NewEncoder(&buf, FormatParquet, tt.compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range logs {
+				err := enc.Write(&logs[i])
+				if err != nil {
+					t.Fatalf("write row %d: %v", i, err)
+				}
+			}
+			err = enc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dec, err := NewDecoder(&buf, FormatParquet, tt.compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range logs {
+				got, err := dec.Read()
+				if err != nil {
+					t.Fatalf("read row %d: %v", i, err)
+				}
+				got.CreatedAt = got.CreatedAt.UTC()
+				if !reflect.DeepEqual(*got, logs[i]) {
+					t.Fatalf("row %d:\n got %+v\nwant %+v", i, *got, logs[i])
+				}
+			}
+			_, err = dec.Read()
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("read past the last row: got %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestThriftRoundTrip(t *testing.T) {
+	var w thriftWriter
+	w.I32(1, -7)
+	w.I64(2, 1<<40)
+	w.String(3, "log_shelter")
+	w.Struct(4, func() {
+		w.I64(1, 42)
+		w.StringList(2, []string{"a", "", "ccc"})
+	})
+	w.I32List(20, []int32{0, 3, -1, 1 << 20})
+	w.StructList(21, 2, func(i int) {
+		w.I32(1, int32(i))
+	})
+
+	r := thriftReader{data: w.Bytes()}
+	got := r.Struct()
+	want := map[int16]any{
+		1: int64(-7),
+		2: int64(1 << 40),
+		3: []byte("log_shelter"),
+		4: map[int16]any{
+			1: int64(42),
+			2: []any{[]byte("a"), []byte(""), []byte("ccc")},
+		},
+		20: []any{int64(0), int64(3), int64(-1), int64(1 << 20)},
+		21: []any{map[int16]any{1: int64(0)}, map[int16]any{1: int64(1)}},
+	}
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v\nwant %#v", got, want)
+	}
+}
+
+// TestThriftCompactBytes checks the writer against encodings worked out by
+// hand from the Thrift compact protocol specification.
+func TestThriftCompactBytes(t *testing.T) {
+	var w thriftWriter
+	w.I32(1, -7)
+	w.I64(2, 1<<40)
+	w.String(3, "ab")
+	w.I32List(4, []int32{1, -1})
+	w.Struct(20, func() {
+		w.I64(1, 300)
+	})
+	w.StringList(21, make([]string, 15))
+
+	want := []byte{
+		0x15, 0x0d, // field 1 delta 1 i32, zigzag(-7)
+		0x16, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40, // field 2 i64, zigzag(1<<40) = 1<<41
+		0x18, 0x02, 'a', 'b', // field 3 binary, length 2
+		0x19, 0x25, 0x02, 0x01, // field 4 list of 2 i32
+		0x0c, 0x28, // field 20 struct, delta over 15 so id as zigzag varint
+		0x16, 0xd8, 0x04, 0x00, // nested field 1 i64 zigzag(300), stop
+		0x19, 0xf8, 0x0f, // field 21 list of binary, size 15 in a varint
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // 15 empty strings
+		0x00, // stop
+	}
+	got := w.Bytes()
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got  % x\nwant % x", got, want)
+	}
+}
+
+// TestParquetLayout walks a one row file by the offsets of its footer and
+// checks every structure against parquet.thrift and the PLAIN and RLE
+// encodings of the format specification, without the Parquet decoder.
+func TestParquetLayout(t *testing.T) {
+	created_at := time.Date(2026, 10, 19, 12, 0, 0, 123456000, time.UTC)
+	entry := model.LogModel{
+		ID:        1,
+		RawLog:    "hello",
+		LogLevel:  "INFO",
+		Source:    "api",
+		RequestID: ptr("r1"),
+		CreatedAt: created_at,
+	}
+
+	var buf bytes.Buffer
+	enc, err := NewEncoder(&buf, FormatParquet, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enc.Write(&entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	int64Value := func(v int64) []byte {
+		return binary.LittleEndian.AppendUint64(nil, uint64(v))
+	}
+	byteArray := func(s string) []byte {
+		return append(binary.LittleEndian.AppendUint32(nil, uint32(len(s))), s...)
+	}
+	// One definition level of bit width 1 as a single RLE run, prefixed by
+	// the 4 byte length of the encoded levels.
+	null := []byte{2, 0, 0, 0, 0x02, 0x00}
+	present := func(value []byte) []byte {
+		return append([]byte{2, 0, 0, 0, 0x02, 0x01}, value...)
+	}
+
+	type column struct {
+		name      string
+		typ       int64
+		optional  bool
+		converted int64
+		page      []byte
+	}
+	const (
+		int64Type     = 2
+		byteArrayType = 6
+		utf8          = 0
+		noConversion  = -1
+	)
+	columns := []column{
+		{"id", int64Type, false, noConversion, int64Value(1)},
+		{"created_at", int64Type, false, 10, int64Value(created_at.UnixMicro())},
+		{"log_level", byteArrayType, false, utf8, byteArray("INFO")},
+		{"source", byteArrayType, false, utf8, byteArray("api")},
+		{"logger_name", byteArrayType, true, utf8, null},
+		{"request_id", byteArrayType, true, utf8, present(byteArray("r1"))},
+		{"trace_id", byteArrayType, true, utf8, null},
+		{"span_id", byteArrayType, true, utf8, null},
+		{"parent_span_id", byteArrayType, true, utf8, null},
+		{"raw_log", byteArrayType, false, utf8, byteArray("hello")},
+		{"attributes", byteArrayType, true, utf8, null},
+		{"data_key_id", int64Type, true, noConversion, null},
+		{"raw_log_enc", byteArrayType, true, noConversion, null},
+		{"attributes_enc", byteArrayType, true, noConversion, null},
+	}
+
+	// PageHeader{type: DATA_PAGE, uncompressed_page_size, compressed_page_size,
+	// data_page_header: DataPageHeader{num_values: 1, encoding: PLAIN,
+	// definition_level_encoding: RLE, repetition_level_encoding: RLE}}.
+	pageHeader := func(size int) []byte {
+		return []byte{
+			0x15, 0x00,
+			0x15, byte(2 * size),
+			0x15, byte(2 * size),
+			0x2c, 0x15, 0x02, 0x15, 0x00, 0x15, 0x06, 0x15, 0x06, 0x00,
+			0x00,
+		}
+	}
+
+	body := []byte(parquetMagic)
+	schema := []any{map[int16]any{4: []byte("schema"), 5: int64(len(columns))}}
+	var chunks []any
+	var total int64
+	for _, col := range columns {
+		offset := int64(len(body))
+		page := append(pageHeader(len(col.page)), col.page...)
+		body = append(body, page...)
+		total += int64(len(page))
+
+		repetition := int64(0)
+		if col.optional {
+			repetition = 1
+		}
+		element := map[int16]any{1: col.typ, 3: repetition, 4: []byte(col.name)}
+		if col.converted != noConversion {
+			element[6] = col.converted
+		}
+		schema = append(schema, element)
+		chunks = append(chunks, map[int16]any{
+			2: offset,
+			3: map[int16]any{
+				1: col.typ,
+				2: []any{int64(0), int64(3)},
+				3: []any{[]byte(col.name)},
+				4: int64(0),
+				5: int64(1),
+				6: int64(len(page)),
+				7: int64(len(page)),
+				9: offset,
+			},
+		})
+	}
+
+	if !bytes.HasPrefix(data, body) {
+		t.Fatalf("column chunks:\n got % x\nwant % x", data[:min(len(data), len(body))], body)
+	}
+	if string(data[len(data)-4:]) != parquetMagic {
+		t.Fatalf("trailing magic: got %q", data[len(data)-4:])
+	}
+	footer_len := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if len(body)+footer_len+8 != len(data) {
+		t.Fatalf("footer length %d doesn't follow the column chunks", footer_len)
+	}
+
+	r := thriftReader{data: data[len(body) : len(body)+footer_len]}
+	got := r.Struct()
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.pos != len(r.data) {
+		t.Fatalf("footer has %d trailing bytes", len(r.data)-r.pos)
+	}
+	want := map[int16]any{
+		1: int64(1),
+		2: schema,
+		3: int64(1),
+		4: []any{map[int16]any{1: chunks, 2: total, 3: int64(1)}},
+		6: []byte(parquetCreatedBy),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("FileMetaData:\n got %#v\nwant %#v", got, want)
+	}
+}
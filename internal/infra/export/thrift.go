@@ -0,0 +1,236 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Thrift compact protocol type ids, as used by the Parquet footer and page
+// headers.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter is a minimal write-only implementation of the Thrift compact
+// protocol, enough to serialize Parquet metadata structures.
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func (t *thriftWriter) uvarint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	delta := id - t.lastID
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) I32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) I64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) String(id int16, v string) {
+	t.fieldHeader(id, thriftBinary)
+	t.uvarint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+// Struct writes a nested struct field; fields are written by fn.
+func (t *thriftWriter) Struct(id int16, fn func()) {
+	t.fieldHeader(id, thriftStruct)
+	t.nested(fn)
+}
+
+func (t *thriftWriter) nested(fn func()) {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+	fn()
+	t.buf.WriteByte(0)
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) listHeader(id int16, elem byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.uvarint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) I32List(id int16, values []int32) {
+	t.listHeader(id, thriftI32, len(values))
+	for _, v := range values {
+		t.varint(int64(v))
+	}
+}
+
+func (t *thriftWriter) StringList(id int16, values []string) {
+	t.listHeader(id, thriftBinary, len(values))
+	for _, v := range values {
+		t.uvarint(uint64(len(v)))
+		t.buf.WriteString(v)
+	}
+}
+
+// StructList writes a list of size structs; fn writes the fields of the
+// i-th element.
+func (t *thriftWriter) StructList(id int16, size int, fn func(i int)) {
+	t.listHeader(id, thriftStruct, size)
+	for i := range size {
+		t.nested(func() { fn(i) })
+	}
+}
+
+// Bytes terminates the top-level struct and returns the encoded message.
+func (t *thriftWriter) Bytes() []byte {
+	t.buf.WriteByte(0)
+	return t.buf.Bytes()
+}
+
+// thriftReader decodes Thrift compact protocol messages generically:
+// structs become maps by field id, lists become slices, integers int64 and
+// binaries []byte. It is enough to read back Parquet metadata.
+type thriftReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (t *thriftReader) byte() byte {
+	if t.err != nil {
+		return 0
+	}
+	if t.pos >= len(t.data) {
+		t.err = errThriftShort
+		return 0
+	}
+	t.pos++
+	return t.data[t.pos-1]
+}
+
+func (t *thriftReader) uvarint() uint64 {
+	if t.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(t.data[t.pos:])
+	if n <= 0 {
+		t.err = errThriftShort
+		return 0
+	}
+	t.pos += n
+	return v
+}
+
+func (t *thriftReader) varint() int64 {
+	v := t.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (t *thriftReader) bytes(n int) []byte {
+	if t.err != nil {
+		return nil
+	}
+	if n < 0 || t.pos+n > len(t.data) {
+		t.err = errThriftShort
+		return nil
+	}
+	t.pos += n
+	return t.data[t.pos-n : t.pos]
+}
+
+// Struct reads the fields of a struct up to its stop byte.
+func (t *thriftReader) Struct() map[int16]any {
+	ret := map[int16]any{}
+	var id int16
+	for t.err == nil {
+		header := t.byte()
+		if header == 0 {
+			break
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(t.varint())
+		}
+		ret[id] = t.value(header & 0x0f)
+	}
+	return ret
+}
+
+func (t *thriftReader) list() []any {
+	header := t.byte()
+	size := int(header >> 4)
+	if size == 15 {
+		size = int(t.uvarint())
+	}
+	ret := make([]any, 0, min(size, len(t.data)))
+	for i := 0; i < size && t.err == nil; i++ {
+		ret = append(ret, t.value(header&0x0f))
+	}
+	return ret
+}
+
+func (t *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(t.byte())
+	case 4, thriftI32, thriftI64:
+		return t.varint()
+	case 7:
+		return t.bytes(8)
+	case thriftBinary:
+		return t.bytes(int(t.uvarint()))
+	case thriftList, 10:
+		return t.list()
+	case 11:
+		size := int(t.uvarint())
+		if size == 0 {
+			return []any{}
+		}
+		types := t.byte()
+		ret := make([]any, 0, min(2*size, len(t.data)))
+		for i := 0; i < size && t.err == nil; i++ {
+			ret = append(ret, t.value(types>>4), t.value(types&0x0f))
+		}
+		return ret
+	case thriftStruct:
+		return t.Struct()
+	default:
+		t.err = fmt.Errorf("thrift: unknown type %d", typ)
+		return nil
+	}
+}
+
+var errThriftShort = errors.New("thrift: unexpected end of data")
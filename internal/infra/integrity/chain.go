@@ -0,0 +1,91 @@
+// Package integrity makes the logs of selected sources tamper-evident.
+// Every log of such a source is linked into a per-source hash chain when
+// it's appended, and the chain heads are periodically signed.
+package integrity
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"hash"
+	"time"
+
+	"log_shelter/internal/model"
+)
+
+// Genesis is the hash preceding the first link of every chain.
+var Genesis = make([]byte, sha256.Size)
+
+// Link returns the link of entry following prev.
+func Link(prev model.ChainLink, entry *model.LogModel) (model.ChainLink, error) {
+	seq := prev.Seq + 1
+	sum, err := Hash(prev.Hash, seq, entry)
+	if err != nil {
+		return model.ChainLink{}, err
+	}
+	return model.ChainLink{Seq: seq, Hash: sum}, nil
+}
+
+// Hash covers prev, seq and everything the log was appended with. Every
+// field is length-prefixed and optional fields carry a presence byte, so
+// distinct logs can't encode the same. Timestamps are hashed as the
+// microseconds Postgres keeps.
+func Hash(prev []byte, seq uint64, entry *model.LogModel) ([]byte, error) {
+	h := sha256.New()
+	h.Write(prev)
+	writeUint(h, seq)
+	writeString(h, entry.Source)
+	writeUint(h, uint64(entry.CreatedAt.UnixMicro()))
+	writeString(h, entry.LogLevel)
+	writeOptional(h, entry.RawLevel)
+	writeString(h, entry.RawLog)
+	writeOptional(h, entry.RequestID)
+	writeString(h, entry.LoggerName)
+	writeOptional(h, entry.TraceID)
+	writeOptional(h, entry.SpanID)
+	writeOptional(h, entry.ParentSpanID)
+
+	// Maps marshal with sorted keys, which keeps the encoding stable
+	// across a round trip through jsonb.
+	var attributes []byte
+	if len(entry.Attributes) != 0 {
+		data, err := json.Marshal(entry.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		attributes = data
+	}
+	writeBytes(h, attributes)
+
+	return h.Sum(nil), nil
+}
+
+// Truncate drops the precision a timestamp loses when stored, so the hash
+// computed on append matches the one computed on verification.
+func Truncate(t time.Time) time.Time {
+	return t.Truncate(time.Microsecond)
+}
+
+func writeUint(h hash.Hash, v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	h.Write(buf[:])
+}
+
+func writeBytes(h hash.Hash, b []byte) {
+	writeUint(h, uint64(len(b)))
+	h.Write(b)
+}
+
+func writeString(h hash.Hash, s string) {
+	writeBytes(h, []byte(s))
+}
+
+func writeOptional(h hash.Hash, s *string) {
+	if s == nil {
+		h.Write([]byte{0})
+		return
+	}
+	h.Write([]byte{1})
+	writeString(h, *s)
+}
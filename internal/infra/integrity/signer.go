@@ -0,0 +1,97 @@
+package integrity
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"log_shelter/internal/model"
+)
+
+// Signer signs chain checkpoints and tombstones with an Ed25519 key.
+type Signer struct {
+	key   ed25519.PrivateKey
+	KeyID string
+}
+
+// LoadSigner reads a base64 Ed25519 seed or private key from path.
+func LoadSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("signing key %s: %w", path, err)
+	}
+
+	var key ed25519.PrivateKey
+	switch len(raw) {
+	case ed25519.SeedSize:
+		key = ed25519.NewKeyFromSeed(raw)
+	case ed25519.PrivateKeySize:
+		key = ed25519.PrivateKey(raw)
+	default:
+		return nil, fmt.Errorf("signing key %s: expected %d or %d bytes, got %d",
+			path, ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+
+	sum := sha256.Sum256(key.Public().(ed25519.PublicKey))
+	return &Signer{key: key, KeyID: hex.EncodeToString(sum[:8])}, nil
+}
+
+// message is what a checkpoint signature covers.
+func message(c *model.ChainCheckpoint) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("log_shelter.checkpoint.v1\n")
+	fmt.Fprintf(&buf, "%s\n%d\n%x\n%d\n", c.Source, c.Seq, c.Hash, c.SignedAt.UnixMicro())
+	return buf.Bytes()
+}
+
+// Checkpoint signs head as of now.
+func (s *Signer) Checkpoint(head model.ChainHead, now time.Time) model.ChainCheckpoint {
+	ret := model.ChainCheckpoint{
+		Source:   head.Source,
+		Seq:      head.Link.Seq,
+		Hash:     head.Link.Hash,
+		KeyID:    s.KeyID,
+		SignedAt: Truncate(now),
+	}
+	ret.Signature = ed25519.Sign(s.key, message(&ret))
+	return ret
+}
+
+// Verify tells whether c was signed by this key.
+func (s *Signer) Verify(c model.ChainCheckpoint) bool {
+	if c.KeyID != s.KeyID {
+		return false
+	}
+	return ed25519.Verify(s.key.Public().(ed25519.PublicKey), message(&c), c.Signature)
+}
+
+// tombstoneMessage is what a tombstone signature covers.
+func tombstoneMessage(t *model.ChainTombstone) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("log_shelter.tombstone.v1\n")
+	fmt.Fprintf(&buf, "%s\n%d\n%d\n%x\n%d\n", t.Source, t.FromSeq, t.ToSeq, t.Hash, t.DeletedAt.UnixMicro())
+	return buf.Bytes()
+}
+
+// SignTombstone signs t in place.
+func (s *Signer) SignTombstone(t *model.ChainTombstone) {
+	t.KeyID = s.KeyID
+	t.Signature = ed25519.Sign(s.key, tombstoneMessage(t))
+}
+
+// VerifyTombstone tells whether t was signed by this key.
+func (s *Signer) VerifyTombstone(t model.ChainTombstone) bool {
+	if t.KeyID != s.KeyID {
+		return false
+	}
+	return ed25519.Verify(s.key.Public().(ed25519.PublicKey), tombstoneMessage(&t), t.Signature)
+}
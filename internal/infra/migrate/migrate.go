@@ -0,0 +1,247 @@
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// advisoryLockKey serializes migrations between instances started at the
+// same time. It is an arbitrary constant, "logshelt" in ASCII.
+const advisoryLockKey = 0x6c6f677368656c74
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// Load reads NNNN_name.up.sql and NNNN_name.down.sql files from the root of
+// fsys. Every version needs an up migration, down migrations are optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	by_version := map[int64]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := by_version[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			by_version[version] = migration
+		}
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q",
+				version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	ret := make([]Migration, 0, len(by_version))
+	for _, m := range by_version {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		ret = append(ret, *m)
+	}
+	slices.SortFunc(ret, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return ret, nil
+}
+
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// locked runs fn on a single connection holding the migration advisory
+// lock, creating the schema_migrations table if needed.
+func (m *Migrator) locked(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockKey)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockKey)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var applied_at time.Time
+		err = rows.Scan(&version, &applied_at)
+		if err != nil {
+			return nil, err
+		}
+		ret[version] = applied_at
+	}
+	return ret, rows.Err()
+}
+
+// step runs a single migration and records it in one transaction.
+func step(ctx context.Context, conn *sql.Conn, script string, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, script)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.ExecContext(ctx, record, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up applies pending migrations up to and including target, or all of them
+// when target is 0, and returns the applied versions.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]int64, error) {
+	ret := make([]int64, 0)
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if target != 0 && migration.Version > target {
+				break
+			}
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			err = step(ctx, conn, migration.Up,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+				migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("Migration applied", "version", migration.Version, "name", migration.Name)
+			ret = append(ret, migration.Version)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// Down reverts the last steps applied migrations and returns the reverted
+// versions.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]int64, error) {
+	ret := make([]int64, 0)
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(ret) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file",
+					migration.Version, migration.Name)
+			}
+			err = step(ctx, conn, migration.Down,
+				"DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			slog.Info("Migration reverted", "version", migration.Version, "name", migration.Name)
+			ret = append(ret, migration.Version)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// Status lists every known migration with the time it was applied at.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	ret := make([]Status, 0, len(m.migrations))
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if applied_at, ok := done[migration.Version]; ok {
+				status.AppliedAt = &applied_at
+			}
+			ret = append(ret, status)
+		}
+		return nil
+	})
+	return ret, err
+}
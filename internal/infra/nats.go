@@ -1,6 +1,7 @@
 package infra
 
 import (
+	"errors"
 	"log/slog"
 	"sync/atomic"
 
@@ -62,3 +63,17 @@ func NewNatsInfra(cfg *config.NatsConfig) (*NatsInfra, error) {
 
 	return &ret, nil
 }
+
+// ObjectStore returns the JetStream object store bucket, creating it on
+// first use.
+func (n *NatsInfra) ObjectStore(bucket string) (nats.ObjectStore, error) {
+	js, err := n.Conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	store, err := js.ObjectStore(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		return js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: bucket})
+	}
+	return store, err
+}
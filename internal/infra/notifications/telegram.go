@@ -8,13 +8,15 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"log_shelter/internal/config"
+	"log_shelter/internal/model"
 )
 
 type TelegramNotifications struct {
-	bot     *tgbotapi.BotAPI
-	levels  []string
-	users   []int64
-	enabled bool
+	bot       *tgbotapi.BotAPI
+	levels    []string
+	min_level *model.Level
+	users     []int64
+	enabled   bool
 }
 
 type NotifyLogModel struct {
@@ -36,16 +38,33 @@ func NewTelegramNotifications(cfg *config.TelegramConfig) (*TelegramNotification
 	} else {
 		bot = nil
 	}
+
+	var min_level *model.Level
+	if cfg.MinLevel != "" {
+		l, ok := model.ParseLevel(cfg.MinLevel)
+		if !ok {
+			return nil, fmt.Errorf("unknown telegram min_level %q", cfg.MinLevel)
+		}
+		min_level = &l
+	}
+
 	return &TelegramNotifications{
-		bot:     bot,
-		levels:  cfg.Levels,
-		users:   cfg.NotificateTo,
-		enabled: cfg.Enabled,
+		bot:       bot,
+		levels:    model.NormalizeLevels(cfg.Levels),
+		min_level: min_level,
+		users:     cfg.NotificateTo,
+		enabled:   cfg.Enabled,
 	}, nil
 }
 
+// ShouldNotify reports whether the level reaches min_level, or is one of
+// the configured levels when no threshold is set.
 func (t *TelegramNotifications) ShouldNotify(logLevel string) bool {
-	return slices.Contains(t.levels, logLevel)
+	if t.min_level != nil {
+		l, ok := model.ParseLevel(logLevel)
+		return ok && l >= *t.min_level
+	}
+	return slices.Contains(t.levels, model.NormalizeLevel(logLevel))
 }
 
 func (t *TelegramNotifications) Notify(log NotifyLogModel) {
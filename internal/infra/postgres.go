@@ -3,45 +3,251 @@ package infra
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	_ "github.com/lib/pq"
 
 	"log_shelter/internal/config"
+	"log_shelter/internal/infra/migrate"
+	"log_shelter/internal/model"
+	"log_shelter/migrations"
 )
 
+const (
+	defaultConnectRetries = 5
+	defaultConnectBackoff = time.Second
+	maxConnectBackoff     = 30 * time.Second
+
+	// replicaCooldown is how long a replica that failed to start a
+	// transaction is skipped.
+	replicaCooldown = 30 * time.Second
+	pingTimeout     = 5 * time.Second
+)
+
+type replica struct {
+	name string
+	db   *sql.DB
+
+	mu         sync.Mutex
+	down_until time.Time
+}
+
+func (r *replica) available(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !now.Before(r.down_until)
+}
+
+func (r *replica) markDown(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.down_until = now.Add(replicaCooldown)
+}
+
 type PostgresInfra struct {
-	ctx context.Context
-	cfg *config.PostgresConfig
-	db  *sql.DB
+	ctx      context.Context
+	cfg      *config.PostgresConfig
+	db       *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+func openPool(dsn string, cfg config.PoolConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
+	return db, nil
 }
 
+// NewPostgresInfra opens the primary pool and waits for it to answer a
+// ping, retrying with backoff. Replicas are opened but not waited for,
+// read-only transactions use the primary until they come up.
 func NewPostgresInfra(ctx context.Context, cfg *config.PostgresConfig) (*PostgresInfra, error) {
-	var ret PostgresInfra
-	ret.cfg = cfg
-	ret.ctx = ctx
-	return &ret, nil
+	db, err := openPool(cfg.Dsn(), cfg.Pool)
+	if err != nil {
+		return nil, err
+	}
+	ret := &PostgresInfra{ctx: ctx, cfg: cfg, db: db}
+
+	err = ret.waitPrimary()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	for _, r := range cfg.Replicas {
+		db, err := openPool(cfg.ReplicaDsn(r), cfg.ReplicaPool)
+		if err != nil {
+			ret.Close()
+			return nil, err
+		}
+		ret.replicas = append(ret.replicas, &replica{
+			name: fmt.Sprintf("%s:%d", r.Host, r.Port),
+			db:   db,
+		})
+	}
+
+	return ret, nil
+}
+
+func (p *PostgresInfra) waitPrimary() error {
+	retries := p.cfg.ConnectRetries
+	if retries == 0 {
+		retries = defaultConnectRetries
+	}
+	backoff := time.Duration(p.cfg.ConnectBackoff)
+	if backoff == 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = p.ping(p.db)
+		if err == nil || attempt > retries {
+			break
+		}
+		slog.Warn("Postgres is not reachable, retrying",
+			"attempt", attempt, "backoff", backoff, "err", err)
+		select {
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+	return err
 }
 
+func (p *PostgresInfra) ping(db *sql.DB) error {
+	return ping(p.ctx, db)
+}
+
+func ping(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// poolHealth pings db and reports it along with its pool statistics.
+func poolHealth(ctx context.Context, name string, role string, db *sql.DB) model.PoolHealth {
+	stats := db.Stats()
+	ret := model.PoolHealth{
+		Name:         name,
+		Role:         role,
+		Open:         stats.OpenConnections,
+		InUse:        stats.InUse,
+		Idle:         stats.Idle,
+		WaitCount:    stats.WaitCount,
+		WaitDuration: stats.WaitDuration,
+	}
+	start := time.Now()
+	err := ping(ctx, db)
+	ret.Latency = time.Since(start)
+	ret.Healthy = err == nil
+	if err != nil {
+		ret.Error = err.Error()
+	}
+	return ret
+}
+
+func (p *PostgresInfra) Close() {
+	p.db.Close()
+	for _, r := range p.replicas {
+		r.db.Close()
+	}
+}
+
+// GetTranscation begins a transaction on the primary. Its connection goes
+// back to the pool once the transaction is committed or rolled back.
+func (p *PostgresInfra) GetTranscation() (*sql.Tx, error) {
+	return p.db.BeginTx(p.ctx, nil)
+}
+
+// GetConnection returns a connection to the primary outside of any
+// transaction, for statements Postgres refuses to run in one. It has to be
+// closed to go back to the pool.
 func (p *PostgresInfra) GetConnection() (*sql.Conn, error) {
-	if p.db == nil {
-		pg, err := sql.Open("postgres", p.cfg.Dsn())
+	return p.db.Conn(p.ctx)
+}
+
+// GetReadTranscation begins a read-only transaction on the next available
+// replica, or on the primary when there are none.
+func (p *PostgresInfra) GetReadTranscation() (*sql.Tx, error) {
+	opts := &sql.TxOptions{ReadOnly: true}
+	now := time.Now()
+	start := p.next.Add(1)
+	for i := range uint64(len(p.replicas)) {
+		r := p.replicas[(start+i)%uint64(len(p.replicas))]
+		if !r.available(now) {
+			continue
+		}
+		tx, err := r.db.BeginTx(p.ctx, opts)
 		if err == nil {
-			return nil, err
+			return tx, nil
 		}
-		p.db = pg
+		slog.Warn("Replica is not available, skipping it", "replica", r.name, "err", err)
+		r.markDown(now)
 	}
-	return p.db.Conn(p.ctx)
+	return p.db.BeginTx(p.ctx, opts)
+}
+
+// Health pings the primary and every replica.
+func (p *PostgresInfra) Health() model.Health {
+	ret := model.Health{Status: model.HealthOK, Backend: config.StoragePostgres}
+
+	check := func(name string, role string, db *sql.DB) bool {
+		h := poolHealth(p.ctx, name, role, db)
+		ret.Pools = append(ret.Pools, h)
+		return h.Healthy
+	}
+
+	if !check(fmt.Sprintf("%s:%d", p.cfg.Host, p.cfg.Port), "primary", p.db) {
+		ret.Status = model.HealthDown
+	}
+	for _, r := range p.replicas {
+		if !check(r.name, "replica", r.db) && ret.Status == model.HealthOK {
+			ret.Status = model.HealthDegraded
+		}
+	}
+	return ret
 }
 
-func (p *PostgresInfra) GetTranscation() (*sql.Conn, *sql.Tx, error) {
-	conn, err := p.GetConnection()
+// OpenMigrator returns a migrator for the embedded migrations on its own
+// connection pool, which the caller closes when done.
+func (p *PostgresInfra) OpenMigrator() (*migrate.Migrator, *sql.DB, error) {
+	db, err := sql.Open("postgres", p.cfg.Dsn())
 	if err != nil {
 		return nil, nil, err
 	}
-	tx, err := conn.BeginTx(p.ctx, nil)
+	m, err := migrate.NewMigrator(db, migrations.FS)
 	if err != nil {
-		conn.Close()
+		db.Close()
 		return nil, nil, err
 	}
-	return conn, tx, nil
+	return m, db, nil
+}
+
+// Migrate applies every pending embedded migration.
+func (p *PostgresInfra) Migrate() error {
+	m, db, err := p.OpenMigrator()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = m.Up(p.ctx, 0)
+	return err
 }
@@ -0,0 +1,99 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"log_shelter/internal/model"
+)
+
+type ArchiveReader struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewArchiveReader(
+	ctx context.Context,
+	tx *sql.Tx,
+) *ArchiveReader {
+	return &ArchiveReader{tx: tx, ctx: ctx}
+}
+
+// ReadSegments lists the manifest entries of the sources, "*" patterns
+// included, holding logs created between from and to.
+func (r *ArchiveReader) ReadSegments(
+	sources []string,
+	from *time.Time,
+	to *time.Time,
+	limit uint64,
+) ([]model.ArchiveSegment, error) {
+	q := squirrel.Select(
+		"id",
+		"key",
+		"source",
+		"day",
+		"format",
+		"row_count",
+		"size_bytes",
+		"sha256",
+		"first_id",
+		"last_id",
+		"first_created_at",
+		"last_created_at",
+		"archived_at",
+		"data_key_ids",
+	).From("archive_segments")
+	if !anyOf(sources) {
+		q = q.Where(matchAny("source", sources))
+	}
+	if from != nil {
+		q = q.Where(squirrel.GtOrEq{"last_created_at": *from})
+	}
+	if to != nil {
+		q = q.Where(squirrel.LtOrEq{"first_created_at": *to})
+	}
+	q = q.OrderBy("day", "source", "first_id").Limit(limit)
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.ArchiveSegment, 0)
+
+	for rows.Next() {
+		var entry model.ArchiveSegment
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Key,
+			&entry.Source,
+			&entry.Day,
+			&entry.Format,
+			&entry.Rows,
+			&entry.SizeBytes,
+			&entry.SHA256,
+			&entry.FirstID,
+			&entry.LastID,
+			&entry.FirstCreatedAt,
+			&entry.LastCreatedAt,
+			&entry.ArchivedAt,
+			pq.Array(&entry.DataKeyIDs),
+		)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+
+	return ret, rows.Err()
+}
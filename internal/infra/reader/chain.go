@@ -0,0 +1,243 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/infra/envelope"
+	"log_shelter/internal/model"
+)
+
+type ChainReader struct {
+	ctx context.Context
+	tx  *sql.Tx
+	// keys decrypts encrypted logs, nil without a master key. Chains are
+	// read to verify their hashes, which needs the plaintext but never
+	// returns it, so no permission is checked.
+	keys *envelope.Keyring
+}
+
+func NewChainReader(
+	ctx context.Context,
+	tx *sql.Tx,
+	keys *envelope.Keyring,
+) *ChainReader {
+	return &ChainReader{tx: tx, ctx: ctx, keys: keys}
+}
+
+// ReadHeads returns the head of every chain, or of source only when set.
+func (r *ChainReader) ReadHeads(source *string) ([]model.ChainHead, error) {
+	q := squirrel.Select(
+		"h.source",
+		"h.seq",
+		"h.hash",
+		"coalesce((SELECT max(c.seq) FROM chain_checkpoints c WHERE c.source = h.source), 0)",
+	).From("chain_heads h").OrderBy("h.source")
+	if source != nil {
+		q = q.Where(squirrel.Eq{"h.source": *source})
+	}
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.ChainHead, 0)
+	for rows.Next() {
+		var entry model.ChainHead
+		err := rows.Scan(&entry.Source, &entry.Link.Seq, &entry.Link.Hash, &entry.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+	return ret, rows.Err()
+}
+
+// ReadChain returns up to limit chained logs of source with sequence
+// numbers from from to to, soft-deleted ones included, in chain order.
+func (r *ChainReader) ReadChain(
+	source string,
+	from uint64,
+	to uint64,
+	limit uint64,
+) ([]model.ChainedLog, error) {
+	query, args, err := squirrel.Select(append(logColumns, "chain_seq", "chain_hash")...).
+		From("logs").
+		Where(squirrel.Eq{"source": source}).
+		Where(squirrel.GtOrEq{"chain_seq": from}).
+		Where(squirrel.LtOrEq{"chain_seq": to}).
+		OrderBy("chain_seq").
+		Limit(limit).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.ChainedLog, 0)
+	for rows.Next() {
+		var entry model.ChainedLog
+		entry.LogModel, err = scanLog(rows, &entry.Link.Seq, &entry.Link.Hash)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ret {
+		err = unseal(r.ctx, r.tx, r.keys, &ret[i].LogModel)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// ReadLink returns the link with sequence number seq of source, nil when
+// the log is gone.
+func (r *ChainReader) ReadLink(source string, seq uint64) (*model.ChainLink, error) {
+	ret := model.ChainLink{Seq: seq}
+	err := r.tx.QueryRowContext(r.ctx,
+		"SELECT chain_hash FROM logs WHERE source = $1 AND chain_seq = $2", source, seq,
+	).Scan(&ret.Hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// ReadCheckpoints returns the checkpoints of source between from and to
+// in chain order.
+func (r *ChainReader) ReadCheckpoints(
+	source string,
+	from uint64,
+	to uint64,
+) ([]model.ChainCheckpoint, error) {
+	query, args, err := squirrel.Select(
+		"id",
+		"source",
+		"seq",
+		"hash",
+		"key_id",
+		"signature",
+		"signed_at",
+	).From("chain_checkpoints").
+		Where(squirrel.Eq{"source": source}).
+		Where(squirrel.GtOrEq{"seq": from}).
+		Where(squirrel.LtOrEq{"seq": to}).
+		OrderBy("seq").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.ChainCheckpoint, 0)
+	for rows.Next() {
+		var entry model.ChainCheckpoint
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Source,
+			&entry.Seq,
+			&entry.Hash,
+			&entry.KeyID,
+			&entry.Signature,
+			&entry.SignedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+	return ret, rows.Err()
+}
+
+// ReadTombstones returns the tombstones of source overlapping the links
+// from to to, in chain order.
+func (r *ChainReader) ReadTombstones(
+	source string,
+	from uint64,
+	to uint64,
+) ([]model.ChainTombstone, error) {
+	query, args, err := squirrel.Select(
+		"id",
+		"source",
+		"from_seq",
+		"to_seq",
+		"hash",
+		"deleted_at",
+		"coalesce(key_id, '')",
+		"signature",
+	).From("chain_tombstones").
+		Where(squirrel.Eq{"source": source}).
+		Where(squirrel.GtOrEq{"to_seq": from}).
+		Where(squirrel.LtOrEq{"from_seq": to}).
+		OrderBy("to_seq").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.ChainTombstone, 0)
+	for rows.Next() {
+		var entry model.ChainTombstone
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Source,
+			&entry.FromSeq,
+			&entry.ToSeq,
+			&entry.Hash,
+			&entry.DeletedAt,
+			&entry.KeyID,
+			&entry.Signature,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+	return ret, rows.Err()
+}
+
+// ReadFirstSeq returns the lowest sequence number left in the chain of
+// source, 0 when it's empty.
+func (r *ChainReader) ReadFirstSeq(source string) (uint64, error) {
+	var ret sql.NullInt64
+	err := r.tx.QueryRowContext(r.ctx,
+		"SELECT min(chain_seq) FROM logs WHERE source = $1 AND chain_seq IS NOT NULL", source,
+	).Scan(&ret)
+	return uint64(ret.Int64), err
+}
@@ -0,0 +1,108 @@
+package reader
+
+import (
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+func inWindow(w model.TimeWindow) squirrel.And {
+	return squirrel.And{
+		squirrel.GtOrEq{"created_at": w.From},
+		squirrel.Lt{"created_at": w.To},
+	}
+}
+
+// ReadWindowCounts counts the values of field within the baseline and the
+// target window. The time bounds of filter are replaced by the windows, and
+// at most limit values with the highest counts are returned.
+func (r *LogReader) ReadWindowCounts(
+	field TopField,
+	filter LogFilter,
+	baseline model.TimeWindow,
+	target model.TimeWindow,
+	limit uint64,
+) ([]model.WindowChange, error) {
+	column, err := field.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	from, to := baseline.From, target.To
+	if target.From.Before(from) {
+		from = target.From
+	}
+	if baseline.To.After(to) {
+		to = baseline.To
+	}
+	filter.After = &from
+	filter.Before = &to
+	err = r.checkFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline_where, baseline_args, err := inWindow(baseline).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	target_where, target_args, err := inWindow(target).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	baseline_count := "COUNT(*) FILTER (WHERE " + baseline_where + ")"
+	target_count := "COUNT(*) FILTER (WHERE " + target_where + ")"
+
+	q := squirrel.Select(column + " AS value").
+		Column(squirrel.Expr(baseline_count, baseline_args...)).
+		Column(squirrel.Expr(target_count, target_args...)).
+		From("logs")
+
+	q = filter.Apply(q)
+
+	q = q.Where(squirrel.Or{inWindow(baseline), inWindow(target)})
+	if field != TopMessage {
+		q = q.Where(squirrel.NotEq{column: nil})
+	}
+
+	order_args := make([]any, 0, len(baseline_args)+len(target_args))
+	order_args = append(order_args, baseline_args...)
+	order_args = append(order_args, target_args...)
+
+	q = q.GroupBy("value").
+		OrderByClause("greatest("+baseline_count+", "+target_count+") DESC", order_args...).
+		OrderBy("value ASC").
+		Limit(limit)
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.checkCost(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.queryContext()
+	defer cancel()
+
+	rows, err := r.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.timeoutError(ctx, err)
+	}
+	defer rows.Close()
+
+	ret := make([]model.WindowChange, 0)
+
+	for rows.Next() {
+		var entry model.WindowChange
+		err := rows.Scan(&entry.Value, &entry.Baseline, &entry.Target)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+
+	return ret, r.timeoutError(ctx, rows.Err())
+}
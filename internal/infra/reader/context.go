@@ -0,0 +1,79 @@
+package reader
+
+import (
+	"database/sql"
+	"slices"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+type ContextScope string
+
+const (
+	ContextScopeSource     ContextScope = "source"
+	ContextScopeLoggerName ContextScope = "logger_name"
+)
+
+func (r *LogReader) ReadLog(id uint64) (*model.LogModel, error) {
+	return r.readLog(squirrel.Select(logColumns...).From("logs").
+		Where(squirrel.Eq{"id": id, "is_deleted": false}))
+}
+
+// ReadRehydratedLog returns a log of logs_rehydrated.
+func (r *LogReader) ReadRehydratedLog(id uint64) (*model.LogModel, error) {
+	return r.readLog(squirrel.Select(logColumns...).From(logsTable(true)).
+		Where(squirrel.Eq{"id": id}))
+}
+
+func (r *LogReader) readLog(q squirrel.SelectBuilder) (*model.LogModel, error) {
+	ret, err := r.queryLogs(q, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) == 0 {
+		return nil, model.ErrLogNotFound
+	}
+	return &ret[0], nil
+}
+
+// ReadContext returns up to before/after neighbours of the anchor entry
+// within the given scope, ordered by (created_at, id) like `grep -C`.
+func (r *LogReader) ReadContext(
+	anchor model.LogModel,
+	before uint64,
+	after uint64,
+	scope ContextScope,
+) ([]model.LogModel, []model.LogModel, error) {
+	neighbours := func(cmp string, order OrderT, limit uint64) ([]model.LogModel, error) {
+		q := squirrel.Select(logColumns...).From("logs").
+			Where(squirrel.Eq{"source": anchor.Source, "is_deleted": false}).
+			Where("(created_at, id) "+cmp+" (?, ?)", anchor.CreatedAt, anchor.ID)
+
+		if scope == ContextScopeLoggerName {
+			logger_name := sql.NullString{
+				String: anchor.LoggerName,
+				Valid:  anchor.LoggerName != "",
+			}
+			q = q.Where("logger_name IS NOT DISTINCT FROM ?", logger_name)
+		}
+
+		q = q.OrderBy("created_at "+order.sql(), "id "+order.sql()).Limit(limit)
+
+		return r.queryLogs(q, true)
+	}
+
+	prev, err := neighbours("<", OrderDesc, before)
+	if err != nil {
+		return nil, nil, err
+	}
+	slices.Reverse(prev)
+
+	next, err := neighbours(">", OrderAsc, after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return prev, next, nil
+}
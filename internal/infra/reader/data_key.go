@@ -0,0 +1,217 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/infra/envelope"
+	"log_shelter/internal/model"
+)
+
+var dataKeyColumns = []string{
+	"id",
+	"source",
+	"wrapped_key",
+	"master_key_id",
+	"created_at",
+	"retired_at",
+}
+
+type DataKeyReader struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewDataKeyReader(
+	ctx context.Context,
+	tx *sql.Tx,
+) *DataKeyReader {
+	return &DataKeyReader{tx: tx, ctx: ctx}
+}
+
+func (r *DataKeyReader) queryKeys(q squirrel.SelectBuilder) ([]model.DataKey, error) {
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.DataKey, 0)
+	for rows.Next() {
+		var entry model.DataKey
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Source,
+			&entry.Wrapped,
+			&entry.MasterKeyID,
+			&entry.CreatedAt,
+			&entry.RetiredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+	return ret, rows.Err()
+}
+
+// ReadKeys returns every data key, retired ones included.
+func (r *DataKeyReader) ReadKeys() ([]model.DataKey, error) {
+	return r.queryKeys(squirrel.Select(dataKeyColumns...).From("data_keys").OrderBy("id"))
+}
+
+// ReadActiveKey returns the data key new logs of source are encrypted
+// with, nil when there's none yet.
+func (r *DataKeyReader) ReadActiveKey(source string) (*model.DataKey, error) {
+	keys, err := r.queryKeys(squirrel.Select(dataKeyColumns...).From("data_keys").
+		Where(squirrel.Eq{"source": source, "retired_at": nil}))
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return &keys[0], nil
+}
+
+func (r *DataKeyReader) ReadKey(id uint64) (*model.DataKey, error) {
+	keys, err := r.queryKeys(squirrel.Select(dataKeyColumns...).From("data_keys").
+		Where(squirrel.Eq{"id": id}))
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("data key %d not found", id)
+	}
+	return &keys[0], nil
+}
+
+// ReadSealed returns up to limit logs encrypted with the data key id.
+func (r *DataKeyReader) ReadSealed(id uint64, limit uint64) ([]model.SealedLog, error) {
+	query, args, err := squirrel.Select(
+		"id",
+		"source",
+		"created_at",
+		"raw_log_enc",
+		"attributes_enc",
+	).From("logs").
+		Where(squirrel.Eq{"data_key_id": id}).
+		Limit(limit).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.SealedLog, 0)
+	for rows.Next() {
+		entry := model.SealedLog{Sealed: model.Sealed{DataKeyID: id}}
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Source,
+			&entry.CreatedAt,
+			&entry.Sealed.RawLog,
+			&entry.Sealed.Attributes,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+	return ret, rows.Err()
+}
+
+// IsKeyUsed tells whether any log is still encrypted with the data key id.
+func (r *DataKeyReader) IsKeyUsed(id uint64) (bool, error) {
+	var ret bool
+	err := r.tx.QueryRowContext(r.ctx,
+		"SELECT EXISTS (SELECT 1 FROM logs WHERE data_key_id = $1)", id,
+	).Scan(&ret)
+	return ret, err
+}
+
+// IsKeyArchived tells whether archived or rehydrated logs are encrypted with
+// the data key id. Those are never re-encrypted, so the key must stay.
+func (r *DataKeyReader) IsKeyArchived(id uint64) (bool, error) {
+	var ret bool
+	err := r.tx.QueryRowContext(r.ctx, `
+		SELECT EXISTS (SELECT 1 FROM archive_segments WHERE data_key_ids @> ARRAY[$1::bigint])
+			OR EXISTS (SELECT 1 FROM logs_rehydrated WHERE data_key_id = $1)
+	`, id).Scan(&ret)
+	return ret, err
+}
+
+// Key returns the data key id unwrapped by keys.
+func (r *DataKeyReader) Key(keys *envelope.Keyring, id uint64) ([]byte, error) {
+	return keys.DataKey(id, func() ([]byte, string, error) {
+		key, err := r.ReadKey(id)
+		if err != nil {
+			return nil, "", err
+		}
+		return key.Wrapped, key.MasterKeyID, nil
+	})
+}
+
+// OpenSealed decrypts the raw_log and attributes of a log of source created
+// at created_at.
+func OpenSealed(
+	key []byte,
+	source string,
+	created_at time.Time,
+	sealed *model.Sealed,
+) (string, map[string]any, error) {
+	raw_log, err := envelope.Open(key, sealed.RawLog,
+		envelope.AAD(source, envelope.FieldRawLog, created_at))
+	if err != nil {
+		return "", nil, err
+	}
+	var attributes map[string]any
+	if sealed.Attributes != nil {
+		data, err := envelope.Open(key, sealed.Attributes,
+			envelope.AAD(source, envelope.FieldAttributes, created_at))
+		if err != nil {
+			return "", nil, err
+		}
+		err = json.Unmarshal(data, &attributes)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return string(raw_log), attributes, nil
+}
+
+// unseal decrypts entry in place when it's encrypted. Without keys the
+// payload stays empty and the entry is flagged as encrypted instead.
+func unseal(ctx context.Context, tx *sql.Tx, keys *envelope.Keyring, entry *model.LogModel) error {
+	if entry.Sealed == nil {
+		return nil
+	}
+	if keys == nil {
+		entry.Encrypted = true
+		entry.Sealed = nil
+		return nil
+	}
+
+	key, err := NewDataKeyReader(ctx, tx).Key(keys, entry.Sealed.DataKeyID)
+	if err != nil {
+		return err
+	}
+	entry.RawLog, entry.Attributes, err = OpenSealed(key, entry.Source, entry.CreatedAt, entry.Sealed)
+	if err != nil {
+		return fmt.Errorf("log %d: %w", entry.ID, err)
+	}
+	entry.Sealed = nil
+	return nil
+}
@@ -0,0 +1,81 @@
+package reader
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+const exportCursor = "log_shelter_export"
+
+// StreamLogs walks every log matching the filter through a server-side
+// cursor, fetching batch rows at a time, and calls fn for each of them.
+// It must run inside a transaction.
+func (r *LogReader) StreamLogs(
+	filter LogFilter,
+	order OrderT,
+	batch uint64,
+	fn func(*model.LogModel) error,
+) error {
+	err := r.checkFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	q := squirrel.Select(logColumns...).From(filter.Table())
+
+	q = filter.Apply(q)
+
+	q = q.OrderBy("created_at "+order.sql(), "id "+order.sql())
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+
+	err = r.checkCost(query, args)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.tx.ExecContext(r.ctx,
+		"DECLARE "+exportCursor+" NO SCROLL CURSOR FOR "+query, args...)
+	if err != nil {
+		return err
+	}
+	defer r.tx.ExecContext(r.ctx, "CLOSE "+exportCursor)
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM %s", batch, exportCursor)
+	for {
+		logs, err := r.fetch(fetch)
+		if err != nil {
+			return err
+		}
+		for i := range logs {
+			err = fn(&logs[i])
+			if err != nil {
+				return err
+			}
+		}
+		if uint64(len(logs)) < batch {
+			return nil
+		}
+	}
+}
+
+func (r *LogReader) fetch(query string) ([]model.LogModel, error) {
+	ctx, cancel := r.queryContext()
+	defer cancel()
+
+	rows, err := r.tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, r.timeoutError(ctx, err)
+	}
+	ret, err := scanLogs(rows)
+	if err != nil {
+		return nil, r.timeoutError(ctx, err)
+	}
+	return ret, r.unseal(ret)
+}
@@ -0,0 +1,79 @@
+package reader
+
+import (
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+type FacetField string
+
+const (
+	FacetSource       FacetField = "source"
+	FacetLogLevel     FacetField = "log_level"
+	FacetLoggerName   FacetField = "logger_name"
+	FacetAttributeKey FacetField = "attribute_key"
+)
+
+func (r *LogReader) ReadFacet(
+	field FacetField,
+	filter LogFilter,
+	prefix *string,
+	limit uint64,
+) ([]model.FacetValue, error) {
+	err := r.checkFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	column := string(field)
+	from := "logs"
+	if field == FacetAttributeKey {
+		from = "logs CROSS JOIN LATERAL jsonb_object_keys(" +
+			"CASE WHEN jsonb_typeof(attributes) = 'object' THEN attributes END" +
+			") AS attribute_key"
+	}
+
+	q := squirrel.Select(column, "COUNT(*)").From(from)
+
+	q = filter.Apply(q)
+
+	q = q.Where(squirrel.NotEq{column: nil})
+	if prefix != nil && *prefix != "" {
+		q = q.Where(squirrel.Like{column: escapeLike(*prefix) + "%"})
+	}
+
+	q = q.GroupBy(column).OrderBy("COUNT(*) DESC", column+" ASC").Limit(limit)
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.checkCost(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.queryContext()
+	defer cancel()
+
+	rows, err := r.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.timeoutError(ctx, err)
+	}
+	defer rows.Close()
+
+	ret := make([]model.FacetValue, 0)
+
+	for rows.Next() {
+		var entry model.FacetValue
+		err := rows.Scan(&entry.Value, &entry.Count)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+
+	return ret, r.timeoutError(ctx, rows.Err())
+}
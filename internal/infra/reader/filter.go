@@ -0,0 +1,219 @@
+package reader
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+// DeletedMode selects how soft-deleted rows are treated by a filter.
+type DeletedMode string
+
+const (
+	DeletedExclude DeletedMode = ""
+	DeletedInclude DeletedMode = "include"
+	DeletedOnly    DeletedMode = "only"
+)
+
+type LogFilter struct {
+	Sources    []string
+	Levels     []string
+	Before     *time.Time
+	After      *time.Time
+	RequestID  *string
+	LoggerName *string
+	MinLevel   *model.Level
+	MaxLevel   *model.Level
+
+	// LoggerNames matches any of the names, "*" patterns included.
+	LoggerNames []string
+
+	ExcludeSources     []string
+	ExcludeLevels      []string
+	ExcludeLoggerNames []string
+
+	RawLogContains     *string
+	RawLogRegex        *string
+	LoggerNameContains *string
+	LoggerNameRegex    *string
+
+	// AnyOf holds alternative filters, at least one of them has to match
+	// in addition to the conditions above.
+	AnyOf []LogFilter
+
+	Deleted DeletedMode
+
+	// Rehydrated searches logs_rehydrated instead of logs. Rehydrated logs
+	// all left logs already, so Deleted doesn't apply to them.
+	Rehydrated bool
+
+	AllowUnbounded bool
+}
+
+// RehydratedTable holds the logs loaded back from the archive.
+const RehydratedTable = "logs_rehydrated"
+
+// logsTable returns the table logs are read from, aliased as logs.
+func logsTable(rehydrated bool) string {
+	if rehydrated {
+		return RehydratedTable + " AS logs"
+	}
+	return "logs"
+}
+
+// Table returns the table the filter searches.
+func (f *LogFilter) Table() string {
+	return logsTable(f.Rehydrated)
+}
+
+func anyOf(values []string) bool {
+	return len(values) == 0 || slices.Contains(values, "*")
+}
+
+// matchAny matches column against values, where values containing "*" are
+// wildcard patterns such as "payments-*".
+func matchAny(column string, values []string) squirrel.Sqlizer {
+	exact := make([]string, 0, len(values))
+	ret := squirrel.Or{}
+	for _, v := range values {
+		if strings.Contains(v, "*") {
+			pattern := strings.ReplaceAll(escapeLike(v), "*", "%")
+			ret = append(ret, squirrel.Like{column: pattern})
+			continue
+		}
+		exact = append(exact, v)
+	}
+	if len(exact) != 0 {
+		ret = append(ret, squirrel.Eq{column: exact})
+	}
+	return ret
+}
+
+// conditions returns the filter as a conjunction of SQL predicates.
+func (f *LogFilter) conditions() squirrel.And {
+	ret := squirrel.And{}
+
+	if !anyOf(f.Sources) {
+		ret = append(ret, matchAny("source", f.Sources))
+	}
+	if !anyOf(f.Levels) {
+		ret = append(ret, squirrel.Eq{"log_level": f.Levels})
+	}
+
+	if f.MinLevel != nil {
+		ret = append(ret, squirrel.GtOrEq{"level_rank": int16(*f.MinLevel)})
+	}
+	if f.MaxLevel != nil {
+		ret = append(ret, squirrel.LtOrEq{"level_rank": int16(*f.MaxLevel)})
+	}
+
+	if f.After != nil {
+		ret = append(ret, squirrel.GtOrEq{"created_at": *f.After})
+	}
+	if f.Before != nil {
+		ret = append(ret, squirrel.LtOrEq{"created_at": *f.Before})
+	}
+
+	if f.RequestID != nil {
+		ret = append(ret, squirrel.Eq{"request_id": *f.RequestID})
+	}
+	if f.LoggerName != nil {
+		ret = append(ret, squirrel.Eq{"logger_name": *f.LoggerName})
+	}
+	if !anyOf(f.LoggerNames) {
+		ret = append(ret, matchAny("logger_name", f.LoggerNames))
+	}
+
+	if len(f.ExcludeSources) != 0 {
+		ret = append(ret, squirrel.Expr("NOT ?", matchAny("source", f.ExcludeSources)))
+	}
+	if len(f.ExcludeLevels) != 0 {
+		ret = append(ret, squirrel.NotEq{"log_level": f.ExcludeLevels})
+	}
+	if len(f.ExcludeLoggerNames) != 0 {
+		ret = append(ret, squirrel.Or{
+			squirrel.Eq{"logger_name": nil},
+			squirrel.Expr("NOT ?", matchAny("logger_name", f.ExcludeLoggerNames)),
+		})
+	}
+
+	if f.RawLogContains != nil {
+		ret = append(ret, squirrel.Like{"raw_log": "%" + escapeLike(*f.RawLogContains) + "%"})
+	}
+	if f.RawLogRegex != nil {
+		ret = append(ret, squirrel.Expr("raw_log ~ ?", *f.RawLogRegex))
+	}
+	if f.LoggerNameContains != nil {
+		ret = append(ret,
+			squirrel.Like{"logger_name": "%" + escapeLike(*f.LoggerNameContains) + "%"})
+	}
+	if f.LoggerNameRegex != nil {
+		ret = append(ret, squirrel.Expr("logger_name ~ ?", *f.LoggerNameRegex))
+	}
+
+	if len(f.AnyOf) != 0 {
+		groups := squirrel.Or{}
+		for i := range f.AnyOf {
+			groups = append(groups, f.AnyOf[i].conditions())
+		}
+		ret = append(ret, groups)
+	}
+
+	return ret
+}
+
+// EachRegex calls fn with every regular expression of f and of its any_of
+// groups, along with the name of the request field holding it.
+func (f *LogFilter) EachRegex(fn func(name string, pattern string) error) error {
+	if f.RawLogRegex != nil {
+		err := fn("raw_log_regex", *f.RawLogRegex)
+		if err != nil {
+			return err
+		}
+	}
+	if f.LoggerNameRegex != nil {
+		err := fn("logger_name_regex", *f.LoggerNameRegex)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range f.AnyOf {
+		err := f.AnyOf[i].EachRegex(func(name string, pattern string) error {
+			return fn("any_of."+name, pattern)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Where returns the whole filter as a single predicate, including the
+// soft-delete condition, for statements other than selects.
+func (f *LogFilter) Where() squirrel.And {
+	ret := squirrel.And{}
+	switch {
+	case f.Rehydrated, f.Deleted == DeletedInclude:
+	case f.Deleted == DeletedOnly:
+		ret = append(ret, squirrel.Eq{"is_deleted": true})
+	default:
+		ret = append(ret, squirrel.Eq{"is_deleted": false})
+	}
+	return append(ret, f.conditions()...)
+}
+
+func (f *LogFilter) Apply(q squirrel.SelectBuilder) squirrel.SelectBuilder {
+	for _, c := range f.Where() {
+		q = q.Where(c)
+	}
+
+	return q
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,189 @@
+package reader
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func toSql(t *testing.T, s squirrel.Sqlizer) (string, []any) {
+	t.Helper()
+	query, args, err := s.ToSql()
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err = squirrel.Dollar.ReplacePlaceholders(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return query, args
+}
+
+func TestLogFilterConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter LogFilter
+		sql    string
+		args   []any
+	}{
+		{
+			name:   "empty",
+			filter: LogFilter{},
+			sql:    "(1=1)",
+			args:   []any{},
+		},
+		{
+			name:   "star matches every source",
+			filter: LogFilter{Sources: []string{"*"}, Levels: []string{"ERROR"}},
+			sql:    "(log_level IN ($1))",
+			args:   []any{"ERROR"},
+		},
+		{
+			name:   "wildcard and exact sources",
+			filter: LogFilter{Sources: []string{"payments-*", "billing"}},
+			sql:    "((source LIKE $1 OR source IN ($2)))",
+			args:   []any{"payments-%", "billing"},
+		},
+		{
+			name:   "wildcard escapes LIKE metacharacters",
+			filter: LogFilter{Sources: []string{`pay_ments%\-*`}},
+			sql:    "((source LIKE $1))",
+			args:   []any{`pay\_ments\%\\-%`},
+		},
+		{
+			name: "severity ranks",
+			filter: LogFilter{
+				MinLevel: ptr(model.LevelWarn),
+				MaxLevel: ptr(model.LevelError),
+			},
+			sql:  "(level_rank >= $1 AND level_rank <= $2)",
+			args: []any{int16(model.LevelWarn), int16(model.LevelError)},
+		},
+		{
+			name: "excluded sources and levels",
+			filter: LogFilter{
+				ExcludeSources: []string{"noisy-*"},
+				ExcludeLevels:  []string{"DEBUG", "TRACE"},
+			},
+			sql:  "(NOT (source LIKE $1) AND log_level NOT IN ($2,$3))",
+			args: []any{"noisy-%", "DEBUG", "TRACE"},
+		},
+		{
+			name:   "excluded logger names keep logs without one",
+			filter: LogFilter{ExcludeLoggerNames: []string{"health", "probe.*"}},
+			sql:    "((logger_name IS NULL OR NOT (logger_name LIKE $1 OR logger_name IN ($2))))",
+			args:   []any{"probe.%", "health"},
+		},
+		{
+			name: "raw_log contains and regex",
+			filter: LogFilter{
+				RawLogContains: ptr(`50%_off\`),
+				RawLogRegex:    ptr(`timeout after \d+ms`),
+			},
+			sql:  "(raw_log LIKE $1 AND raw_log ~ $2)",
+			args: []any{`%50\%\_off\\%`, `timeout after \d+ms`},
+		},
+		{
+			name: "logger_name contains and regex",
+			filter: LogFilter{
+				LoggerNameContains: ptr("http"),
+				LoggerNameRegex:    ptr(`^api\.`),
+			},
+			sql:  "(logger_name LIKE $1 AND logger_name ~ $2)",
+			args: []any{"%http%", `^api\.`},
+		},
+		{
+			name: "nested any_of",
+			filter: LogFilter{
+				Sources: []string{"api"},
+				AnyOf: []LogFilter{
+					{Levels: []string{"ERROR"}},
+					{
+						RawLogContains: ptr("panic"),
+						AnyOf: []LogFilter{
+							{LoggerNames: []string{"worker-*"}},
+							{RequestID: ptr("r1")},
+						},
+					},
+				},
+			},
+			sql: "((source IN ($1)) AND ((log_level IN ($2)) OR " +
+				"(raw_log LIKE $3 AND (((logger_name LIKE $4)) OR (request_id = $5)))))",
+			args: []any{"api", "ERROR", "%panic%", "worker-%", "r1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := toSql(t, tt.filter.conditions())
+			if sql != tt.sql {
+				t.Errorf("sql:\n got %s\nwant %s", sql, tt.sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args:\n got %#v\nwant %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestLogFilterWhereDeleted(t *testing.T) {
+	tests := []struct {
+		mode       DeletedMode
+		rehydrated bool
+		sql        string
+		args       []any
+	}{
+		{mode: DeletedExclude, sql: "(is_deleted = $1 AND (source IN ($2)))", args: []any{false, "api"}},
+		{mode: DeletedInclude, sql: "((source IN ($1)))", args: []any{"api"}},
+		{mode: DeletedOnly, sql: "(is_deleted = $1 AND (source IN ($2)))", args: []any{true, "api"}},
+		{mode: DeletedOnly, rehydrated: true, sql: "((source IN ($1)))", args: []any{"api"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s rehydrated=%t", tt.mode, tt.rehydrated), func(t *testing.T) {
+			filter := LogFilter{Sources: []string{"api"}, Deleted: tt.mode, Rehydrated: tt.rehydrated}
+			sql, args := toSql(t, filter.Where())
+			if sql != tt.sql {
+				t.Errorf("sql:\n got %s\nwant %s", sql, tt.sql)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args:\n got %#v\nwant %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestLogFilterEachRegex(t *testing.T) {
+	filter := LogFilter{
+		RawLogRegex: ptr("timeout"),
+		AnyOf: []LogFilter{
+			{LoggerNameRegex: ptr(`^api\.`)},
+			{AnyOf: []LogFilter{{RawLogRegex: ptr(`(a)\1`)}}},
+		},
+	}
+
+	got := make([]string, 0)
+	err := filter.EachRegex(func(name string, pattern string) error {
+		got = append(got, name+"="+pattern)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"raw_log_regex=timeout",
+		`any_of.logger_name_regex=^api\.`,
+		`any_of.any_of.raw_log_regex=(a)\1`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,206 @@
+package reader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"log_shelter/internal/model"
+)
+
+const defaultPageSize = 50
+
+// Guardrails limit how expensive a single read can be. Zero values disable
+// the corresponding check.
+type Guardrails struct {
+	StatementTimeout time.Duration
+	MaxTimeRange     time.Duration
+	MaxPageSize      uint64
+	MaxCost          float64
+}
+
+func rejected(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{model.ErrQueryRejected}, args...)...)
+}
+
+// CheckFilter rejects filters without a lower time bound (unless they are
+// narrowed down by request_id or explicitly allowed to be unbounded) and
+// filters spanning more than MaxTimeRange.
+func (g *Guardrails) CheckFilter(f LogFilter) error {
+	if f.AllowUnbounded {
+		return nil
+	}
+	if f.After == nil {
+		if f.RequestID != nil {
+			return nil
+		}
+		return rejected("query has no \"after\" time bound, " +
+			"set \"allow_unbounded\" to run it anyway")
+	}
+	if g.MaxTimeRange == 0 {
+		return nil
+	}
+	before := time.Now()
+	if f.Before != nil {
+		before = *f.Before
+	}
+	if span := before.Sub(*f.After); span > g.MaxTimeRange {
+		return rejected("time range of %s exceeds the maximum of %s",
+			span.Round(time.Second), g.MaxTimeRange)
+	}
+	return nil
+}
+
+// CheckFilter applies the filter guardrails to statements that don't go
+// through ReadLogs, such as restores.
+func (r *LogReader) CheckFilter(f LogFilter) error {
+	return r.checkFilter(f)
+}
+
+// checkFilter applies the filter guardrails and has Postgres compile the
+// regular expressions of f.
+func (r *LogReader) checkFilter(f LogFilter) error {
+	err := r.guard.CheckFilter(f)
+	if err != nil {
+		return err
+	}
+	return f.EachRegex(r.checkRegex)
+}
+
+// invalidRegex is the SQLSTATE of a regular expression Postgres can't
+// compile.
+const invalidRegex = "2201B"
+
+// checkRegex compiles pattern with the engine that runs it, Postgres ARE
+// syntax differing from Go's, and rejects it as an invalid request when it
+// doesn't compile. The savepoint keeps the failure from aborting the
+// transaction.
+func (r *LogReader) checkRegex(name string, pattern string) error {
+	_, err := r.tx.ExecContext(r.ctx, "SAVEPOINT check_regex")
+	if err != nil {
+		return err
+	}
+	_, err = r.tx.ExecContext(r.ctx, "SELECT '' ~ $1", pattern)
+	if err != nil {
+		_, rollback_err := r.tx.ExecContext(r.ctx, "ROLLBACK TO SAVEPOINT check_regex")
+		if rollback_err != nil {
+			return rollback_err
+		}
+		var pq_err *pq.Error
+		if errors.As(err, &pq_err) && pq_err.Code == invalidRegex {
+			return fmt.Errorf("%w: invalid %s: %s", model.ErrInvalidRequest, name, pq_err.Message)
+		}
+		return err
+	}
+	_, err = r.tx.ExecContext(r.ctx, "RELEASE SAVEPOINT check_regex")
+	return err
+}
+
+// PageSize returns the requested page size, or the default one, within
+// MaxPageSize.
+func (g *Guardrails) PageSize(page_size *uint64) (uint64, error) {
+	if page_size == nil || *page_size == 0 {
+		if g.MaxPageSize != 0 {
+			return min(defaultPageSize, g.MaxPageSize), nil
+		}
+		return defaultPageSize, nil
+	}
+	if g.MaxPageSize != 0 && *page_size > g.MaxPageSize {
+		return 0, rejected("page size %d exceeds the maximum of %d",
+			*page_size, g.MaxPageSize)
+	}
+	return *page_size, nil
+}
+
+// queryContext bounds a single statement by StatementTimeout.
+func (r *LogReader) queryContext() (context.Context, context.CancelFunc) {
+	if r.guard.StatementTimeout == 0 {
+		return context.WithCancel(r.ctx)
+	}
+	return context.WithTimeout(r.ctx, r.guard.StatementTimeout)
+}
+
+// timeoutError reports a statement cancelled by queryContext as a
+// rejection instead of the driver's generic cancellation error.
+func (r *LogReader) timeoutError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return rejected("statement timeout of %s exceeded", r.guard.StatementTimeout)
+	}
+	return err
+}
+
+// checkCost asks the planner for the estimated total cost of the query and
+// rejects it above MaxCost.
+func (r *LogReader) checkCost(query string, args []any) error {
+	if r.guard.MaxCost == 0 {
+		return nil
+	}
+
+	ctx, cancel := r.queryContext()
+	defer cancel()
+
+	var plan []byte
+	err := r.tx.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan)
+	if err != nil {
+		return r.timeoutError(ctx, err)
+	}
+
+	var explain []struct {
+		Plan struct {
+			TotalCost float64 `json:"Total Cost"`
+		} `json:"Plan"`
+	}
+	err = json.Unmarshal(plan, &explain)
+	if err != nil {
+		return err
+	}
+	if len(explain) != 0 && explain[0].Plan.TotalCost > r.guard.MaxCost {
+		return rejected("estimated query cost %.0f exceeds the maximum of %.0f",
+			explain[0].Plan.TotalCost, r.guard.MaxCost)
+	}
+	return nil
+}
+
+// queryLogs runs the select under the statement timeout and scans the
+// resulting logs. Filter based queries are checked against MaxCost first.
+func (r *LogReader) queryLogs(q squirrel.SelectBuilder, check_cost bool) ([]model.LogModel, error) {
+	ret, err := r.querySealed(q, check_cost)
+	if err != nil {
+		return nil, err
+	}
+	return ret, r.unseal(ret)
+}
+
+// querySealed is queryLogs leaving encrypted payloads in Sealed.
+func (r *LogReader) querySealed(q squirrel.SelectBuilder, check_cost bool) ([]model.LogModel, error) {
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	if check_cost {
+		err = r.checkCost(query, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := r.queryContext()
+	defer cancel()
+
+	rows, err := r.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.timeoutError(ctx, err)
+	}
+
+	ret, err := scanLogs(rows)
+	if err != nil {
+		return nil, r.timeoutError(ctx, err)
+	}
+	return ret, nil
+}
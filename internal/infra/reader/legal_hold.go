@@ -0,0 +1,136 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+// activeHold selects the holds of legal_holds h in force right now.
+const activeHold = `h.released_at IS NULL AND (h.expires_at IS NULL OR h.expires_at > now())`
+
+// holdMatch matches the log row "logs" against the hold h.
+const holdMatch = `(h.source IS NULL OR h.source = logs.source)
+	AND (h.request_id IS NULL OR h.request_id = logs.request_id)
+	AND (h.from_time IS NULL OR logs.created_at >= h.from_time)
+	AND (h.to_time IS NULL OR logs.created_at < h.to_time)`
+
+// Held matches the logs at least one active legal hold keeps.
+func Held() squirrel.Sqlizer {
+	return squirrel.Expr("EXISTS (SELECT 1 FROM legal_holds h WHERE " +
+		activeHold + " AND " + holdMatch + ")")
+}
+
+// Unheld matches the logs no active legal hold keeps, the only ones
+// retention and purge may delete.
+func Unheld() squirrel.Sqlizer {
+	return squirrel.Expr("NOT ?", Held())
+}
+
+type LegalHoldReader struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewLegalHoldReader(
+	ctx context.Context,
+	tx *sql.Tx,
+) *LegalHoldReader {
+	return &LegalHoldReader{tx: tx, ctx: ctx}
+}
+
+// ReadHolds lists holds, newest first, along with the logs each of them
+// keeps. Without inactive only the holds in force are listed.
+func (r *LegalHoldReader) ReadHolds(
+	ids []uint64,
+	inactive bool,
+	limit uint64,
+) ([]model.LegalHold, error) {
+	volume := squirrel.Select(
+		"count(*) AS row_count",
+		"coalesce(sum(pg_column_size(logs.*)), 0) AS size_bytes",
+	).From("logs").Where(holdMatch)
+
+	q := squirrel.Select(
+		"h.id",
+		"h.reason",
+		"h.created_by",
+		"h.created_at",
+		"h.expires_at",
+		"h.released_by",
+		"h.released_at",
+		"h.source",
+		"h.request_id",
+		"h.from_time",
+		"h.to_time",
+		"("+activeHold+") AS active",
+		"v.row_count",
+		"v.size_bytes",
+	).From("legal_holds h").
+		JoinClause(volume.Prefix("CROSS JOIN LATERAL (").Suffix(") v"))
+	if len(ids) != 0 {
+		q = q.Where(squirrel.Eq{"h.id": ids})
+	}
+	if !inactive {
+		q = q.Where(activeHold)
+	}
+	q = q.OrderBy("h.id DESC").Limit(limit)
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.LegalHold, 0)
+
+	for rows.Next() {
+		var entry model.LegalHold
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Reason,
+			&entry.CreatedBy,
+			&entry.CreatedAt,
+			&entry.ExpiresAt,
+			&entry.ReleasedBy,
+			&entry.ReleasedAt,
+			&entry.Source,
+			&entry.RequestID,
+			&entry.From,
+			&entry.To,
+			&entry.Active,
+			&entry.Rows,
+			&entry.SizeBytes,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+
+	return ret, rows.Err()
+}
+
+// ReadProtected returns the volume all active holds keep together.
+func (r *LegalHoldReader) ReadProtected() (model.HoldVolume, error) {
+	var ret model.HoldVolume
+
+	query, args, err := squirrel.Select(
+		"count(*)",
+		"coalesce(sum(pg_column_size(logs.*)), 0)",
+	).From("logs").Where(Held()).PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return ret, err
+	}
+
+	err = r.tx.QueryRowContext(r.ctx, query, args...).Scan(&ret.Rows, &ret.SizeBytes)
+	return ret, err
+}
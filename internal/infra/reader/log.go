@@ -3,12 +3,12 @@ package reader
 import (
 	"context"
 	"database/sql"
-	"slices"
-	"time"
+	"encoding/json"
+	"strings"
 
 	"github.com/Masterminds/squirrel"
-	"github.com/lib/pq"
 
+	"log_shelter/internal/infra/envelope"
 	"log_shelter/internal/model"
 )
 
@@ -19,166 +19,163 @@ const (
 	OrderDesc OrderT = "desc"
 )
 
+// sql returns the ORDER BY direction, defaulting to ascending order for
+// anything that isn't an explicit "desc", as ReadLogs always did.
+func (o OrderT) sql() string {
+	if strings.EqualFold(string(o), string(OrderDesc)) {
+		return "DESC"
+	}
+	return "ASC"
+}
+
 type LogReader struct {
-	ctx context.Context
-	tx  *sql.Tx
+	ctx   context.Context
+	tx    *sql.Tx
+	guard Guardrails
+	// keys decrypts encrypted logs, nil without a master key. Logs are
+	// only decrypted for contexts allowed by envelope.AllowDecrypt.
+	keys *envelope.Keyring
 }
 
 func NewLogReader(
 	ctx context.Context,
 	tx *sql.Tx,
+	guard Guardrails,
+	keys *envelope.Keyring,
 ) *LogReader {
-	return &LogReader{tx: tx, ctx: ctx}
+	return &LogReader{tx: tx, ctx: ctx, guard: guard, keys: keys}
 }
 
-func (r *LogReader) ReadLogs(
-	page uint64,
-	page_size *uint64,
-	sources []string,
-	levels []string,
-	before *time.Time,
-	after *time.Time,
-	request_id *string,
-	logger_name *string,
-	order OrderT,
-) ([]model.LogModel, error) {
-	q := squirrel.Select("id", "raw_log",
-		"log_level",
-		"source",
-		"created_at",
-		"request_id",
-		"logger_name").From("logs")
-
-	q = q.Where(squirrel.Eq{"is_deleted": false})
-
-	if !slices.Contains(sources, "*") {
-		q = q.Where(squirrel.Eq{"source": sources})
-	}
-	if !slices.Contains(levels, "*") {
-		q = q.Where(squirrel.Eq{"level": levels})
-	}
-
-	if after != nil {
-		q = q.Where(squirrel.GtOrEq{"created_at": *after})
-	}
-	if before != nil {
-		q = q.Where(squirrel.GtOrEq{"created_at": *before})
+// keyring returns the keys reads under r.ctx may decrypt with, nil when
+// the caller isn't allowed to see encrypted payloads.
+func (r *LogReader) keyring() *envelope.Keyring {
+	if !envelope.MayDecrypt(r.ctx) {
+		return nil
 	}
+	return r.keys
+}
 
-	if request_id != nil {
-		q = q.Where(squirrel.Eq{"request_id": *request_id})
-	}
-	if logger_name != nil {
-		q = q.Where(squirrel.Eq{"logger_name": *logger_name})
+// unseal decrypts the encrypted logs among logs, or flags them as
+// encrypted when the caller may not decrypt them.
+func (r *LogReader) unseal(logs []model.LogModel) error {
+	keys := r.keyring()
+	for i := range logs {
+		err := unseal(r.ctx, r.tx, keys, &logs[i])
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	q = q.OrderBy("created_at " + string(order))
-
-	q = q.Where(squirrel.Eq{"is_deleted": false})
+var logColumns = []string{
+	"id",
+	"raw_log",
+	"log_level",
+	"raw_level",
+	"source",
+	"created_at",
+	"request_id",
+	"logger_name",
+	"attributes",
+	"trace_id",
+	"span_id",
+	"parent_span_id",
+	"is_deleted",
+	"deleted_at",
+	"data_key_id",
+	"raw_log_enc",
+	"attributes_enc",
+}
 
-	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+// scanLog scans a single row selected with logColumns followed by any
+// extra columns, which are scanned into extra. Encrypted payloads are left
+// in Sealed.
+func scanLog(rows *sql.Rows, extra ...any) (model.LogModel, error) {
+	var entry model.LogModel
+	var logger_name sql.NullString
+	var attributes []byte
+	var is_deleted sql.NullBool
+	var data_key_id sql.NullInt64
+	var raw_log_enc, attributes_enc []byte
+	err := rows.Scan(append([]any{
+		&entry.ID,
+		&entry.RawLog,
+		&entry.LogLevel,
+		&entry.RawLevel,
+		&entry.Source,
+		&entry.CreatedAt,
+		&entry.RequestID,
+		&logger_name,
+		&attributes,
+		&entry.TraceID,
+		&entry.SpanID,
+		&entry.ParentSpanID,
+		&is_deleted,
+		&entry.DeletedAt,
+		&data_key_id,
+		&raw_log_enc,
+		&attributes_enc,
+	}, extra...)...)
 	if err != nil {
-		return nil, err
+		return entry, err
 	}
-
-	rows, err := r.tx.Query(query, args...)
-	if err != nil {
-		return nil, err
+	entry.LoggerName = logger_name.String
+	if attributes != nil {
+		err = json.Unmarshal(attributes, &entry.Attributes)
+		if err != nil {
+			return entry, err
+		}
+	}
+	entry.IsDeleted = is_deleted.Bool
+	if data_key_id.Valid {
+		entry.Sealed = &model.Sealed{
+			DataKeyID:  uint64(data_key_id.Int64),
+			RawLog:     raw_log_enc,
+			Attributes: attributes_enc,
+		}
 	}
+	return entry, nil
+}
+
+func scanLogs(rows *sql.Rows) ([]model.LogModel, error) {
+	defer rows.Close()
 
 	ret := make([]model.LogModel, 0)
 
 	for rows.Next() {
-		var entry model.LogModel
-		err := rows.Scan(
-			&entry.ID,
-			&entry.RawLog,
-			&entry.LogLevel,
-			&entry.Source,
-			&entry.CreatedAt,
-			&entry.RequestID,
-			&entry.LoggerName,
-		)
+		entry, err := scanLog(rows)
 		if err != nil {
 			return nil, err
 		}
-		entry.IsDeleted = false
 		ret = append(ret, entry)
 	}
 
-	return ret, nil
+	return ret, rows.Err()
 }
 
-func (r *LogReader) durationToPSQLInterval(d *time.Duration) time.Duration {
-	if d == nil {
-		return time.Second
-	}
-	return *d
-}
-
-func (r *LogReader) GetTimeLineFor(
-	id uint64,
-	before *time.Duration,
-	after *time.Duration,
+func (r *LogReader) ReadLogs(
+	page uint64,
+	page_size *uint64,
+	filter LogFilter,
+	order OrderT,
 ) ([]model.LogModel, error) {
-	/*
-		$1 = id
-		$2 = 5 minutes
-		$3 = 1 minute
-		$4 = ('WARN', 'ERROR', 'CRITICAL', 'FATAL')
-
-	*/
-	query := `
-			WITH critical_log AS (
-				SELECT *
-				FROM logs
-				WHERE id = $1
-			)
-			SELECT l.id, l.raw_log, l.log_level, l.source, 
-				l.created_at, l.request_id, l.logger_name
-			FROM logs l
-			CROSS JOIN critical_log cl
-			WHERE (
-					l.request_id = cl.request_id
-					OR (
-						l.source = cl.source
-						AND l.created_at BETWEEN cl.created_at - $2::interval
-												AND cl.created_at + $3::interval
-					)
-				)
-			AND l.log_level = ANY($4)
-			AND l.is_deleted = false
-			ORDER BY l.created_at ASC
-	`
-
-	rows, err := r.tx.Query(query,
-		id,
-		r.durationToPSQLInterval(before),
-		r.durationToPSQLInterval(after),
-		pq.Array([]string{"WARN", "ERROR", "CRITICAL", "FATAL"}))
+	err := r.checkFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := r.guard.PageSize(page_size)
 	if err != nil {
 		return nil, err
 	}
 
-	ret := make([]model.LogModel, 0)
+	q := squirrel.Select(logColumns...).From(filter.Table())
 
-	for rows.Next() {
-		var entry model.LogModel
-		err := rows.Scan(
-			&entry.ID,
-			&entry.RawLog,
-			&entry.LogLevel,
-			&entry.Source,
-			&entry.CreatedAt,
-			&entry.RequestID,
-			&entry.LoggerName,
-		)
-		if err != nil {
-			return nil, err
-		}
-		entry.IsDeleted = false
-		ret = append(ret, entry)
-	}
+	q = filter.Apply(q)
+
+	q = q.OrderBy("created_at "+order.sql(), "id "+order.sql())
+
+	q = q.Limit(limit).Offset(max(page, 1)*limit - limit)
 
-	return ret, nil
+	return r.queryLogs(q, true)
 }
@@ -0,0 +1,180 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"log_shelter/internal/model"
+)
+
+type PartitionReader struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewPartitionReader(
+	ctx context.Context,
+	tx *sql.Tx,
+) *PartitionReader {
+	return &PartitionReader{tx: tx, ctx: ctx}
+}
+
+var partitionBound = regexp.MustCompile(`^FOR VALUES FROM \((.+)\) TO \((.+)\)$`)
+
+var boundLayouts = []string{
+	"2006-01-02 15:04:05-07",
+	"2006-01-02 15:04:05-07:00",
+}
+
+// parseBound parses a timestamptz range bound as printed by pg_get_expr,
+// returning nil for MINVALUE and MAXVALUE.
+func parseBound(raw string) (*time.Time, error) {
+	if raw == "MINVALUE" || raw == "MAXVALUE" {
+		return nil, nil
+	}
+	if len(raw) < 2 || raw[0] != '\'' || raw[len(raw)-1] != '\'' {
+		return nil, fmt.Errorf("unexpected partition bound %s", raw)
+	}
+	raw = raw[1 : len(raw)-1]
+	for _, layout := range boundLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected partition bound %s", raw)
+}
+
+// ReadPartitions lists the partitions of logs ordered by their range. The
+// row counts are planner estimates.
+func (r *PartitionReader) ReadPartitions() (*model.PartitionStatus, error) {
+	var kind sql.NullString
+	err := r.tx.QueryRowContext(r.ctx, `
+		SELECT p.partstrat::text
+		FROM pg_class c
+		LEFT JOIN pg_partitioned_table p ON p.partrelid = c.oid
+		WHERE c.oid = to_regclass('logs')
+	`).Scan(&kind)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	ret := model.PartitionStatus{
+		Partitioned: kind.Valid,
+		Partitions:  make([]model.Partition, 0),
+	}
+	if !ret.Partitioned {
+		return &ret, nil
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, `
+		SELECT c.relname,
+			pg_get_expr(c.relpartbound, c.oid),
+			greatest(c.reltuples, 0)::bigint,
+			pg_total_relation_size(c.oid)
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'logs'::regclass
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry model.Partition
+		var bound string
+		err = rows.Scan(&entry.Name, &bound, &entry.Rows, &entry.SizeBytes)
+		if err != nil {
+			return nil, err
+		}
+		if bound == "DEFAULT" {
+			entry.Default = true
+		} else {
+			m := partitionBound.FindStringSubmatch(bound)
+			if m == nil {
+				return nil, fmt.Errorf("unexpected partition bound %s", bound)
+			}
+			entry.From, err = parseBound(m[1])
+			if err != nil {
+				return nil, err
+			}
+			entry.To, err = parseBound(m[2])
+			if err != nil {
+				return nil, err
+			}
+		}
+		ret.TotalBytes += entry.SizeBytes
+		ret.Partitions = append(ret.Partitions, entry)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(ret.Partitions, comparePartitions)
+	return &ret, nil
+}
+
+// comparePartitions orders by range start, MINVALUE first and the default
+// partition last.
+func comparePartitions(a, b model.Partition) int {
+	switch {
+	case a.Default != b.Default:
+		if a.Default {
+			return 1
+		}
+		return -1
+	case a.From == nil && b.From == nil:
+		return 0
+	case a.From == nil:
+		return -1
+	case b.From == nil:
+		return 1
+	default:
+		return a.From.Compare(*b.From)
+	}
+}
+
+// HasRowsIn tells whether the partition holds any log created within
+// [from, to).
+func (r *PartitionReader) HasRowsIn(name string, from time.Time, to time.Time) (bool, error) {
+	query, args, err := squirrel.Select("1").
+		From(pq.QuoteIdentifier(name)).
+		Where(squirrel.GtOrEq{"created_at": from}).
+		Where(squirrel.Lt{"created_at": to}).
+		Limit(1).
+		Prefix("SELECT EXISTS (").Suffix(")").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	var ret bool
+	err = r.tx.QueryRowContext(r.ctx, query, args...).Scan(&ret)
+	return ret, err
+}
+
+// IsPartitionHeld tells whether an active hold or a recent restore keeps
+// any log of the partition, which must not be dropped then.
+func (r *PartitionReader) IsPartitionHeld(name string) (bool, error) {
+	query, args, err := squirrel.Select("1").
+		From(pq.QuoteIdentifier(name) + " AS logs").
+		Where(squirrel.Or{Held(), Retained()}).
+		Limit(1).
+		Prefix("SELECT EXISTS (").Suffix(")").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	var ret bool
+	err = r.tx.QueryRowContext(r.ctx, query, args...).Scan(&ret)
+	return ret, err
+}
@@ -0,0 +1,74 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"log_shelter/internal/model"
+)
+
+type RestoreReader struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewRestoreReader(
+	ctx context.Context,
+	tx *sql.Tx,
+) *RestoreReader {
+	return &RestoreReader{tx: tx, ctx: ctx}
+}
+
+func (r *RestoreReader) ReadRestores(
+	restored_by *string,
+	limit uint64,
+) ([]model.LogRestore, error) {
+	q := squirrel.Select(
+		"id",
+		"restored_by",
+		"reason",
+		"request",
+		"log_ids",
+		"restored_at",
+	).From("log_restores")
+	if restored_by != nil {
+		q = q.Where(squirrel.Eq{"restored_by": *restored_by})
+	}
+	q = q.OrderBy("restored_at DESC", "id DESC").Limit(limit)
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.LogRestore, 0)
+
+	for rows.Next() {
+		var entry model.LogRestore
+		var request []byte
+		err := rows.Scan(
+			&entry.ID,
+			&entry.RestoredBy,
+			&entry.Reason,
+			&request,
+			pq.Array(&entry.LogIDs),
+			&entry.RestoredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.Request = request
+		ret = append(ret, entry)
+	}
+
+	return ret, rows.Err()
+}
@@ -0,0 +1,107 @@
+package reader
+
+import (
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+// RetentionScope selects the logs a retention rule applies to: the ones
+// Match selects and none of Exclude does. LiveOnly leaves soft-deleted
+// logs out, so they neither count toward limits nor get deleted twice.
+type RetentionScope struct {
+	Match    LogFilter
+	Exclude  []LogFilter
+	LiveOnly bool
+}
+
+// RetentionLimit is one of the limits of a rule: logs created before Before
+// for model.ExpireMaxAge, or the logs of a source past the newest Max rows
+// or bytes for model.ExpireMaxRows and model.ExpireMaxBytes.
+type RetentionLimit struct {
+	Kind   string
+	Before time.Time
+	Max    uint64
+}
+
+func (s *RetentionScope) where() squirrel.And {
+	ret := squirrel.And{s.Match.Where()}
+	if s.LiveOnly {
+		ret = append(ret, squirrel.Eq{"is_deleted": false})
+	}
+	// IS NOT TRUE rather than NOT, as a NULL logger_name makes a
+	// pattern match NULL instead of false.
+	for i := range s.Exclude {
+		ret = append(ret, squirrel.Expr("? IS NOT TRUE", s.Exclude[i].Where()))
+	}
+	return ret
+}
+
+// Retained matches the logs a restore keeps until their retain_until.
+func Retained() squirrel.Sqlizer {
+	return squirrel.Expr("retain_until > now()")
+}
+
+// Unretained matches the logs no restore keeps anymore.
+func Unretained() squirrel.Sqlizer {
+	return squirrel.Expr("(retain_until IS NULL OR retain_until <= now())")
+}
+
+// RetentionWhere matches the logs in scope past limit that neither a legal
+// hold nor a recent restore keeps. Kept logs still count toward the rows
+// and bytes limits.
+func RetentionWhere(scope RetentionScope, limit RetentionLimit) squirrel.Sqlizer {
+	where := scope.where()
+	switch limit.Kind {
+	case model.ExpireMaxRows:
+		return squirrel.And{beyond(where, "1", limit.Max), Unheld(), Unretained()}
+	case model.ExpireMaxBytes:
+		return squirrel.And{
+			beyond(where, "pg_column_size(logs.*)", limit.Max), Unheld(), Unretained(),
+		}
+	default:
+		return squirrel.And{
+			where, squirrel.Lt{"created_at": limit.Before}, Unheld(), Unretained(),
+		}
+	}
+}
+
+// beyond matches the logs in scope past the point where the running total
+// of measure per source, from the newest log on, exceeds limit.
+func beyond(scope squirrel.Sqlizer, measure string, limit uint64) squirrel.Sqlizer {
+	ranked := squirrel.Select(
+		"id",
+		"sum("+measure+") OVER (PARTITION BY source ORDER BY created_at DESC, id DESC) AS total",
+	).From("logs").Where(scope)
+
+	ids := squirrel.Select("id").
+		FromSelect(ranked, "ranked").
+		Where(squirrel.Gt{"total": limit})
+
+	return squirrel.Expr("id IN (?)", ids)
+}
+
+// Purgeable matches the logs soft-deleted before before that no legal
+// hold keeps.
+func Purgeable(before time.Time) squirrel.Sqlizer {
+	return squirrel.And{
+		squirrel.Eq{"is_deleted": true},
+		squirrel.Lt{"deleted_at": before},
+		Unheld(),
+	}
+}
+
+// ReadExpiring locks and returns up to limit logs matching where, oldest
+// ids first, so they can be archived before being deleted. Encrypted logs
+// are returned sealed, archives keep them as stored.
+func (r *LogReader) ReadExpiring(where squirrel.Sqlizer, limit uint64) ([]model.LogModel, error) {
+	q := squirrel.Select(logColumns...).From("logs").
+		Where(where).
+		OrderBy("id").
+		Limit(limit).
+		Suffix("FOR UPDATE")
+
+	return r.querySealed(q, false)
+}
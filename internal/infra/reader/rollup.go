@@ -0,0 +1,188 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+// rollupLogs exposes the rollups under the column names of logs, so a
+// LogFilter without row-level conditions applies to them as is.
+const rollupLogs = `(
+	SELECT
+		resolution,
+		bucket AS created_at,
+		source,
+		log_level,
+		level_rank,
+		NULLIF(logger_name, '') AS logger_name,
+		count,
+		bytes
+	FROM log_rollups
+) AS logs`
+
+type StatsField string
+
+const (
+	StatsSource     StatsField = "source"
+	StatsLogLevel   StatsField = "log_level"
+	StatsLoggerName StatsField = "logger_name"
+)
+
+type RollupReader struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewRollupReader(
+	ctx context.Context,
+	tx *sql.Tx,
+) *RollupReader {
+	return &RollupReader{tx: tx, ctx: ctx}
+}
+
+// rollupConditions are the conditions of filter over the rollups. A bucket
+// starting at filter.Before holds logs past it, so Before is exclusive.
+func rollupConditions(filter LogFilter) squirrel.And {
+	before := filter.Before
+	filter.Before = nil
+
+	ret := filter.conditions()
+	if before != nil {
+		ret = append(ret, squirrel.Lt{"created_at": *before})
+	}
+	return ret
+}
+
+// ReadStats counts the logs matching filter grouped by fields and, unless
+// interval is empty, by the interval they were appended in, truncated in
+// the time zone tz. filter must not have row-level conditions and its
+// Before is exclusive. Along with
+// up to limit points it returns the totals of every point.
+func (r *RollupReader) ReadStats(
+	filter LogFilter,
+	fields []StatsField,
+	interval string,
+	tz string,
+	limit uint64,
+) ([]model.StatsPoint, uint64, uint64, error) {
+	q := squirrel.Select().From(rollupLogs)
+
+	groups := 0
+	if interval != "" {
+		q = q.Column(squirrel.Expr(
+			"date_trunc(?::text, created_at AT TIME ZONE ?::text) AT TIME ZONE ?::text", interval, tz, tz))
+		groups++
+	}
+	for _, field := range fields {
+		q = q.Column(string(field))
+		groups++
+	}
+	q = q.Columns(
+		"coalesce(sum(count), 0)",
+		"coalesce(sum(bytes), 0)",
+		"coalesce(sum(sum(count)) OVER (), 0)",
+		"coalesce(sum(sum(bytes)) OVER (), 0)",
+	)
+
+	for _, c := range rollupConditions(filter) {
+		q = q.Where(c)
+	}
+
+	order := make([]string, 0, groups+1)
+	for i := 1; i <= groups; i++ {
+		q = q.GroupBy(strconv.Itoa(i))
+		order = append(order, strconv.Itoa(i))
+	}
+	if interval == "" {
+		order = append([]string{"sum(count) DESC"}, order...)
+	}
+	q = q.OrderBy(order...).Limit(limit)
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.StatsPoint, 0)
+	var count, bytes uint64
+	for rows.Next() {
+		var entry model.StatsPoint
+		dst := make([]any, 0, groups+4)
+		if interval != "" {
+			dst = append(dst, &entry.Bucket)
+		}
+		for _, field := range fields {
+			switch field {
+			case StatsSource:
+				dst = append(dst, &entry.Source)
+			case StatsLogLevel:
+				dst = append(dst, &entry.LogLevel)
+			case StatsLoggerName:
+				dst = append(dst, &entry.LoggerName)
+			}
+		}
+		dst = append(dst, &entry.Count, &entry.Bytes, &count, &bytes)
+
+		err := rows.Scan(dst...)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		ret = append(ret, entry)
+	}
+	return ret, count, bytes, rows.Err()
+}
+
+// bucketEnd is the end of the bucket of a rollup.
+const bucketEnd = `created_at + CASE resolution
+	WHEN 'minute' THEN interval '1 minute'
+	WHEN 'hour' THEN interval '1 hour'
+	ELSE interval '1 day'
+END`
+
+// ReadResolution returns the coarsest resolution of the rollups matching
+// filter whose buckets overlap its time bounds, "" when there are none.
+func (r *RollupReader) ReadResolution(filter LogFilter) (string, error) {
+	after := filter.After
+	filter.After = nil
+
+	q := squirrel.Select("resolution").From(rollupLogs).Where(rollupConditions(filter))
+	if after != nil {
+		q = q.Where(squirrel.Expr(bucketEnd+" > ?", *after))
+	}
+	q = q.GroupBy("resolution")
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var ret string
+	for rows.Next() {
+		var resolution string
+		err := rows.Scan(&resolution)
+		if err != nil {
+			return "", err
+		}
+		if model.ResolutionStep(resolution) > model.ResolutionStep(ret) {
+			ret = resolution
+		}
+	}
+	return ret, rows.Err()
+}
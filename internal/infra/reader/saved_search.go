@@ -0,0 +1,98 @@
+package reader
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"log_shelter/internal/model"
+)
+
+type SavedSearchReader struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewSavedSearchReader(
+	ctx context.Context,
+	tx *sql.Tx,
+) *SavedSearchReader {
+	return &SavedSearchReader{tx: tx, ctx: ctx}
+}
+
+func (r *SavedSearchReader) selectSavedSearches() squirrel.SelectBuilder {
+	return squirrel.Select(
+		"id",
+		"name",
+		"query",
+		"owner",
+		"description",
+		"tags",
+		"created_at",
+		"updated_at",
+	).From("saved_searches")
+}
+
+func (r *SavedSearchReader) query(q squirrel.SelectBuilder) ([]model.SavedSearch, error) {
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]model.SavedSearch, 0)
+
+	for rows.Next() {
+		var entry model.SavedSearch
+		var query []byte
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Name,
+			&query,
+			&entry.Owner,
+			&entry.Description,
+			pq.Array(&entry.Tags),
+			&entry.CreatedAt,
+			&entry.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.Query = query
+		ret = append(ret, entry)
+	}
+
+	return ret, rows.Err()
+}
+
+func (r *SavedSearchReader) ReadSavedSearch(name string) (*model.SavedSearch, error) {
+	ret, err := r.query(r.selectSavedSearches().Where(squirrel.Eq{"name": name}))
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) == 0 {
+		return nil, model.ErrSavedSearchNotFound
+	}
+	return &ret[0], nil
+}
+
+func (r *SavedSearchReader) ReadSavedSearches(
+	owner *string,
+	tags []string,
+) ([]model.SavedSearch, error) {
+	q := r.selectSavedSearches()
+	if owner != nil {
+		q = q.Where(squirrel.Eq{"owner": *owner})
+	}
+	if len(tags) != 0 {
+		q = q.Where("tags @> ?", pq.Array(tags))
+	}
+	return r.query(q.OrderBy("name ASC"))
+}
@@ -0,0 +1,107 @@
+package reader
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+type SimilarMethod string
+
+const (
+	// SimilarTrigram ranks logs by pg_trgm similarity of their normalized
+	// messages.
+	SimilarTrigram SimilarMethod = "trigram"
+	// SimilarFingerprint matches logs whose normalized message is equal.
+	SimilarFingerprint SimilarMethod = "fingerprint"
+)
+
+// ReadSimilar finds logs other than anchor whose normalized message is
+// similar to the one of anchor, most similar and most recent first.
+func (r *LogReader) ReadSimilar(
+	anchor model.LogModel,
+	filter LogFilter,
+	method SimilarMethod,
+	threshold float64,
+	limit uint64,
+) ([]model.SimilarLog, error) {
+	err := r.checkFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor_message := normalizedMessage("?")
+
+	q := squirrel.Select(logColumns...).From("logs")
+	switch method {
+	case SimilarTrigram:
+		// % uses the trigram index, but only with its threshold set for
+		// the transaction.
+		_, err = r.tx.ExecContext(r.ctx,
+			"SELECT set_config('pg_trgm.similarity_threshold', $1, true)",
+			strconv.FormatFloat(threshold, 'f', -1, 64))
+		if err != nil {
+			return nil, err
+		}
+		q = q.Column(squirrel.Expr(
+			"similarity("+messageExpr+", "+anchor_message+") AS similarity", anchor.RawLog)).
+			Where(squirrel.Expr(messageExpr+" % "+anchor_message, anchor.RawLog))
+	case SimilarFingerprint:
+		q = q.Column("1.0 AS similarity").
+			Where(squirrel.Expr("md5("+messageExpr+") = md5("+anchor_message+")", anchor.RawLog))
+	default:
+		return nil, fmt.Errorf("%w: unknown similarity method %q", model.ErrInvalidRequest, method)
+	}
+
+	q = filter.Apply(q)
+
+	q = q.Where(squirrel.NotEq{"id": anchor.ID}).
+		OrderBy("similarity DESC", "created_at DESC", "id DESC").
+		Limit(limit)
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.checkCost(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.queryContext()
+	defer cancel()
+
+	rows, err := r.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.timeoutError(ctx, err)
+	}
+	defer rows.Close()
+
+	ret := make([]model.SimilarLog, 0)
+
+	for rows.Next() {
+		var similarity float64
+		entry, err := scanLog(rows, &similarity)
+		if err != nil {
+			return nil, r.timeoutError(ctx, err)
+		}
+		ret = append(ret, model.SimilarLog{LogModel: entry, Similarity: similarity})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, r.timeoutError(ctx, err)
+	}
+
+	keys := r.keyring()
+	for i := range ret {
+		err = unseal(r.ctx, r.tx, keys, &ret[i].LogModel)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
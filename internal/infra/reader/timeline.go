@@ -0,0 +1,282 @@
+package reader
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+const (
+	CorrelateRequestID  = "request_id"
+	CorrelateLoggerName = "logger_name"
+	CorrelateTraceID    = "trace_id"
+	CorrelateTimeWindow = "time_window"
+
+	attributePrefix = "attributes."
+)
+
+// TimelineQuery selects related rows either by exact Levels or, when no
+// levels are given, by a MinLevel severity threshold.
+type TimelineQuery struct {
+	Levels      []string
+	MinLevel    model.Level
+	CorrelateBy []string
+	Before      time.Duration
+	After       time.Duration
+	// CorrelationWindow bounds the correlations other than the time window
+	// to as long around the anchor, Before and After when zero.
+	CorrelationWindow time.Duration
+	CrossSource       bool
+	// Limit caps the rows of the timeline, keeping the closest to the
+	// anchor. Zero means no limit.
+	Limit uint64
+	// Rehydrated builds the timeline out of logs_rehydrated.
+	Rehydrated bool
+}
+
+// correlation is a single reason for a row to be part of a timeline: the
+// SQL predicate selecting it and the same check applied to a fetched row.
+type correlation struct {
+	label string
+	where squirrel.Sqlizer
+	match func(model.LogModel) bool
+}
+
+func attributeKey(key string) string {
+	return strings.TrimPrefix(key, attributePrefix)
+}
+
+func (q *TimelineQuery) levelWhere() squirrel.Sqlizer {
+	if len(q.Levels) != 0 {
+		return squirrel.Eq{"log_level": q.Levels}
+	}
+	return squirrel.GtOrEq{"level_rank": int16(q.MinLevel)}
+}
+
+func (q *TimelineQuery) levelMatch(l model.LogModel) bool {
+	if len(q.Levels) != 0 {
+		return slices.Contains(q.Levels, l.LogLevel)
+	}
+	level, ok := model.ParseLevel(l.LogLevel)
+	return ok && level >= q.MinLevel
+}
+
+func (q *TimelineQuery) correlations(anchor model.LogModel) []correlation {
+	ret := make([]correlation, 0, len(q.CorrelateBy)+1)
+
+	for _, key := range q.CorrelateBy {
+		switch key {
+		case CorrelateRequestID:
+			if anchor.RequestID == nil {
+				continue
+			}
+			ret = append(ret, correlation{
+				label: key,
+				where: squirrel.Eq{"request_id": *anchor.RequestID},
+				match: func(l model.LogModel) bool {
+					return l.RequestID != nil && *l.RequestID == *anchor.RequestID
+				},
+			})
+		case CorrelateTraceID:
+			if anchor.TraceID == nil {
+				continue
+			}
+			ret = append(ret, correlation{
+				label: key,
+				where: squirrel.Eq{"trace_id": *anchor.TraceID},
+				match: func(l model.LogModel) bool {
+					return l.TraceID != nil && *l.TraceID == *anchor.TraceID
+				},
+			})
+		case CorrelateLoggerName:
+			if anchor.LoggerName == "" {
+				continue
+			}
+			ret = append(ret, correlation{
+				label: key,
+				where: squirrel.Eq{"logger_name": anchor.LoggerName},
+				match: func(l model.LogModel) bool {
+					return l.LoggerName == anchor.LoggerName
+				},
+			})
+		default:
+			name := attributeKey(key)
+			value, ok := anchor.Attributes[name]
+			if !ok || value == nil {
+				continue
+			}
+			// Compared as jsonb, as the text of ->> spells numbers
+			// differently from Go (1500000 vs 1.5e+06).
+			raw, err := json.Marshal(value)
+			if err != nil {
+				continue
+			}
+			ret = append(ret, correlation{
+				label: attributePrefix + name,
+				where: squirrel.Expr("attributes -> ? = ?::jsonb", name, string(raw)),
+				match: func(l model.LogModel) bool {
+					v, ok := l.Attributes[name]
+					if !ok || v == nil {
+						return false
+					}
+					other, err := json.Marshal(v)
+					return err == nil && bytes.Equal(other, raw)
+				},
+			})
+		}
+	}
+
+	// Correlations are bounded too, a request_id or logger_name may span
+	// the whole table.
+	from, to := q.CorrelationBounds(anchor)
+	for i, c := range ret {
+		ret[i].where = squirrel.And{
+			c.where,
+			squirrel.GtOrEq{"created_at": from},
+			squirrel.LtOrEq{"created_at": to},
+		}
+		ret[i].match = func(l model.LogModel) bool {
+			return !l.CreatedAt.Before(from) && !l.CreatedAt.After(to) && c.match(l)
+		}
+	}
+
+	from = anchor.CreatedAt.Add(-q.Before)
+	to = anchor.CreatedAt.Add(q.After)
+	window := squirrel.And{
+		squirrel.GtOrEq{"created_at": from},
+		squirrel.LtOrEq{"created_at": to},
+	}
+	if !q.CrossSource {
+		window = append(window, squirrel.Eq{"source": anchor.Source})
+	}
+	ret = append(ret, correlation{
+		label: CorrelateTimeWindow,
+		where: window,
+		match: func(l model.LogModel) bool {
+			if !q.CrossSource && l.Source != anchor.Source {
+				return false
+			}
+			return !l.CreatedAt.Before(from) && !l.CreatedAt.After(to)
+		},
+	})
+
+	return ret
+}
+
+// CorrelationBounds returns the time range around anchor the correlations
+// other than the time window are bounded to.
+func (q TimelineQuery) CorrelationBounds(anchor model.LogModel) (time.Time, time.Time) {
+	if q.CorrelationWindow != 0 {
+		return anchor.CreatedAt.Add(-q.CorrelationWindow), anchor.CreatedAt.Add(q.CorrelationWindow)
+	}
+	return anchor.CreatedAt.Add(-q.Before), anchor.CreatedAt.Add(q.After)
+}
+
+// Window returns the time range the timeline of anchor reads, covering the
+// time window and the correlation bounds, as a filter so it goes through
+// the same guardrails as other reads.
+func (q TimelineQuery) Window(anchor model.LogModel) LogFilter {
+	from, to := q.CorrelationBounds(anchor)
+	if before := anchor.CreatedAt.Add(-q.Before); before.Before(from) {
+		from = before
+	}
+	if after := anchor.CreatedAt.Add(q.After); after.After(to) {
+		to = after
+	}
+	return LogFilter{After: &from, Before: &to}
+}
+
+// Keep returns the timeline of anchor out of the rows read for it, closest
+// to the anchor first, leaving out the one past the limit that tells it
+// was truncated.
+func (q TimelineQuery) Keep(
+	anchor model.LogModel,
+	logs []model.LogModel,
+	reasons func(model.LogModel) []string,
+) *model.Timeline {
+	ret := &model.Timeline{Entries: make([]model.TimelineEntry, 0, len(logs))}
+	if q.Limit != 0 && uint64(len(logs)) > q.Limit {
+		logs, ret.Truncated = logs[:q.Limit], true
+	}
+	for _, l := range logs {
+		ret.Entries = append(ret.Entries, model.TimelineEntry{
+			LogModel: l,
+			Anchor:   l.ID == anchor.ID,
+			Reasons:  reasons(l),
+		})
+	}
+	slices.SortFunc(ret.Entries, func(a, b model.TimelineEntry) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return ret
+}
+
+func (r *LogReader) GetTimeLineFor(
+	anchor model.LogModel,
+	query TimelineQuery,
+) (*model.Timeline, error) {
+	err := r.guard.CheckFilter(query.Window(anchor))
+	if err != nil {
+		return nil, err
+	}
+	correlations := query.correlations(anchor)
+
+	related := squirrel.Or{}
+	for _, c := range correlations {
+		related = append(related, c.where)
+	}
+
+	// Closest to the anchor first, so the limit cuts the far ends.
+	q := squirrel.Select(logColumns...).From(logsTable(query.Rehydrated)).
+		Where(squirrel.Or{
+			squirrel.Eq{"id": anchor.ID},
+			squirrel.And{query.levelWhere(), related},
+		}).
+		OrderByClause("id = ? DESC", anchor.ID).
+		OrderByClause("abs(extract(epoch FROM created_at - ?::timestamptz))", anchor.CreatedAt).
+		OrderBy("id ASC")
+	if !query.Rehydrated {
+		q = q.Where(squirrel.Eq{"is_deleted": false})
+	}
+	if query.Limit != 0 {
+		q = q.Limit(query.Limit + 1)
+	}
+
+	logs, err := r.queryLogs(q, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Keep(anchor, logs, func(l model.LogModel) []string {
+		return reasons(correlations, query, l)
+	}), nil
+}
+
+func reasons(correlations []correlation, query TimelineQuery, l model.LogModel) []string {
+	ret := make([]string, 0)
+	if !query.levelMatch(l) {
+		return ret
+	}
+	for _, c := range correlations {
+		if c.match(l) {
+			ret = append(ret, c.label)
+		}
+	}
+	return ret
+}
+
+// Reasons lists why l belongs to the timeline of anchor, for backends that
+// select the candidate rows themselves.
+func (q TimelineQuery) Reasons(anchor model.LogModel, l model.LogModel) []string {
+	return reasons(q.correlations(anchor), q, l)
+}
@@ -0,0 +1,149 @@
+package reader
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+type TopField string
+
+const (
+	TopSource     TopField = "source"
+	TopLogLevel   TopField = "log_level"
+	TopLoggerName TopField = "logger_name"
+	TopRequestID  TopField = "request_id"
+	TopMessage    TopField = "message"
+)
+
+type TopRank string
+
+const (
+	TopRankCount TopRank = "count"
+	TopRankDelta TopRank = "delta"
+)
+
+// normalizedMessage turns the text expression into a message pattern by
+// masking UUIDs, long hex strings and numbers, so the same message with
+// different ids falls into one group. The patterns avoid "?", which
+// squirrel takes for a placeholder, so text may be "?" itself.
+func normalizedMessage(text string) string {
+	return `left(regexp_replace(regexp_replace(regexp_replace(` + text + `, ` +
+		`'[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}', '<uuid>', 'g'), ` +
+		`'\m(0x|)[0-9a-fA-F]{16,}\M', '<hex>', 'g'), ` +
+		`'\d+(\.\d+)*', '<num>', 'g'), 256)`
+}
+
+var messageExpr = normalizedMessage("raw_log")
+
+func (f TopField) expr() (string, error) {
+	switch f {
+	case TopSource, TopLogLevel, TopLoggerName, TopRequestID:
+		return string(f), nil
+	case TopMessage:
+		return messageExpr, nil
+	default:
+		return "", fmt.Errorf("%w: unknown top field %q", model.ErrInvalidRequest, f)
+	}
+}
+
+// ReadTop counts the values of field within the filter window and within
+// the previous window of the same length, ranked by count or by growth.
+// The filter has to have an "after" bound, "before" defaults to now.
+func (r *LogReader) ReadTop(
+	field TopField,
+	filter LogFilter,
+	rank TopRank,
+	limit uint64,
+) (*model.Top, error) {
+	column, err := field.expr()
+	if err != nil {
+		return nil, err
+	}
+	if filter.After == nil {
+		return nil, fmt.Errorf("%w: top needs an \"after\" time bound", model.ErrInvalidRequest)
+	}
+	from := *filter.After
+	to := time.Now()
+	if filter.Before != nil {
+		to = *filter.Before
+	}
+	previous_from := from.Add(-to.Sub(from))
+
+	both := filter
+	both.After = &previous_from
+	both.Before = &to
+	// Both windows are scanned, so both count against MaxTimeRange.
+	err = r.checkFilter(both)
+	if err != nil {
+		return nil, err
+	}
+
+	current := "COUNT(*) FILTER (WHERE created_at >= ?)"
+	previous := "COUNT(*) FILTER (WHERE created_at < ?)"
+
+	q := squirrel.Select(column + " AS value").
+		Column(squirrel.Expr(current, from)).
+		Column(squirrel.Expr(previous, from)).
+		From("logs")
+
+	q = both.Apply(q)
+
+	if field != TopMessage {
+		q = q.Where(squirrel.NotEq{column: nil})
+	}
+
+	q = q.GroupBy("value").Having(current+" > 0", from)
+	if rank == TopRankDelta {
+		q = q.OrderByClause(current+" - "+previous+" DESC", from, from)
+	} else {
+		q = q.OrderByClause(current+" DESC", from)
+	}
+	q = q.OrderBy("value ASC").Limit(limit)
+
+	query, args, err := q.PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.checkCost(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.queryContext()
+	defer cancel()
+
+	rows, err := r.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.timeoutError(ctx, err)
+	}
+	defer rows.Close()
+
+	ret := model.Top{
+		Field:        string(field),
+		From:         from,
+		To:           to,
+		PreviousFrom: previous_from,
+		Values:       make([]model.TopValue, 0),
+	}
+
+	for rows.Next() {
+		var entry model.TopValue
+		err := rows.Scan(&entry.Value, &entry.Count, &entry.Previous)
+		if err != nil {
+			return nil, err
+		}
+		entry.Delta = entry.Count - entry.Previous
+		if entry.Previous != 0 {
+			ratio := float64(entry.Count) / float64(entry.Previous)
+			entry.Ratio = &ratio
+		}
+		ret.Values = append(ret.Values, entry)
+	}
+
+	return &ret, r.timeoutError(ctx, rows.Err())
+}
@@ -0,0 +1,29 @@
+package reader
+
+import (
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+// ReadTrace returns every log of the trace or of the request, ordered by
+// time. Either of the IDs may be nil, but not both.
+func (r *LogReader) ReadTrace(
+	trace_id *string,
+	request_id *string,
+) ([]model.LogModel, error) {
+	related := squirrel.Or{}
+	if trace_id != nil {
+		related = append(related, squirrel.Eq{"trace_id": *trace_id})
+	}
+	if request_id != nil {
+		related = append(related, squirrel.Eq{"request_id": *request_id})
+	}
+
+	q := squirrel.Select(logColumns...).From("logs").
+		Where(squirrel.Eq{"is_deleted": false}).
+		Where(related).
+		OrderBy("created_at ASC", "id ASC")
+
+	return r.queryLogs(q, true)
+}
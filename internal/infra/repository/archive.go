@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"log_shelter/internal/model"
+)
+
+type ArchiveRepository struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewArchiveRepository(
+	ctx context.Context,
+	tx *sql.Tx,
+) *ArchiveRepository {
+	return &ArchiveRepository{tx: tx, ctx: ctx}
+}
+
+// RecordSegment adds the segment to the manifest, replacing the entry of a
+// segment rewritten under the same key, and returns its id.
+func (r *ArchiveRepository) RecordSegment(segment model.ArchiveSegment) (uint64, error) {
+	q, args, err := squirrel.Insert("archive_segments").Columns(
+		"key",
+		"source",
+		"day",
+		"format",
+		"row_count",
+		"size_bytes",
+		"sha256",
+		"first_id",
+		"last_id",
+		"first_created_at",
+		"last_created_at",
+		"data_key_ids",
+	).Values(
+		segment.Key,
+		segment.Source,
+		segment.Day,
+		segment.Format,
+		segment.Rows,
+		segment.SizeBytes,
+		segment.SHA256,
+		segment.FirstID,
+		segment.LastID,
+		segment.FirstCreatedAt,
+		segment.LastCreatedAt,
+		pq.Array(segment.DataKeyIDs),
+	).Suffix(`
+		ON CONFLICT (key) DO UPDATE SET
+			row_count = excluded.row_count,
+			size_bytes = excluded.size_bytes,
+			sha256 = excluded.sha256,
+			first_created_at = excluded.first_created_at,
+			last_created_at = excluded.last_created_at,
+			data_key_ids = excluded.data_key_ids,
+			archived_at = now()
+		RETURNING id
+	`).PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var id uint64
+	err = r.tx.QueryRowContext(r.ctx, q, args...).Scan(&id)
+	return id, err
+}
+
+// RehydrateLogs copies archived logs into logs_rehydrated, skipping the
+// ones already there, and returns how many were added.
+func (r *ArchiveRepository) RehydrateLogs(segment_id uint64, logs []model.LogModel) (int64, error) {
+	if len(logs) == 0 {
+		return 0, nil
+	}
+
+	q := squirrel.Insert("logs_rehydrated").Columns(
+		"id",
+		"raw_log",
+		"log_level",
+		"raw_level",
+		"level_rank",
+		"source",
+		"created_at",
+		"request_id",
+		"logger_name",
+		"attributes",
+		"trace_id",
+		"span_id",
+		"parent_span_id",
+		"is_deleted",
+		"deleted_at",
+		"data_key_id",
+		"raw_log_enc",
+		"attributes_enc",
+		"segment_id",
+	)
+	for _, l := range logs {
+		var level_rank *int16
+		if level, ok := model.ParseLevel(l.LogLevel); ok {
+			rank := int16(level)
+			level_rank = &rank
+		}
+		var logger_name *string
+		if l.LoggerName != "" {
+			logger_name = &l.LoggerName
+		}
+		var attributes *string
+		if len(l.Attributes) != 0 {
+			data, err := json.Marshal(l.Attributes)
+			if err != nil {
+				return 0, err
+			}
+			tmp := string(data)
+			attributes = &tmp
+		}
+		// Encrypted logs come back sealed, as they were stored.
+		var data_key_id *uint64
+		var raw_log_enc, attributes_enc []byte
+		if l.Sealed != nil {
+			data_key_id = &l.Sealed.DataKeyID
+			raw_log_enc, attributes_enc = l.Sealed.RawLog, l.Sealed.Attributes
+		}
+		q = q.Values(
+			l.ID,
+			l.RawLog,
+			l.LogLevel,
+			l.RawLevel,
+			level_rank,
+			l.Source,
+			l.CreatedAt,
+			l.RequestID,
+			logger_name,
+			attributes,
+			l.TraceID,
+			l.SpanID,
+			l.ParentSpanID,
+			l.IsDeleted,
+			l.DeletedAt,
+			data_key_id,
+			raw_log_enc,
+			attributes_enc,
+			segment_id,
+		)
+	}
+
+	query, args, err := q.Suffix("ON CONFLICT (id) DO NOTHING").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return 0, err
+	}
+	res, err := r.tx.ExecContext(r.ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
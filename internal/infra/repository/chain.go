@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"log_shelter/internal/infra/integrity"
+	"log_shelter/internal/model"
+)
+
+type ChainRepository struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewChainRepository(
+	ctx context.Context,
+	tx *sql.Tx,
+) *ChainRepository {
+	return &ChainRepository{tx: tx, ctx: ctx}
+}
+
+// LockHead returns the newest link of the chain of source, starting the
+// chain if needed, and locks it until the transaction ends so appends to
+// the chain are serialized.
+func (r *ChainRepository) LockHead(source string) (model.ChainLink, error) {
+	var ret model.ChainLink
+
+	_, err := r.tx.ExecContext(r.ctx, `
+		INSERT INTO chain_heads (source, seq, hash) VALUES ($1, 0, $2)
+		ON CONFLICT (source) DO NOTHING
+	`, source, integrity.Genesis)
+	if err != nil {
+		return ret, err
+	}
+
+	err = r.tx.QueryRowContext(r.ctx,
+		"SELECT seq, hash FROM chain_heads WHERE source = $1 FOR UPDATE", source,
+	).Scan(&ret.Seq, &ret.Hash)
+	return ret, err
+}
+
+func (r *ChainRepository) AdvanceHead(source string, link model.ChainLink) error {
+	q, args, err := squirrel.Update("chain_heads").
+		Set("seq", link.Seq).
+		Set("hash", link.Hash).
+		Set("updated_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"source": source}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.tx.ExecContext(r.ctx, q, args...)
+	return err
+}
+
+func (r *ChainRepository) RecordCheckpoint(c model.ChainCheckpoint) error {
+	q, args, err := squirrel.Insert("chain_checkpoints").Columns(
+		"source",
+		"seq",
+		"hash",
+		"key_id",
+		"signature",
+		"signed_at",
+	).Values(
+		c.Source,
+		c.Seq,
+		c.Hash,
+		c.KeyID,
+		c.Signature,
+		c.SignedAt,
+	).Suffix("ON CONFLICT (source, seq) DO NOTHING").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.tx.ExecContext(r.ctx, q, args...)
+	return err
+}
+
+// recordTombstones follows a "deleted" CTE returning the source, chain_seq
+// and chain_hash of deleted logs, and records a tombstone for every run of
+// consecutive links among them, keeping the hash of its last link.
+const recordTombstones = `runs AS (
+	SELECT source, chain_seq, chain_hash,
+		chain_seq - row_number() OVER (PARTITION BY source ORDER BY chain_seq) AS run
+	FROM deleted
+	WHERE chain_seq IS NOT NULL
+), tombstones AS (
+	INSERT INTO chain_tombstones (source, from_seq, to_seq, hash)
+	SELECT source, min(chain_seq), max(chain_seq), (array_agg(chain_hash ORDER BY chain_seq DESC))[1]
+	FROM runs
+	GROUP BY source, run
+	RETURNING id
+)`
+
+// deleteChained runs query, a "deleted" CTE followed by recordTombstones,
+// signs the tombstones it recorded with signer unless nil and returns how
+// many logs were deleted.
+func deleteChained(
+	ctx context.Context,
+	tx *sql.Tx,
+	signer *integrity.Signer,
+	query string,
+	args ...any,
+) (int64, error) {
+	var ret int64
+	var ids []int64
+	err := tx.QueryRowContext(ctx,
+		query+" SELECT (SELECT count(*) FROM deleted), coalesce(array_agg(id), '{}') FROM tombstones",
+		args...,
+	).Scan(&ret, pq.Array(&ids))
+	if err != nil || signer == nil || len(ids) == 0 {
+		return ret, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, source, from_seq, to_seq, hash, deleted_at
+		FROM chain_tombstones WHERE id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	tombstones := make([]model.ChainTombstone, 0, len(ids))
+	for rows.Next() {
+		var entry model.ChainTombstone
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Source,
+			&entry.FromSeq,
+			&entry.ToSeq,
+			&entry.Hash,
+			&entry.DeletedAt,
+		)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tombstones = append(tombstones, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, t := range tombstones {
+		signer.SignTombstone(&t)
+		_, err := tx.ExecContext(ctx,
+			"UPDATE chain_tombstones SET key_id = $1, signature = $2 WHERE id = $3",
+			t.KeyID, t.Signature, t.ID,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+)
+
+type DataKeyRepository struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewDataKeyRepository(
+	ctx context.Context,
+	tx *sql.Tx,
+) *DataKeyRepository {
+	return &DataKeyRepository{tx: tx, ctx: ctx}
+}
+
+// CreateDataKey stores the active data key of source, unless a concurrent
+// append created one first.
+func (r *DataKeyRepository) CreateDataKey(source string, wrapped []byte, master_key_id string) error {
+	_, err := r.tx.ExecContext(r.ctx, `
+		INSERT INTO data_keys (source, wrapped_key, master_key_id) VALUES ($1, $2, $3)
+		ON CONFLICT (source) WHERE retired_at IS NULL DO NOTHING
+	`, source, wrapped, master_key_id)
+	return err
+}
+
+func (r *DataKeyRepository) RewrapKey(id uint64, wrapped []byte, master_key_id string) error {
+	q, args, err := squirrel.Update("data_keys").
+		Set("wrapped_key", wrapped).
+		Set("master_key_id", master_key_id).
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.tx.ExecContext(r.ctx, q, args...)
+	return err
+}
+
+func (r *DataKeyRepository) RetireKey(id uint64, at time.Time) error {
+	q, args, err := squirrel.Update("data_keys").
+		Set("retired_at", at).
+		Where(squirrel.Eq{"id": id, "retired_at": nil}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.tx.ExecContext(r.ctx, q, args...)
+	return err
+}
+
+func (r *DataKeyRepository) DeleteKey(id uint64) error {
+	q, args, err := squirrel.Delete("data_keys").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.tx.ExecContext(r.ctx, q, args...)
+	return err
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/model"
+)
+
+type LegalHoldRepository struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewLegalHoldRepository(
+	ctx context.Context,
+	tx *sql.Tx,
+) *LegalHoldRepository {
+	return &LegalHoldRepository{tx: tx, ctx: ctx}
+}
+
+func (r *LegalHoldRepository) CreateHold(
+	reason string,
+	created_by string,
+	expires_at *time.Time,
+	source *string,
+	request_id *string,
+	from *time.Time,
+	to *time.Time,
+) (uint64, error) {
+	q, args, err := squirrel.Insert("legal_holds").Columns(
+		"reason",
+		"created_by",
+		"expires_at",
+		"source",
+		"request_id",
+		"from_time",
+		"to_time",
+	).Values(
+		reason,
+		created_by,
+		expires_at,
+		source,
+		request_id,
+		from,
+		to,
+	).Suffix("RETURNING id").PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var id uint64
+	err = r.tx.QueryRowContext(r.ctx, q, args...).Scan(&id)
+	return id, err
+}
+
+// ReleaseHold lifts a hold that is still in force.
+func (r *LegalHoldRepository) ReleaseHold(id uint64, released_by string) error {
+	q, args, err := squirrel.Update("legal_holds").
+		Set("released_by", released_by).
+		Set("released_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"id": id, "released_at": nil}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+
+	res, err := r.tx.ExecContext(r.ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return model.ErrLegalHoldNotFound
+	}
+	return nil
+}
@@ -3,66 +3,117 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/infra/integrity"
+	"log_shelter/internal/model"
 )
 
 type LogRepository struct {
 	ctx context.Context
 	tx  *sql.Tx
+	// signer signs chain tombstones, nil without a signing key.
+	signer *integrity.Signer
 }
 
 func NewLogRepository(
 	ctx context.Context,
 	tx *sql.Tx,
+	signer *integrity.Signer,
 ) *LogRepository {
-	return &LogRepository{tx: tx, ctx: ctx}
+	return &LogRepository{tx: tx, ctx: ctx, signer: signer}
 }
 
 func (r *LogRepository) AppendLog(
 	raw_log string,
 	log_level string,
+	raw_level string,
+	level_rank *int16,
 	source string,
 	created_at time.Time,
 	request_id *string,
 	logger_name *string,
+	attributes map[string]any,
+	trace_id *string,
+	span_id *string,
+	parent_span_id *string,
+	chain *model.ChainLink,
+	sealed *model.Sealed,
 ) error {
-	q, args, err := squirrel.Insert("logs").Columns(
+	var attributes_json *string
+	if len(attributes) != 0 {
+		data, err := json.Marshal(attributes)
+		if err != nil {
+			return err
+		}
+		tmp := string(data)
+		attributes_json = &tmp
+	}
+
+	columns := []string{
 		"raw_log",
 		"log_level",
+		"raw_level",
+		"level_rank",
 		"source",
 		"created_at",
 		"request_id",
 		"logger_name",
+		"attributes",
+		"trace_id",
+		"span_id",
+		"parent_span_id",
 		"is_deleted",
-	).Values(
+	}
+	values := []any{
 		raw_log,
 		log_level,
+		raw_level,
+		level_rank,
 		source,
 		created_at,
 		request_id,
 		logger_name,
+		attributes_json,
+		trace_id,
+		span_id,
+		parent_span_id,
 		false,
-	).PlaceholderFormat(squirrel.Dollar).ToSql()
+	}
+	if chain != nil {
+		columns = append(columns, "chain_seq", "chain_hash")
+		values = append(values, chain.Seq, chain.Hash)
+	}
+	if sealed != nil {
+		columns = append(columns, "data_key_id", "raw_log_enc", "attributes_enc")
+		values = append(values, sealed.DataKeyID, sealed.RawLog, sealed.Attributes)
+	}
+
+	q, args, err := squirrel.Insert("logs").Columns(columns...).Values(values...).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
 	if err != nil {
 		return err
 	}
-	_, err = r.tx.Query(q, args...)
+	_, err = r.tx.ExecContext(r.ctx, q, args...)
 
 	return err
 }
 
-func (r *LogRepository) RetentOlder(delta time.Duration) error {
-	q := `
-		UPDATE logs
-		SET is_deleted = true
-		WHERE created_at < $1
-		AND is_deleted = false
-		RETURNING id, created_at, is_deleted;
-	`
-	deletion_time := time.Now().UTC().Add(-delta)
-	_, err := r.tx.Query(q, deletion_time)
-
+// ResealLog replaces the encrypted payload of the log id created at
+// created_at, which lets Postgres prune the other partitions.
+func (r *LogRepository) ResealLog(id uint64, created_at time.Time, sealed model.Sealed) error {
+	q, args, err := squirrel.Update("logs").
+		Set("data_key_id", sealed.DataKeyID).
+		Set("raw_log_enc", sealed.RawLog).
+		Set("attributes_enc", sealed.Attributes).
+		Where(squirrel.Eq{"id": id, "created_at": created_at}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.tx.ExecContext(r.ctx, q, args...)
 	return err
 }
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"log_shelter/internal/infra/integrity"
+)
+
+type PartitionRepository struct {
+	ctx context.Context
+	tx  *sql.Tx
+	// signer signs chain tombstones, nil without a signing key.
+	signer *integrity.Signer
+}
+
+func NewPartitionRepository(
+	ctx context.Context,
+	tx *sql.Tx,
+	signer *integrity.Signer,
+) *PartitionRepository {
+	return &PartitionRepository{tx: tx, ctx: ctx, signer: signer}
+}
+
+// CreatePartition creates the logs partition for [from, to). DDL takes no
+// parameters, so the bounds are quoted as literals. It fails when a table
+// of that name exists, which callers check against the partitions first.
+func (r *PartitionRepository) CreatePartition(name string, from time.Time, to time.Time) error {
+	q := fmt.Sprintf(
+		"CREATE TABLE %s PARTITION OF logs FOR VALUES FROM (%s) TO (%s)",
+		pq.QuoteIdentifier(name),
+		pq.QuoteLiteral(from.UTC().Format(time.RFC3339)),
+		pq.QuoteLiteral(to.UTC().Format(time.RFC3339)),
+	)
+	_, err := r.tx.ExecContext(r.ctx, q)
+	return err
+}
+
+// CreatePartitionOver creates the logs partition for [from, to) when the
+// default partition already holds logs of that range, which Postgres
+// refuses to create it over. The default partition is detached, its logs
+// of the range are moved into the new partition and it is attached again.
+// Returns how many logs were moved.
+func (r *PartitionRepository) CreatePartitionOver(
+	name string,
+	default_name string,
+	from time.Time,
+	to time.Time,
+) (int64, error) {
+	_, err := r.tx.ExecContext(r.ctx,
+		"ALTER TABLE logs DETACH PARTITION "+pq.QuoteIdentifier(default_name))
+	if err != nil {
+		return 0, err
+	}
+
+	err = r.CreatePartition(name, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.tx.ExecContext(r.ctx, fmt.Sprintf(`
+		WITH moved AS (
+			DELETE FROM %s WHERE created_at >= $1 AND created_at < $2 RETURNING *
+		)
+		INSERT INTO %s SELECT * FROM moved`,
+		pq.QuoteIdentifier(default_name),
+		pq.QuoteIdentifier(name),
+	), from, to)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = r.tx.ExecContext(r.ctx,
+		"ALTER TABLE logs ATTACH PARTITION "+pq.QuoteIdentifier(default_name)+" DEFAULT")
+	return moved, err
+}
+
+// DetachPartitionConcurrently detaches the partition from logs without
+// locking out reads and writes of the other partitions. Postgres only does
+// so outside of transactions, hence the connection of its own, and refuses
+// to while logs has a default partition. A detach interrupted earlier is
+// finalized instead.
+func DetachPartitionConcurrently(ctx context.Context, conn *sql.Conn, name string) error {
+	var pending bool
+	err := conn.QueryRowContext(ctx,
+		"SELECT inhdetachpending FROM pg_inherits WHERE inhrelid = to_regclass($1)",
+		pq.QuoteIdentifier(name),
+	).Scan(&pending)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	mode := "CONCURRENTLY"
+	if pending {
+		mode = "FINALIZE"
+	}
+	_, err = conn.ExecContext(ctx, "ALTER TABLE logs DETACH PARTITION "+pq.QuoteIdentifier(name)+" "+mode)
+	return err
+}
+
+// DropPartition drops the partition with all of its rows, leaving signed
+// chain tombstones in place of the chained ones.
+func (r *PartitionRepository) DropPartition(name string) error {
+	var exists bool
+	err := r.tx.QueryRowContext(r.ctx, "SELECT to_regclass($1) IS NOT NULL", pq.QuoteIdentifier(name)).Scan(&exists)
+	if err != nil || !exists {
+		return err
+	}
+
+	_, err = deleteChained(r.ctx, r.tx, r.signer, fmt.Sprintf(
+		"WITH deleted AS (SELECT source, chain_seq, chain_hash FROM %s), %s",
+		pq.QuoteIdentifier(name), recordTombstones,
+	))
+	if err != nil {
+		return err
+	}
+
+	_, err = r.tx.ExecContext(r.ctx, "DROP TABLE IF EXISTS "+pq.QuoteIdentifier(name))
+	return err
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"log_shelter/internal/infra/reader"
+)
+
+// RestoreLogs undeletes the soft-deleted logs matching where, keeps them
+// from retention until retain_until and returns their ids.
+func (r *LogRepository) RestoreLogs(where squirrel.Sqlizer, retain_until time.Time) ([]int64, error) {
+	q, args, err := squirrel.Update("logs").
+		Set("is_deleted", false).
+		Set("deleted_at", nil).
+		Set("retain_until", retain_until).
+		Where(squirrel.Eq{"is_deleted": true}).
+		Where(where).
+		Suffix("RETURNING id").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.tx.QueryContext(r.ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, id)
+	}
+	return ret, rows.Err()
+}
+
+func (r *LogRepository) RecordRestore(
+	restored_by string,
+	reason *string,
+	request []byte,
+	log_ids []int64,
+) (uint64, error) {
+	q, args, err := squirrel.Insert("log_restores").Columns(
+		"restored_by",
+		"reason",
+		"request",
+		"log_ids",
+	).Values(
+		restored_by,
+		reason,
+		string(request),
+		pq.Array(log_ids),
+	).Suffix("RETURNING id").PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var id uint64
+	err = r.tx.QueryRowContext(r.ctx, q, args...).Scan(&id)
+	return id, err
+}
+
+// PurgeDeleted physically deletes at most limit logs that were soft-deleted
+// more than grace ago, skipping held ones, and returns how many were
+// removed.
+func (r *LogRepository) PurgeDeleted(grace time.Duration, limit uint64) (int64, error) {
+	purge_time := time.Now().UTC().Add(-grace)
+	ids := squirrel.Select("id").From("logs").
+		Where(reader.Purgeable(purge_time)).
+		Limit(limit)
+
+	return r.Expire(squirrel.Expr("id IN (?)", ids), true)
+}
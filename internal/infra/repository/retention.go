@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/infra/reader"
+)
+
+// Expire deletes the logs matching where, or only marks them deleted
+// unless hard, and returns how many were affected.
+func (r *LogRepository) Expire(where squirrel.Sqlizer, hard bool) (int64, error) {
+	if hard {
+		return r.delete(where)
+	}
+
+	q, args, err := squirrel.Update("logs").
+		Set("is_deleted", true).
+		Set("deleted_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"is_deleted": false}).
+		Where(where).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.tx.ExecContext(r.ctx, q, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// delete deletes the logs matching where, leaving signed chain tombstones
+// in place of the chained ones so their chains still verify.
+func (r *LogRepository) delete(where squirrel.Sqlizer) (int64, error) {
+	del, args, err := squirrel.Delete("logs").
+		Where(where).
+		Suffix("RETURNING source, chain_seq, chain_hash").ToSql()
+	if err != nil {
+		return 0, err
+	}
+	q, err := squirrel.Dollar.ReplacePlaceholders(
+		"WITH deleted AS (" + del + "), " + recordTombstones)
+	if err != nil {
+		return 0, err
+	}
+	return deleteChained(r.ctx, r.tx, r.signer, q, args...)
+}
+
+// DeleteLogs deletes the logs with the given ids for good.
+func (r *LogRepository) DeleteLogs(ids []uint64) (int64, error) {
+	return r.Expire(squirrel.Eq{"id": ids}, true)
+}
+
+// ExpireLogs applies a retention limit to the logs in scope.
+func (r *LogRepository) ExpireLogs(
+	scope reader.RetentionScope,
+	limit reader.RetentionLimit,
+	hard bool,
+) (int64, error) {
+	return r.Expire(reader.RetentionWhere(scope, limit), hard)
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"log_shelter/internal/model"
+)
+
+type RollupRepository struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewRollupRepository(
+	ctx context.Context,
+	tx *sql.Tx,
+) *RollupRepository {
+	return &RollupRepository{tx: tx, ctx: ctx}
+}
+
+// CountLog adds a log of size bytes to the rollup of its minute.
+func (r *RollupRepository) CountLog(
+	created_at time.Time,
+	source string,
+	log_level string,
+	level_rank *int16,
+	logger_name *string,
+	size int,
+) error {
+	name := ""
+	if logger_name != nil {
+		name = *logger_name
+	}
+	_, err := r.tx.ExecContext(r.ctx, `
+		INSERT INTO log_rollups AS r
+			(resolution, bucket, source, log_level, level_rank, logger_name, count, bytes)
+		VALUES ($1, $2, $3, $4, $5, $6, 1, $7)
+		ON CONFLICT (resolution, bucket, source, log_level, logger_name) DO UPDATE
+		SET count = r.count + 1, bytes = r.bytes + EXCLUDED.bytes
+	`, model.ResolutionMinute, created_at.Truncate(time.Minute), source, log_level, level_rank,
+		name, size)
+	return err
+}
+
+// Downsample folds the rollups of resolution from older than before into
+// rollups of resolution to, an hour or a day bucketed in UTC, and returns
+// how many rows were folded.
+func (r *RollupRepository) Downsample(from string, to string, before time.Time) (int64, error) {
+	var ret int64
+	err := r.tx.QueryRowContext(r.ctx, `
+		WITH moved AS (
+			DELETE FROM log_rollups
+			WHERE resolution = $1 AND bucket < $2
+			RETURNING bucket, source, log_level, level_rank, logger_name, count, bytes
+		), folded AS (
+			INSERT INTO log_rollups AS r
+				(resolution, bucket, source, log_level, level_rank, logger_name, count, bytes)
+			SELECT
+				$3::text,
+				date_trunc($3::text, bucket AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+				source,
+				log_level,
+				max(level_rank),
+				logger_name,
+				sum(count),
+				sum(bytes)
+			FROM moved
+			GROUP BY 2, 3, 4, 6
+			ON CONFLICT (resolution, bucket, source, log_level, logger_name) DO UPDATE
+			SET count = r.count + EXCLUDED.count, bytes = r.bytes + EXCLUDED.bytes
+		)
+		SELECT count(*) FROM moved
+	`, from, before, to).Scan(&ret)
+	return ret, err
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+
+	"log_shelter/internal/model"
+)
+
+const uniqueViolation = "23505"
+
+type SavedSearchRepository struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewSavedSearchRepository(
+	ctx context.Context,
+	tx *sql.Tx,
+) *SavedSearchRepository {
+	return &SavedSearchRepository{tx: tx, ctx: ctx}
+}
+
+func isUniqueViolation(err error) bool {
+	var pq_err *pq.Error
+	return errors.As(err, &pq_err) && pq_err.Code == uniqueViolation
+}
+
+func (r *SavedSearchRepository) CreateSavedSearch(
+	name string,
+	query []byte,
+	owner *string,
+	description *string,
+	tags []string,
+) (uint64, error) {
+	q, args, err := squirrel.Insert("saved_searches").Columns(
+		"name",
+		"query",
+		"owner",
+		"description",
+		"tags",
+	).Values(
+		name,
+		string(query),
+		owner,
+		description,
+		pq.Array(tags),
+	).Suffix("RETURNING id").PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var id uint64
+	err = r.tx.QueryRowContext(r.ctx, q, args...).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, model.ErrSavedSearchExists
+	}
+	return id, err
+}
+
+func (r *SavedSearchRepository) UpdateSavedSearch(
+	name string,
+	query []byte,
+	owner *string,
+	description *string,
+	tags []string,
+) error {
+	q, args, err := squirrel.Update("saved_searches").
+		Set("query", string(query)).
+		Set("owner", owner).
+		Set("description", description).
+		Set("tags", pq.Array(tags)).
+		Set("updated_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"name": name}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+
+	res, err := r.tx.ExecContext(r.ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func (r *SavedSearchRepository) DeleteSavedSearch(name string) error {
+	q, args, err := squirrel.Delete("saved_searches").
+		Where(squirrel.Eq{"name": name}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return err
+	}
+
+	res, err := r.tx.ExecContext(r.ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func expectAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return model.ErrSavedSearchNotFound
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"log_shelter/internal/config"
+	"log_shelter/internal/infra/sqlite"
+	"log_shelter/internal/model"
+)
+
+type SQLiteInfra struct {
+	ctx  context.Context
+	path string
+	db   *sql.DB
+}
+
+func NewSQLiteInfra(ctx context.Context, cfg *config.SQLiteConfig) (*SQLiteInfra, error) {
+	db, err := sqlite.Open(cfg.Path, time.Duration(cfg.BusyTimeout))
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteInfra{ctx: ctx, path: cfg.Path, db: db}, nil
+}
+
+func (s *SQLiteInfra) GetTranscation() (*sql.Tx, error) {
+	return s.db.BeginTx(s.ctx, nil)
+}
+
+func (s *SQLiteInfra) GetConnection() (*sql.Conn, error) {
+	return s.db.Conn(s.ctx)
+}
+
+// GetReadTranscation is GetTranscation, a single file has no replicas.
+func (s *SQLiteInfra) GetReadTranscation() (*sql.Tx, error) {
+	return s.GetTranscation()
+}
+
+func (s *SQLiteInfra) Health() model.Health {
+	ret := model.Health{Status: model.HealthOK, Backend: config.StorageSQLite}
+	h := poolHealth(s.ctx, s.path, "primary", s.db)
+	if !h.Healthy {
+		ret.Status = model.HealthDown
+	}
+	ret.Pools = append(ret.Pools, h)
+	return ret
+}
@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+// The trigram tokenizer can't look up needles shorter than a trigram.
+const minFTSNeedle = 3
+
+func micros(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+func anyOf(values []string) bool {
+	return len(values) == 0 || slices.Contains(values, "*")
+}
+
+// escapeGlob quotes the GLOB metacharacters other than "*".
+func escapeGlob(s string) string {
+	return strings.NewReplacer("[", "[[]", "?", "[?]").Replace(s)
+}
+
+// matchAny matches column against values, where values containing "*" are
+// wildcard patterns such as "payments-*". GLOB is case sensitive, like
+// LIKE in Postgres.
+func matchAny(column string, values []string) squirrel.Sqlizer {
+	exact := make([]string, 0, len(values))
+	ret := squirrel.Or{}
+	for _, v := range values {
+		if strings.Contains(v, "*") {
+			ret = append(ret, squirrel.Expr(column+" GLOB ?", escapeGlob(v)))
+			continue
+		}
+		exact = append(exact, v)
+	}
+	if len(exact) != 0 {
+		ret = append(ret, squirrel.Eq{column: exact})
+	}
+	return ret
+}
+
+func contains(column string, needle string) squirrel.Sqlizer {
+	return squirrel.Expr("instr("+column+", ?) > 0", needle)
+}
+
+// rawLogContains looks needle up in the FTS5 index as a phrase, which the
+// trigram tokenizer matches as a substring.
+func rawLogContains(needle string) squirrel.Sqlizer {
+	if utf8.RuneCountInString(needle) < minFTSNeedle {
+		return contains("raw_log", needle)
+	}
+	phrase := `"` + strings.ReplaceAll(needle, `"`, `""`) + `"`
+	return squirrel.Expr("id IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH ?)", phrase)
+}
+
+// conditions is the SQLite counterpart of reader.LogFilter.Where without
+// the soft-delete condition.
+func conditions(f *reader.LogFilter) squirrel.And {
+	ret := squirrel.And{}
+
+	if !anyOf(f.Sources) {
+		ret = append(ret, matchAny("source", f.Sources))
+	}
+	if !anyOf(f.Levels) {
+		ret = append(ret, squirrel.Eq{"log_level": f.Levels})
+	}
+
+	if f.MinLevel != nil {
+		ret = append(ret, squirrel.GtOrEq{"level_rank": int16(*f.MinLevel)})
+	}
+	if f.MaxLevel != nil {
+		ret = append(ret, squirrel.LtOrEq{"level_rank": int16(*f.MaxLevel)})
+	}
+
+	if f.After != nil {
+		ret = append(ret, squirrel.GtOrEq{"created_at": micros(*f.After)})
+	}
+	if f.Before != nil {
+		ret = append(ret, squirrel.LtOrEq{"created_at": micros(*f.Before)})
+	}
+
+	if f.RequestID != nil {
+		ret = append(ret, squirrel.Eq{"request_id": *f.RequestID})
+	}
+	if f.LoggerName != nil {
+		ret = append(ret, squirrel.Eq{"logger_name": *f.LoggerName})
+	}
+	if !anyOf(f.LoggerNames) {
+		ret = append(ret, matchAny("logger_name", f.LoggerNames))
+	}
+
+	if len(f.ExcludeSources) != 0 {
+		ret = append(ret, squirrel.Expr("NOT ?", matchAny("source", f.ExcludeSources)))
+	}
+	if len(f.ExcludeLevels) != 0 {
+		ret = append(ret, squirrel.NotEq{"log_level": f.ExcludeLevels})
+	}
+	if len(f.ExcludeLoggerNames) != 0 {
+		ret = append(ret, squirrel.Or{
+			squirrel.Eq{"logger_name": nil},
+			squirrel.Expr("NOT ?", matchAny("logger_name", f.ExcludeLoggerNames)),
+		})
+	}
+
+	if f.RawLogContains != nil {
+		ret = append(ret, rawLogContains(*f.RawLogContains))
+	}
+	if f.RawLogRegex != nil {
+		ret = append(ret, squirrel.Expr("raw_log REGEXP ?", *f.RawLogRegex))
+	}
+	if f.LoggerNameContains != nil {
+		ret = append(ret, contains("logger_name", *f.LoggerNameContains))
+	}
+	if f.LoggerNameRegex != nil {
+		ret = append(ret, squirrel.Expr("logger_name REGEXP ?", *f.LoggerNameRegex))
+	}
+
+	if len(f.AnyOf) != 0 {
+		groups := squirrel.Or{}
+		for i := range f.AnyOf {
+			groups = append(groups, conditions(&f.AnyOf[i]))
+		}
+		ret = append(ret, groups)
+	}
+
+	return ret
+}
+
+// where returns the whole filter as a single predicate, including the
+// soft-delete condition.
+func where(f *reader.LogFilter) squirrel.And {
+	ret := squirrel.And{}
+	switch f.Deleted {
+	case reader.DeletedInclude:
+	case reader.DeletedOnly:
+		ret = append(ret, squirrel.Eq{"is_deleted": true})
+	default:
+		ret = append(ret, squirrel.Eq{"is_deleted": false})
+	}
+	return append(ret, conditions(f)...)
+}
+
+// retentionWhere is the SQLite counterpart of reader.RetentionWhere. Bytes
+// are measured as the encoded size of raw_log and attributes.
+func retentionWhere(scope reader.RetentionScope, limit reader.RetentionLimit) squirrel.Sqlizer {
+	in_scope := squirrel.And{where(&scope.Match)}
+	if scope.LiveOnly {
+		in_scope = append(in_scope, squirrel.Eq{"is_deleted": false})
+	}
+	for i := range scope.Exclude {
+		in_scope = append(in_scope, squirrel.Expr("? IS NOT TRUE", where(&scope.Exclude[i])))
+	}
+
+	switch limit.Kind {
+	case model.ExpireMaxRows:
+		return beyond(in_scope, "1", limit.Max)
+	case model.ExpireMaxBytes:
+		return beyond(in_scope,
+			"length(CAST(raw_log AS BLOB)) + coalesce(length(CAST(attributes AS BLOB)), 0)",
+			limit.Max)
+	default:
+		return squirrel.And{in_scope, squirrel.Lt{"created_at": micros(limit.Before)}}
+	}
+}
+
+// beyond matches the logs in scope past the point where the running total
+// of measure per source, from the newest log on, exceeds limit.
+func beyond(scope squirrel.Sqlizer, measure string, limit uint64) squirrel.Sqlizer {
+	ranked := squirrel.Select(
+		"id",
+		"sum("+measure+") OVER (PARTITION BY source ORDER BY created_at DESC, id DESC) AS total",
+	).From("logs").Where(scope)
+
+	ids := squirrel.Select("id").
+		FromSelect(ranked, "ranked").
+		Where(squirrel.Gt{"total": limit})
+
+	return squirrel.Expr("id IN (?)", ids)
+}
@@ -0,0 +1,384 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+// LogStore reads and writes logs of a SQLite database. Guardrails apply
+// like they do in Postgres, except MaxCost as SQLite has no cost estimates.
+type LogStore struct {
+	ctx   context.Context
+	tx    *sql.Tx
+	guard reader.Guardrails
+}
+
+func NewLogStore(
+	ctx context.Context,
+	tx *sql.Tx,
+	guard reader.Guardrails,
+) *LogStore {
+	return &LogStore{tx: tx, ctx: ctx, guard: guard}
+}
+
+var logColumns = []string{
+	"id",
+	"raw_log",
+	"log_level",
+	"raw_level",
+	"source",
+	"created_at",
+	"request_id",
+	"logger_name",
+	"attributes",
+	"trace_id",
+	"span_id",
+	"parent_span_id",
+	"is_deleted",
+	"deleted_at",
+}
+
+func scanLogs(rows *sql.Rows) ([]model.LogModel, error) {
+	defer rows.Close()
+
+	ret := make([]model.LogModel, 0)
+
+	for rows.Next() {
+		var entry model.LogModel
+		var created_at int64
+		var logger_name sql.NullString
+		var attributes sql.NullString
+		var deleted_at sql.NullInt64
+		err := rows.Scan(
+			&entry.ID,
+			&entry.RawLog,
+			&entry.LogLevel,
+			&entry.RawLevel,
+			&entry.Source,
+			&created_at,
+			&entry.RequestID,
+			&logger_name,
+			&attributes,
+			&entry.TraceID,
+			&entry.SpanID,
+			&entry.ParentSpanID,
+			&entry.IsDeleted,
+			&deleted_at,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.CreatedAt = time.UnixMicro(created_at).UTC()
+		entry.LoggerName = logger_name.String
+		if attributes.Valid {
+			err = json.Unmarshal([]byte(attributes.String), &entry.Attributes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if deleted_at.Valid {
+			t := time.UnixMicro(deleted_at.Int64).UTC()
+			entry.DeletedAt = &t
+		}
+		ret = append(ret, entry)
+	}
+
+	return ret, rows.Err()
+}
+
+// queryContext bounds a single statement by StatementTimeout.
+func (s *LogStore) queryContext() (context.Context, context.CancelFunc) {
+	if s.guard.StatementTimeout == 0 {
+		return context.WithCancel(s.ctx)
+	}
+	return context.WithTimeout(s.ctx, s.guard.StatementTimeout)
+}
+
+func (s *LogStore) timeoutError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: statement timeout of %s exceeded",
+			model.ErrQueryRejected, s.guard.StatementTimeout)
+	}
+	return err
+}
+
+func (s *LogStore) queryLogs(q squirrel.SelectBuilder) ([]model.LogModel, error) {
+	query, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := s.queryContext()
+	defer cancel()
+
+	rows, err := s.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, s.timeoutError(ctx, err)
+	}
+
+	ret, err := scanLogs(rows)
+	return ret, s.timeoutError(ctx, err)
+}
+
+func (s *LogStore) exec(q squirrel.Sqlizer) (int64, error) {
+	query, args, err := q.ToSql()
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.tx.ExecContext(s.ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *LogStore) AppendLog(
+	raw_log string,
+	log_level string,
+	raw_level string,
+	level_rank *int16,
+	source string,
+	created_at time.Time,
+	request_id *string,
+	logger_name *string,
+	attributes map[string]any,
+	trace_id *string,
+	span_id *string,
+	parent_span_id *string,
+	chain *model.ChainLink,
+	sealed *model.Sealed,
+) error {
+	if chain != nil {
+		return fmt.Errorf("%w: hash chains need the postgres backend", model.ErrUnsupported)
+	}
+	if sealed != nil {
+		return fmt.Errorf("%w: encryption needs the postgres backend", model.ErrUnsupported)
+	}
+
+	var attributes_json *string
+	if len(attributes) != 0 {
+		data, err := json.Marshal(attributes)
+		if err != nil {
+			return err
+		}
+		tmp := string(data)
+		attributes_json = &tmp
+	}
+
+	_, err := s.exec(squirrel.Insert("logs").Columns(
+		"raw_log",
+		"log_level",
+		"raw_level",
+		"level_rank",
+		"source",
+		"created_at",
+		"request_id",
+		"logger_name",
+		"attributes",
+		"trace_id",
+		"span_id",
+		"parent_span_id",
+		"is_deleted",
+	).Values(
+		raw_log,
+		log_level,
+		raw_level,
+		level_rank,
+		source,
+		micros(created_at),
+		request_id,
+		logger_name,
+		attributes_json,
+		trace_id,
+		span_id,
+		parent_span_id,
+		false,
+	))
+
+	return err
+}
+
+func (s *LogStore) ReadLog(id uint64) (*model.LogModel, error) {
+	q := squirrel.Select(logColumns...).From("logs").
+		Where(squirrel.Eq{"id": id, "is_deleted": false})
+
+	ret, err := s.queryLogs(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) == 0 {
+		return nil, model.ErrLogNotFound
+	}
+	return &ret[0], nil
+}
+
+// ReadRehydratedLog fails, SQLite doesn't keep an archive.
+func (s *LogStore) ReadRehydratedLog(id uint64) (*model.LogModel, error) {
+	return nil, model.ErrUnsupported
+}
+
+func (s *LogStore) ReadLogs(
+	page uint64,
+	page_size *uint64,
+	filter reader.LogFilter,
+	order reader.OrderT,
+) ([]model.LogModel, error) {
+	if filter.Rehydrated {
+		return nil, model.ErrUnsupported
+	}
+	err := s.guard.CheckFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	err = filter.EachRegex(checkRegex)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := s.guard.PageSize(page_size)
+	if err != nil {
+		return nil, err
+	}
+
+	direction := "ASC"
+	if strings.EqualFold(string(order), string(reader.OrderDesc)) {
+		direction = "DESC"
+	}
+
+	q := squirrel.Select(logColumns...).From("logs").
+		Where(where(&filter)).
+		OrderBy("created_at "+direction, "id "+direction).
+		Limit(limit).Offset(max(page, 1)*limit - limit)
+
+	return s.queryLogs(q)
+}
+
+// correlated selects the rows related to anchor by any of the correlation
+// keys of query, see reader.TimelineQuery.
+func correlated(anchor model.LogModel, query reader.TimelineQuery) squirrel.Or {
+	ret := squirrel.Or{}
+
+	for _, key := range query.CorrelateBy {
+		switch key {
+		case reader.CorrelateRequestID:
+			if anchor.RequestID != nil {
+				ret = append(ret, squirrel.Eq{"request_id": *anchor.RequestID})
+			}
+		case reader.CorrelateTraceID:
+			if anchor.TraceID != nil {
+				ret = append(ret, squirrel.Eq{"trace_id": *anchor.TraceID})
+			}
+		case reader.CorrelateLoggerName:
+			if anchor.LoggerName != "" {
+				ret = append(ret, squirrel.Eq{"logger_name": anchor.LoggerName})
+			}
+		default:
+			name := strings.TrimPrefix(key, "attributes.")
+			value, ok := anchor.Attributes[name]
+			if !ok || value == nil {
+				continue
+			}
+			// -> yields the JSON text of the value, which was written
+			// by json.Marshal as well.
+			raw, err := json.Marshal(value)
+			if err != nil {
+				continue
+			}
+			ret = append(ret, squirrel.Expr("attributes -> ? = ?", name, string(raw)))
+		}
+	}
+
+	from, to := query.CorrelationBounds(anchor)
+	bounded := squirrel.And{
+		ret,
+		squirrel.GtOrEq{"created_at": micros(from)},
+		squirrel.LtOrEq{"created_at": micros(to)},
+	}
+
+	window := squirrel.And{
+		squirrel.GtOrEq{"created_at": micros(anchor.CreatedAt.Add(-query.Before))},
+		squirrel.LtOrEq{"created_at": micros(anchor.CreatedAt.Add(query.After))},
+	}
+	if !query.CrossSource {
+		window = append(window, squirrel.Eq{"source": anchor.Source})
+	}
+	return squirrel.Or{bounded, window}
+}
+
+func (s *LogStore) GetTimeLineFor(
+	anchor model.LogModel,
+	query reader.TimelineQuery,
+) (*model.Timeline, error) {
+	if query.Rehydrated {
+		return nil, model.ErrUnsupported
+	}
+	err := s.guard.CheckFilter(query.Window(anchor))
+	if err != nil {
+		return nil, err
+	}
+	var level squirrel.Sqlizer = squirrel.GtOrEq{"level_rank": int16(query.MinLevel)}
+	if len(query.Levels) != 0 {
+		level = squirrel.Eq{"log_level": query.Levels}
+	}
+
+	// Closest to the anchor first, so the limit cuts the far ends.
+	q := squirrel.Select(logColumns...).From("logs").
+		Where(squirrel.Eq{"is_deleted": false}).
+		Where(squirrel.Or{
+			squirrel.Eq{"id": anchor.ID},
+			squirrel.And{level, correlated(anchor, query)},
+		}).
+		OrderByClause("id = ? DESC", anchor.ID).
+		OrderByClause("abs(created_at - ?)", micros(anchor.CreatedAt)).
+		OrderBy("id ASC")
+	if query.Limit != 0 {
+		q = q.Limit(query.Limit + 1)
+	}
+
+	logs, err := s.queryLogs(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Keep(anchor, logs, func(l model.LogModel) []string {
+		return query.Reasons(anchor, l)
+	}), nil
+}
+
+// ExpireLogs applies a retention limit to the logs in scope, deleting them
+// or only marking them deleted unless hard.
+func (s *LogStore) ExpireLogs(
+	scope reader.RetentionScope,
+	limit reader.RetentionLimit,
+	hard bool,
+) (int64, error) {
+	where := retentionWhere(scope, limit)
+	if hard {
+		return s.exec(squirrel.Delete("logs").Where(where))
+	}
+	return s.exec(squirrel.Update("logs").
+		Set("is_deleted", true).
+		Set("deleted_at", micros(time.Now())).
+		Where(squirrel.Eq{"is_deleted": false}).
+		Where(where))
+}
+
+// PurgeDeleted physically deletes at most limit logs that were soft-deleted
+// more than grace ago and returns how many were removed.
+func (s *LogStore) PurgeDeleted(grace time.Duration, limit uint64) (int64, error) {
+	ids := squirrel.Select("id").From("logs").
+		Where(squirrel.Eq{"is_deleted": true}).
+		Where(squirrel.Lt{"deleted_at": micros(time.Now().Add(-grace))}).
+		Limit(limit)
+
+	return s.exec(squirrel.Delete("logs").Where(squirrel.Expr("id IN (?)", ids)))
+}
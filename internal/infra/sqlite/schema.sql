@@ -0,0 +1,47 @@
+-- SQLite schema of the edge backend. Timestamps are unix microseconds, so
+-- they compare and index as plain integers.
+CREATE TABLE IF NOT EXISTS logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    raw_log TEXT NOT NULL,
+    log_level TEXT NOT NULL,
+    raw_level TEXT,
+    level_rank INTEGER,
+    source TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    request_id TEXT,
+    logger_name TEXT,
+    attributes TEXT,
+    trace_id TEXT,
+    span_id TEXT,
+    parent_span_id TEXT,
+    is_deleted INTEGER NOT NULL DEFAULT 0,
+    deleted_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS logs_created_at_idx ON logs (created_at, id);
+CREATE INDEX IF NOT EXISTS logs_source_created_at_idx ON logs (source, created_at);
+CREATE INDEX IF NOT EXISTS logs_request_id_idx ON logs (request_id) WHERE request_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS logs_trace_id_idx ON logs (trace_id) WHERE trace_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS logs_deleted_at_idx ON logs (deleted_at) WHERE is_deleted = 1;
+
+-- Trigram index of raw_log for raw_log_contains, kept in sync with logs
+-- by the triggers below.
+CREATE VIRTUAL TABLE IF NOT EXISTS logs_fts USING fts5(
+    raw_log,
+    content='logs',
+    content_rowid='id',
+    tokenize='trigram case_sensitive 1'
+);
+
+CREATE TRIGGER IF NOT EXISTS logs_fts_insert AFTER INSERT ON logs BEGIN
+    INSERT INTO logs_fts (rowid, raw_log) VALUES (new.id, new.raw_log);
+END;
+
+CREATE TRIGGER IF NOT EXISTS logs_fts_delete AFTER DELETE ON logs BEGIN
+    INSERT INTO logs_fts (logs_fts, rowid, raw_log) VALUES ('delete', old.id, old.raw_log);
+END;
+
+CREATE TRIGGER IF NOT EXISTS logs_fts_update AFTER UPDATE OF raw_log ON logs BEGIN
+    INSERT INTO logs_fts (logs_fts, rowid, raw_log) VALUES ('delete', old.id, old.raw_log);
+    INSERT INTO logs_fts (rowid, raw_log) VALUES (new.id, new.raw_log);
+END;
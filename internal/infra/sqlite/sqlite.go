@@ -0,0 +1,116 @@
+// Package sqlite stores logs in a single SQLite file, for edge and local
+// deployments that can't run Postgres. It implements the storage
+// interfaces of the usecase package: append, search, timeline and
+// retention. Text search uses an FTS5 trigram index, so the binary has to
+// be built with the sqlite_fts5 tag.
+package sqlite
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"log_shelter/internal/model"
+)
+
+const driverName = "sqlite3_log_shelter"
+
+//go:embed schema.sql
+var schema string
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("regexp", regexpMatch, true)
+		},
+	})
+}
+
+const maxCachedRegexps = 256
+
+var (
+	regexps    = make(map[string]*regexp.Regexp)
+	regexps_mu sync.Mutex
+)
+
+// regexpMatch backs the REGEXP operator, which SQLite leaves to the
+// application. NULL values never match.
+func regexpMatch(pattern string, value any) (bool, error) {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	regexps_mu.Lock()
+	re, ok := regexps[pattern]
+	regexps_mu.Unlock()
+	if !ok {
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return false, err
+		}
+		regexps_mu.Lock()
+		if len(regexps) >= maxCachedRegexps {
+			clear(regexps)
+		}
+		regexps[pattern] = re
+		regexps_mu.Unlock()
+	}
+	return re.MatchString(s), nil
+}
+
+// Open opens the database at path, in WAL mode so readers don't block the
+// writer, and creates the schema if needed.
+func Open(path string, busy_timeout time.Duration) (*sql.DB, error) {
+	if busy_timeout == 0 {
+		busy_timeout = 5 * time.Second
+	}
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_synchronous", "NORMAL")
+	params.Set("_busy_timeout", fmt.Sprint(busy_timeout.Milliseconds()))
+
+	db, err := sql.Open(driverName, "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var fts5 bool
+	err = db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5)
+	if err == nil && !fts5 {
+		err = errors.New("sqlite was built without FTS5, build with -tags sqlite_fts5")
+	}
+	if err == nil {
+		_, err = db.Exec(schema)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// checkRegex rejects patterns REGEXP can't compile as invalid requests,
+// rather than failing the query on them.
+func checkRegex(name string, pattern string) error {
+	_, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("%w: invalid %s: %v", model.ErrInvalidRequest, name, err)
+	}
+	return nil
+}
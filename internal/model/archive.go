@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// ArchiveSegment is a manifest entry: one compressed file holding the
+// archived logs of a source for a UTC day.
+type ArchiveSegment struct {
+	ID             uint64    `json:"id"`
+	Key            string    `json:"key"`
+	Source         string    `json:"source"`
+	Day            time.Time `json:"day"`
+	Format         string    `json:"format"`
+	Rows           int64     `json:"rows"`
+	SizeBytes      int64     `json:"size_bytes"`
+	SHA256         string    `json:"sha256"`
+	FirstID        uint64    `json:"first_id"`
+	LastID         uint64    `json:"last_id"`
+	FirstCreatedAt time.Time `json:"first_created_at"`
+	LastCreatedAt  time.Time `json:"last_created_at"`
+	ArchivedAt     time.Time `json:"archived_at"`
+	// DataKeyIDs are the data keys the encrypted logs of the segment are
+	// sealed with.
+	DataKeyIDs []int64 `json:"data_key_ids,omitempty"`
+}
+
+type Rehydration struct {
+	Segments []ArchiveSegment `json:"segments"`
+	Rows     int64            `json:"rows"`
+	Table    string           `json:"table"`
+}
@@ -0,0 +1,86 @@
+package model
+
+import "time"
+
+const (
+	ChainBreakMissing            = "missing"
+	ChainBreakModified           = "modified"
+	ChainBreakCheckpointMismatch = "checkpoint_mismatch"
+	ChainBreakBadSignature       = "bad_signature"
+	ChainBreakUnsignedTombstone  = "unsigned_tombstone"
+)
+
+// ChainLink places a log in the hash chain of its source: Hash covers the
+// log and the Hash of the link with Seq-1.
+type ChainLink struct {
+	Seq  uint64
+	Hash []byte
+}
+
+type ChainedLog struct {
+	LogModel
+	Link ChainLink
+}
+
+// ChainHead is the newest link of a source, along with the sequence number
+// of its latest checkpoint, 0 without any.
+type ChainHead struct {
+	Source     string
+	Link       ChainLink
+	Checkpoint uint64
+}
+
+// ChainCheckpoint is a signed chain head.
+type ChainCheckpoint struct {
+	ID        uint64    `json:"id"`
+	Source    string    `json:"source"`
+	Seq       uint64    `json:"seq"`
+	Hash      []byte    `json:"hash"`
+	KeyID     string    `json:"key_id"`
+	Signature []byte    `json:"signature"`
+	SignedAt  time.Time `json:"signed_at"`
+}
+
+// ChainTombstone stands for the links FromSeq to ToSeq of a source, deleted
+// by retention, a purge or a partition drop. Hash is the hash of the link
+// with ToSeq, the one the link after them builds on. KeyID and Signature
+// are unset when no signing key was configured at the time.
+type ChainTombstone struct {
+	ID        uint64    `json:"id"`
+	Source    string    `json:"source"`
+	FromSeq   uint64    `json:"from_seq"`
+	ToSeq     uint64    `json:"to_seq"`
+	Hash      []byte    `json:"hash"`
+	DeletedAt time.Time `json:"deleted_at"`
+	KeyID     string    `json:"key_id,omitempty"`
+	Signature []byte    `json:"signature,omitempty"`
+}
+
+// ChainBreak is the first place a chain doesn't verify. LogID is unset
+// when the log is missing.
+type ChainBreak struct {
+	Seq      uint64  `json:"seq"`
+	LogID    *uint64 `json:"log_id,omitempty"`
+	Reason   string  `json:"reason"`
+	Expected []byte  `json:"expected,omitempty"`
+	Actual   []byte  `json:"actual,omitempty"`
+}
+
+// ChainVerification reports a walk over the links From to To of a source.
+// Anchored is set when the link before From is gone, so From itself is
+// trusted rather than verified. Pruned counts the links skipped over
+// because a tombstone records their deletion. Complete is unset when the walk stopped at
+// its row limit before reaching the requested end.
+type ChainVerification struct {
+	Source      string      `json:"source"`
+	From        uint64      `json:"from_seq"`
+	To          uint64      `json:"to_seq"`
+	Head        uint64      `json:"head_seq"`
+	Rows        uint64      `json:"rows"`
+	Pruned      uint64      `json:"pruned"`
+	Checkpoints int         `json:"checkpoints"`
+	Anchored    bool        `json:"anchored"`
+	Complete    bool        `json:"complete"`
+	Intact      bool        `json:"intact"`
+	Break       *ChainBreak `json:"break,omitempty"`
+}
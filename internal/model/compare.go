@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+type TimeWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+const (
+	ChangeAppeared    = "appeared"
+	ChangeDisappeared = "disappeared"
+	ChangeIncreased   = "increased"
+	ChangeDecreased   = "decreased"
+	ChangeUnchanged   = "unchanged"
+)
+
+// WindowChange compares the count of a value in the baseline and target
+// windows. Ratio compares the rates per second, so windows of different
+// lengths are comparable, and Effect is the signed z-score of the change.
+type WindowChange struct {
+	Value    string   `json:"value"`
+	Change   string   `json:"change"`
+	Baseline int64    `json:"baseline"`
+	Target   int64    `json:"target"`
+	Ratio    *float64 `json:"ratio,omitempty"`
+	Effect   float64  `json:"effect"`
+}
+
+type WindowComparison struct {
+	Baseline TimeWindow     `json:"baseline"`
+	Target   TimeWindow     `json:"target"`
+	Sources  []WindowChange `json:"sources,omitempty"`
+	Levels   []WindowChange `json:"levels,omitempty"`
+	Patterns []WindowChange `json:"patterns,omitempty"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// DataKey encrypts the logs of a source. Wrapped is the key encrypted by
+// the master key MasterKeyID; retired keys stay until no log uses them.
+type DataKey struct {
+	ID          uint64
+	Source      string
+	Wrapped     []byte
+	MasterKeyID string
+	CreatedAt   time.Time
+	RetiredAt   *time.Time
+}
+
+// Sealed is the encrypted raw_log and attributes of a log. Attributes is
+// nil when the log has none.
+type Sealed struct {
+	DataKeyID  uint64 `json:"data_key_id"`
+	RawLog     []byte `json:"raw_log_enc"`
+	Attributes []byte `json:"attributes_enc,omitempty"`
+}
+
+// SealedLog is what re-encrypting a log needs.
+type SealedLog struct {
+	ID        uint64
+	Source    string
+	CreatedAt time.Time
+	Sealed    Sealed
+}
+
+// KeyRotation counts what a rotation cycle did: data keys rewrapped by the
+// current master key, retired for their age and dropped once unused, and
+// logs re-encrypted off retired keys.
+type KeyRotation struct {
+	Rewrapped   int64 `json:"rewrapped"`
+	Retired     int64 `json:"retired"`
+	Reencrypted int64 `json:"reencrypted"`
+	Dropped     int64 `json:"dropped"`
+}
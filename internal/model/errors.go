@@ -0,0 +1,15 @@
+package model
+
+import "errors"
+
+var (
+	ErrInvalidRequest      = errors.New("invalid request")
+	ErrQueryRejected       = errors.New("query rejected")
+	ErrLogNotFound         = errors.New("log not found")
+	ErrSavedSearchNotFound = errors.New("saved search not found")
+	ErrSavedSearchExists   = errors.New("saved search already exists")
+	ErrUnsupported         = errors.New("not supported by the storage backend")
+	ErrLegalHoldNotFound   = errors.New("legal hold not found")
+	ErrChainNotFound       = errors.New("hash chain not found")
+	ErrReplyTooLarge       = errors.New("reply exceeds the NATS max payload")
+)
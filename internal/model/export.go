@@ -0,0 +1,9 @@
+package model
+
+type ExportReference struct {
+	Bucket string `json:"bucket"`
+	Name   string `json:"name"`
+	Size   uint64 `json:"size"`
+	Chunks uint32 `json:"chunks"`
+	Digest string `json:"digest,omitempty"`
+}
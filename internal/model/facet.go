@@ -0,0 +1,13 @@
+package model
+
+type FacetValue struct {
+	Value string `json:"value"`
+	Count uint64 `json:"count"`
+}
+
+type Facets struct {
+	Sources       []FacetValue `json:"sources,omitempty"`
+	LogLevels     []FacetValue `json:"log_levels,omitempty"`
+	LoggerNames   []FacetValue `json:"logger_names,omitempty"`
+	AttributeKeys []FacetValue `json:"attribute_keys,omitempty"`
+}
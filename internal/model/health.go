@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// PoolHealth is the state of one connection pool: whether it answered a
+// ping and how busy it is.
+type PoolHealth struct {
+	Name         string        `json:"name"`
+	Role         string        `json:"role"`
+	Healthy      bool          `json:"healthy"`
+	Error        string        `json:"error,omitempty"`
+	Latency      time.Duration `json:"latency_ns"`
+	Open         int           `json:"open"`
+	InUse        int           `json:"in_use"`
+	Idle         int           `json:"idle"`
+	WaitCount    int64         `json:"wait_count"`
+	WaitDuration time.Duration `json:"wait_duration_ns"`
+}
+
+// Health is "down" when the primary is unreachable and "degraded" when
+// only replicas are.
+type Health struct {
+	Status  string       `json:"status"`
+	Backend string       `json:"backend"`
+	Pools   []PoolHealth `json:"pools"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// HoldVolume is how many logs, soft-deleted ones included, a hold keeps.
+type HoldVolume struct {
+	Rows      int64 `json:"rows"`
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// LegalHold keeps the logs matching all of its set selectors: Source,
+// RequestID and the [From, To) range of created_at.
+type LegalHold struct {
+	ID         uint64     `json:"id"`
+	Reason     string     `json:"reason"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	ReleasedBy *string    `json:"released_by,omitempty"`
+	ReleasedAt *time.Time `json:"released_at,omitempty"`
+	Source     *string    `json:"source,omitempty"`
+	RequestID  *string    `json:"request_id,omitempty"`
+	From       *time.Time `json:"from,omitempty"`
+	To         *time.Time `json:"to,omitempty"`
+	Active     bool       `json:"active"`
+	HoldVolume
+}
+
+// LegalHolds lists holds along with the volume all active holds keep
+// together, which is less than the sum when they overlap.
+type LegalHolds struct {
+	Holds     []LegalHold `json:"holds"`
+	Protected HoldVolume  `json:"protected"`
+}
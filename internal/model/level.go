@@ -0,0 +1,159 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Level is the canonical severity of a log entry. Levels are ordered, so
+// they can be compared to express thresholds.
+type Level int16
+
+const (
+	LevelTrace Level = iota
+	LevelDebug
+	LevelInfo
+	LevelNotice
+	LevelWarn
+	LevelError
+	LevelCritical
+	LevelAlert
+	LevelFatal
+)
+
+var levelNames = [...]string{
+	LevelTrace:    "TRACE",
+	LevelDebug:    "DEBUG",
+	LevelInfo:     "INFO",
+	LevelNotice:   "NOTICE",
+	LevelWarn:     "WARN",
+	LevelError:    "ERROR",
+	LevelCritical: "CRITICAL",
+	LevelAlert:    "ALERT",
+	LevelFatal:    "FATAL",
+}
+
+var levelAliases = map[string]Level{
+	"TRACE":         LevelTrace,
+	"TRC":           LevelTrace,
+	"DEBUG":         LevelDebug,
+	"DBG":           LevelDebug,
+	"VERBOSE":       LevelDebug,
+	"INFO":          LevelInfo,
+	"INF":           LevelInfo,
+	"INFORMATION":   LevelInfo,
+	"INFORMATIONAL": LevelInfo,
+	"NOTICE":        LevelNotice,
+	"WARN":          LevelWarn,
+	"WRN":           LevelWarn,
+	"WARNING":       LevelWarn,
+	"ERROR":         LevelError,
+	"ERR":           LevelError,
+	"CRITICAL":      LevelCritical,
+	"CRIT":          LevelCritical,
+	"ALERT":         LevelAlert,
+	"FATAL":         LevelFatal,
+	"PANIC":         LevelFatal,
+	"EMERG":         LevelFatal,
+	"EMERGENCY":     LevelFatal,
+}
+
+// syslogLevels maps RFC 5424 numeric severities, 0 being the most severe.
+var syslogLevels = [...]Level{
+	LevelFatal,
+	LevelAlert,
+	LevelCritical,
+	LevelError,
+	LevelWarn,
+	LevelNotice,
+	LevelInfo,
+	LevelDebug,
+}
+
+// slogLevels are the base levels of log/slog, which prints levels between
+// them as offsets such as "ERROR+2" or "INFO-4".
+var slogLevels = map[string]int{
+	"DEBUG": -4,
+	"INFO":  0,
+	"WARN":  4,
+	"ERROR": 8,
+}
+
+func (l Level) String() string {
+	if l < LevelTrace || l > LevelFatal {
+		return "UNKNOWN"
+	}
+	return levelNames[l]
+}
+
+func fromSlog(v int) Level {
+	switch {
+	case v < -4:
+		return LevelTrace
+	case v < 0:
+		return LevelDebug
+	case v < 4:
+		return LevelInfo
+	case v < 8:
+		return LevelWarn
+	case v < 12:
+		return LevelError
+	default:
+		return LevelCritical
+	}
+}
+
+// ParseLevel normalizes level names in any case, their common aliases,
+// numeric syslog severities and slog offsets like "ERROR+2".
+func ParseLevel(raw string) (Level, bool) {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+
+	if l, ok := levelAliases[s]; ok {
+		return l, true
+	}
+
+	if n, err := strconv.Atoi(s); err == nil {
+		if n >= 0 && n < len(syslogLevels) {
+			return syslogLevels[n], true
+		}
+		return 0, false
+	}
+
+	if i := strings.IndexAny(s, "+-"); i > 0 {
+		base, ok := slogLevels[s[:i]]
+		if !ok {
+			return 0, false
+		}
+		offset, err := strconv.Atoi(s[i:])
+		if err != nil {
+			return 0, false
+		}
+		return fromSlog(base + offset), true
+	}
+
+	return 0, false
+}
+
+// NormalizeLevel returns the canonical name of the level, or the trimmed
+// upper-cased input when it isn't a known level.
+func NormalizeLevel(raw string) string {
+	if l, ok := ParseLevel(raw); ok {
+		return l.String()
+	}
+	return strings.ToUpper(strings.TrimSpace(raw))
+}
+
+func NormalizeLevels(raw []string) []string {
+	if raw == nil {
+		return nil
+	}
+	ret := make([]string, 0, len(raw))
+	for _, l := range raw {
+		if l == "*" {
+			ret = append(ret, l)
+			continue
+		}
+		ret = append(ret, NormalizeLevel(l))
+	}
+	return ret
+}
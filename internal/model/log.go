@@ -6,14 +6,26 @@ import (
 )
 
 type LogModel struct {
-	ID         uint64    `json:"id"`
-	RawLog     string    `json:"raw_log"`
-	LogLevel   string    `json:"log_level"`
-	Source     string    `json:"source"`
-	CreatedAt  time.Time `json:"created_at"`
-	RequestID  *string   `json:"request_id"`
-	LoggerName string    `json:"logger_name"`
-	IsDeleted  bool      `json:"is_deleted"`
+	ID           uint64         `json:"id"`
+	RawLog       string         `json:"raw_log"`
+	LogLevel     string         `json:"log_level"`
+	RawLevel     *string        `json:"raw_level,omitempty"`
+	Source       string         `json:"source"`
+	CreatedAt    time.Time      `json:"created_at"`
+	RequestID    *string        `json:"request_id"`
+	LoggerName   string         `json:"logger_name"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	TraceID      *string        `json:"trace_id,omitempty"`
+	SpanID       *string        `json:"span_id,omitempty"`
+	ParentSpanID *string        `json:"parent_span_id,omitempty"`
+	IsDeleted    bool           `json:"is_deleted"`
+	DeletedAt    *time.Time     `json:"deleted_at,omitempty"`
+	// Encrypted is set when raw_log and attributes are encrypted and were
+	// read without the master key.
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Sealed holds the encrypted payload until it's decrypted. Reads always
+	// clear it, only archives keep it.
+	Sealed *Sealed `json:"sealed,omitempty"`
 }
 
 func (m *LogModel) AsJson() *string {
@@ -24,3 +36,23 @@ func (m *LogModel) AsJson() *string {
 	ret := string(res)
 	return &ret
 }
+
+type LogContext struct {
+	Before []LogModel `json:"before"`
+	Entry  LogModel   `json:"entry"`
+	After  []LogModel `json:"after"`
+}
+
+type TimelineEntry struct {
+	LogModel
+	Anchor  bool     `json:"anchor"`
+	Reasons []string `json:"reasons"`
+}
+
+// Timeline lists the logs related to an anchor in time order. Truncated is
+// set when more logs matched than the row limit, the ones kept being the
+// closest to the anchor.
+type Timeline struct {
+	Entries   []TimelineEntry `json:"entries"`
+	Truncated bool            `json:"truncated"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Partition is a range partition of logs. From is nil for a partition
+// starting at MINVALUE, From and To are both nil for the default one.
+type Partition struct {
+	Name      string     `json:"name"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Default   bool       `json:"default,omitempty"`
+	Rows      int64      `json:"rows"`
+	SizeBytes int64      `json:"size_bytes"`
+}
+
+type PartitionStatus struct {
+	Partitioned bool        `json:"partitioned"`
+	Partitions  []Partition `json:"partitions"`
+	TotalBytes  int64       `json:"total_bytes"`
+}
+
+// PartitionChanges lists what a maintenance cycle did. Held partitions
+// were due to be dropped but hold logs under a legal hold. Moved counts the
+// logs moved out of the default partition into the created ones.
+type PartitionChanges struct {
+	Created []string `json:"created"`
+	Dropped []string `json:"dropped"`
+	Held    []string `json:"held"`
+	Moved   int64    `json:"moved"`
+}
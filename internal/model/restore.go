@@ -0,0 +1,16 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// LogRestore is the audit record of a single restore of soft-deleted logs.
+type LogRestore struct {
+	ID         uint64          `json:"id"`
+	RestoredBy string          `json:"restored_by"`
+	Reason     *string         `json:"reason,omitempty"`
+	Request    json.RawMessage `json:"request"`
+	LogIDs     []int64         `json:"log_ids"`
+	RestoredAt time.Time       `json:"restored_at"`
+}
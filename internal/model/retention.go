@@ -0,0 +1,16 @@
+package model
+
+const (
+	ExpireMaxAge   = "max_age"
+	ExpireMaxRows  = "max_rows"
+	ExpireMaxBytes = "max_bytes"
+)
+
+// RetentionResult counts the logs a retention rule expired for one of its
+// limits.
+type RetentionResult struct {
+	Rule    string `json:"rule"`
+	Limit   string `json:"limit"`
+	Hard    bool   `json:"hard"`
+	Expired int64  `json:"expired"`
+}
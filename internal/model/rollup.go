@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Rollup resolutions, from the one logs are counted at to the coarsest
+// they are downsampled to.
+const (
+	ResolutionMinute = "minute"
+	ResolutionHour   = "hour"
+	ResolutionDay    = "day"
+)
+
+// ResolutionStep returns the bucket length of a resolution, 0 for unknown
+// ones.
+func ResolutionStep(resolution string) time.Duration {
+	switch resolution {
+	case ResolutionMinute:
+		return time.Minute
+	case ResolutionHour:
+		return time.Hour
+	case ResolutionDay:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// StatsPoint counts the logs of a group. Bucket is set for histograms, the
+// other keys for the fields the stats are grouped by.
+type StatsPoint struct {
+	Bucket     *time.Time `json:"bucket,omitempty"`
+	Source     *string    `json:"source,omitempty"`
+	LogLevel   *string    `json:"log_level,omitempty"`
+	LoggerName *string    `json:"logger_name,omitempty"`
+	Count      uint64     `json:"count"`
+	Bytes      uint64     `json:"bytes"`
+}
+
+// Stats counts logs from the rollups. Resolution is the coarsest rollup
+// resolution the time range falls on, which bounds how precise its time
+// bounds are.
+type Stats struct {
+	Interval   string       `json:"interval,omitempty"`
+	Resolution string       `json:"resolution,omitempty"`
+	Count      uint64       `json:"count"`
+	Bytes      uint64       `json:"bytes"`
+	Points     []StatsPoint `json:"points"`
+}
+
+// Downsampling counts the rollup rows folded into coarser ones.
+type Downsampling struct {
+	Hourly int64
+	Daily  int64
+}
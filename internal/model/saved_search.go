@@ -0,0 +1,17 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type SavedSearch struct {
+	ID          uint64          `json:"id"`
+	Name        string          `json:"name"`
+	Query       json.RawMessage `json:"query"`
+	Owner       *string         `json:"owner,omitempty"`
+	Description *string         `json:"description,omitempty"`
+	Tags        []string        `json:"tags"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
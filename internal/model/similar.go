@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+type SimilarLog struct {
+	LogModel
+	Similarity float64 `json:"similarity"`
+}
+
+// SimilarSource summarizes where the similar logs were found.
+type SimilarSource struct {
+	Source    string    `json:"source"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type SimilarLogs struct {
+	Anchor  LogModel        `json:"anchor"`
+	Matches []SimilarLog    `json:"matches"`
+	Sources []SimilarSource `json:"sources"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// TopValue is the count of a value within the window and within the
+// previous window of the same length.
+type TopValue struct {
+	Value    string   `json:"value"`
+	Count    int64    `json:"count"`
+	Previous int64    `json:"previous"`
+	Delta    int64    `json:"delta"`
+	Ratio    *float64 `json:"ratio,omitempty"`
+}
+
+type Top struct {
+	Field        string     `json:"field"`
+	From         time.Time  `json:"from"`
+	To           time.Time  `json:"to"`
+	PreviousFrom time.Time  `json:"previous_from"`
+	Values       []TopValue `json:"values"`
+}
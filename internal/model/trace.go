@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type TraceSpan struct {
+	SpanID       string       `json:"span_id"`
+	ParentSpanID *string      `json:"parent_span_id,omitempty"`
+	Sources      []string     `json:"sources"`
+	FirstAt      time.Time    `json:"first_at"`
+	LastAt       time.Time    `json:"last_at"`
+	DurationMs   float64      `json:"duration_ms"`
+	Logs         []LogModel   `json:"logs"`
+	Children     []*TraceSpan `json:"children"`
+}
+
+type TraceService struct {
+	Source     string    `json:"source"`
+	FirstAt    time.Time `json:"first_at"`
+	LastAt     time.Time `json:"last_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Count      uint64    `json:"count"`
+}
+
+type Trace struct {
+	TraceID   *string        `json:"trace_id,omitempty"`
+	RequestID *string        `json:"request_id,omitempty"`
+	Spans     []*TraceSpan   `json:"spans"`
+	Services  []TraceService `json:"services"`
+	Unspanned []LogModel     `json:"unspanned"`
+}
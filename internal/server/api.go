@@ -5,45 +5,265 @@ import (
 	"log/slog"
 	"time"
 
-	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/factory"
+	"log_shelter/internal/model"
 )
 
+// logRetention expires logs by the retention rules every cycle_time.
 func (s *Server) logRetention(ctx context.Context) {
 	cfg := s.cfg.Logs
-	pg := s.pg
+	rules, err := factory.RetentionRules(cfg)
+	if err != nil {
+		slog.Error("Retention disabled", "err", err)
+		return
+	}
+	if len(rules) == 0 || cfg.CycleTime == 0 {
+		return
+	}
+
 	for {
-		time.Sleep(time.Duration(cfg.CycleTime))
 		select {
 		case <-ctx.Done():
 			return
-		default:
-
+		case <-time.After(time.Duration(cfg.CycleTime)):
 		}
 
-		conn, tx, err := pg.GetTranscation()
+		f, err := s.factory.GetUsecaseFactory(ctx)
 		if err != nil {
 			slog.Error("Error before transcation in retention", "err", err)
+			continue
 		}
+		results, err := f.GetApplyRetentionUsecase(rules, s.archiver).Run(time.Now())
+		f.Close()
+		if err != nil {
+			slog.Error("Error in retention", "err", err)
+			continue
+		}
+		for _, r := range results {
+			if r.Expired != 0 {
+				slog.Info("Logs expired", "rule", r.Rule, "limit", r.Limit,
+					"hard", r.Hard, "expired", r.Expired)
+			}
+		}
+		slog.Info("Cycle ended")
+	}
+}
 
-		defer conn.Close()
+const defaultPurgeBatchSize = 10000
 
-		repo := repository.NewLogRepository(ctx, tx)
+// purgeBatch hard-deletes a single batch of expired soft-deleted logs in its
+// own transaction, so a large backlog doesn't hold one long lock. With
+// archiving enabled the batch is archived first.
+func (s *Server) purgeBatch(ctx context.Context, grace time.Duration, limit uint64) (int64, error) {
+	f, err := s.factory.GetUsecaseFactory(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
 
-		switch cfg.RetencionPolicy {
-		case "after_time":
-			err := repo.RetentOlder(time.Duration(cfg.DeleteAfter))
+	return f.GetPurgeLogsUsecase(s.archiver).Run(time.Now(), grace, limit)
+}
+
+// logPurge physically removes logs that stayed soft-deleted for longer than
+// the purge_after grace period.
+func (s *Server) logPurge(ctx context.Context) {
+	cfg := s.cfg.Logs
+	if cfg.PurgeAfter == 0 || cfg.PurgeCycleTime == 0 {
+		return
+	}
+	limit := cfg.PurgeBatchSize
+	if limit == 0 {
+		limit = defaultPurgeBatchSize
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(cfg.PurgeCycleTime)):
+		}
+
+		var total int64
+		for {
+			n, err := s.purgeBatch(ctx, time.Duration(cfg.PurgeAfter), limit)
 			if err != nil {
-				slog.Error("Error in retention", "err", err)
+				slog.Error("Error in purge", "err", err)
+				break
+			}
+			total += n
+			if uint64(n) < limit || ctx.Err() != nil {
+				break
 			}
-			err = tx.Commit()
+		}
+		slog.Info("Purge cycle ended", "purged", total)
+	}
+}
+
+// partitionMaintenance keeps future partitions of logs created and drops
+// the ones past the partition retention.
+func (s *Server) partitionMaintenance(ctx context.Context) {
+	cfg := s.cfg.Partitions
+	if !cfg.Enabled {
+		return
+	}
+	cycle := time.Duration(cfg.CycleTime)
+	if cycle == 0 {
+		cycle = time.Hour
+	}
+
+	for {
+		f, err := s.factory.GetUsecaseFactory(ctx)
+		if err != nil {
+			slog.Error("Cannot get factory", "err", err)
+		} else {
+			changes, err := f.GetMaintainPartitionsUsecase(s.archiver).Run(time.Now())
+			f.Close()
 			if err != nil {
-				slog.Error("Error in retention commit", "err", err)
+				slog.Error("Error in partition maintenance", "err", err)
+			} else if len(changes.Created) != 0 || len(changes.Dropped) != 0 {
+				slog.Info("Partitions changed", "created", changes.Created, "dropped", changes.Dropped)
 			}
-		default:
-			tx.Rollback()
+			if err == nil && len(changes.Held) != 0 {
+				slog.Info("Partitions kept for legal holds", "held", changes.Held)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
 			return
+		case <-time.After(cycle):
+		}
+	}
+}
+
+const defaultCheckpointInterval = 10 * time.Minute
+
+// chainCheckpoints signs the heads of the hash chains that moved since
+// their last checkpoint.
+func (s *Server) chainCheckpoints(ctx context.Context) {
+	if s.signer == nil || len(s.cfg.Integrity.Sources) == 0 {
+		return
+	}
+	interval := time.Duration(s.cfg.Integrity.CheckpointInterval)
+	if interval == 0 {
+		interval = defaultCheckpointInterval
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		f, err := s.factory.GetUsecaseFactory(ctx)
+		if err != nil {
+			slog.Error("Error before transcation in checkpoints", "err", err)
+			continue
+		}
+		checkpoints, err := f.GetCheckpointChainsUsecase().Run(time.Now())
+		f.Close()
+		if err != nil {
+			slog.Error("Error in checkpoints", "err", err)
+			continue
+		}
+		for _, c := range checkpoints {
+			slog.Info("Chain checkpointed", "source", c.Source, "seq", c.Seq, "key_id", c.KeyID)
+		}
+	}
+}
+
+const (
+	defaultRotationCycle     = time.Hour
+	defaultRotationBatchSize = 1000
+)
+
+// rotateBatch runs a single key rotation batch in its own transaction.
+func (s *Server) rotateBatch(ctx context.Context) (*model.KeyRotation, error) {
+	f, err := s.factory.GetUsecaseFactory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.GetRotateKeysUsecase().Run(time.Now())
+}
+
+// keyRotation rewraps and retires data keys and re-encrypts the logs of
+// retired ones, batch by batch.
+func (s *Server) keyRotation(ctx context.Context) {
+	cfg := s.cfg.Encryption
+	if s.keys == nil {
+		return
+	}
+	cycle := time.Duration(cfg.RotationCycleTime)
+	if cycle == 0 {
+		cycle = defaultRotationCycle
+	}
+	batch := int64(cfg.RotationBatchSize)
+	if batch == 0 {
+		batch = defaultRotationBatchSize
+	}
+
+	for {
+		var total model.KeyRotation
+		for {
+			r, err := s.rotateBatch(ctx)
+			if err != nil {
+				slog.Error("Error in key rotation", "err", err)
+				break
+			}
+			total.Rewrapped += r.Rewrapped
+			total.Retired += r.Retired
+			total.Reencrypted += r.Reencrypted
+			total.Dropped += r.Dropped
+			if r.Reencrypted < batch || ctx.Err() != nil {
+				break
+			}
+		}
+		if total != (model.KeyRotation{}) {
+			slog.Info("Keys rotated", "rewrapped", total.Rewrapped, "retired", total.Retired,
+				"reencrypted", total.Reencrypted, "dropped", total.Dropped)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cycle):
+		}
+	}
+}
+
+// rollupDownsampling folds old minute rollups into hours and days.
+func (s *Server) rollupDownsampling(ctx context.Context) {
+	cfg := s.cfg.Rollups
+	if s.pg == nil || (cfg.HourlyAfter == 0 && cfg.DailyAfter == 0) {
+		return
+	}
+	cycle := time.Duration(cfg.CycleTime)
+	if cycle == 0 {
+		cycle = time.Hour
+	}
+
+	for {
+		f, err := s.factory.GetUsecaseFactory(ctx)
+		if err != nil {
+			slog.Error("Cannot get factory", "err", err)
+		} else {
+			folded, err := f.GetDownsampleRollupsUsecase().Run(time.Now())
+			f.Close()
+			if err != nil {
+				slog.Error("Error in rollup downsampling", "err", err)
+			} else if folded.Hourly != 0 || folded.Daily != 0 {
+				slog.Info("Rollups downsampled", "hourly", folded.Hourly, "daily", folded.Daily)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cycle):
 		}
-		slog.Info("Cycle ended")
 	}
 }
 
@@ -52,4 +272,9 @@ func (s *Server) setupAPI() {
 	s.setupHTTPAPI()
 
 	go s.logRetention(s.ctx)
+	go s.logPurge(s.ctx)
+	go s.partitionMaintenance(s.ctx)
+	go s.chainCheckpoints(s.ctx)
+	go s.keyRotation(s.ctx)
+	go s.rollupDownsampling(s.ctx)
 }
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+
+	"log_shelter/internal/model"
+	"log_shelter/pkg/client"
+)
+
+// chunkHeadroom leaves space for the protocol headers of every chunk within
+// the NATS max payload.
+const chunkHeadroom = 1024
+
+// chunkSize reports whether the requester asked for a chunked reply and the
+// chunk size to use for it.
+func (s *Server) chunkSize(msg *nats.Msg) (int, bool) {
+	if msg.Header == nil || msg.Header.Get(client.HeaderChunked) == "" {
+		return 0, false
+	}
+	size := int(s.nats.Conn.MaxPayload()) - chunkHeadroom
+	requested, err := strconv.Atoi(msg.Header.Get(client.HeaderChunkSize))
+	if err == nil && requested > 0 {
+		size = min(size, requested)
+	}
+	return size, true
+}
+
+// chunkWriter sends everything written to it as sequenced reply chunks of at
+// most size bytes. Close ends the stream with the end-of-stream marker.
+type chunkWriter struct {
+	msg  *nats.Msg
+	size int
+	seq  int
+	buf  []byte
+}
+
+func newChunkWriter(msg *nats.Msg, size int) *chunkWriter {
+	return &chunkWriter{msg: msg, size: size, buf: make([]byte, 0, size)}
+}
+
+func (w *chunkWriter) send(data []byte) error {
+	reply := nats.NewMsg(w.msg.Reply)
+	reply.Header.Set(client.HeaderSeq, strconv.Itoa(w.seq))
+	reply.Data = data
+	w.seq++
+	return w.msg.RespondMsg(reply)
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) != 0 {
+		free := w.size - len(w.buf)
+		if free > len(p) {
+			free = len(p)
+		}
+		w.buf = append(w.buf, p[:free]...)
+		p = p[free:]
+		if len(w.buf) == w.size {
+			err := w.send(w.buf)
+			if err != nil {
+				return n - len(p), err
+			}
+			w.buf = w.buf[:0]
+		}
+	}
+	return n, nil
+}
+
+// Close flushes the buffered data and sends the end-of-stream marker. A non
+// nil failure is reported on the marker instead of the remaining data.
+func (w *chunkWriter) Close(failure error) error {
+	if failure == nil && len(w.buf) != 0 {
+		err := w.send(w.buf)
+		if err != nil {
+			return err
+		}
+	}
+	w.buf = w.buf[:0]
+
+	eos := nats.NewMsg(w.msg.Reply)
+	eos.Header.Set(client.HeaderEOS, "true")
+	eos.Header.Set(client.HeaderChunks, strconv.Itoa(w.seq))
+	if failure != nil {
+		eos.Header.Set(client.HeaderError, failure.Error())
+		eos.Data, _ = json.Marshal(errorResponse{Error: failure.Error()})
+	}
+	return w.msg.RespondMsg(eos)
+}
+
+// respond replies with data, chunked when the requester negotiated it. A
+// plain reply too large for NATS is answered with an error instead, as the
+// requester would only time out otherwise.
+func (s *Server) respond(msg *nats.Msg, data []byte) {
+	var err error
+	if size, ok := s.chunkSize(msg); ok {
+		w := newChunkWriter(msg, size)
+		_, err = w.Write(data)
+		if err == nil {
+			err = w.Close(nil)
+		}
+	} else if limit := s.nats.Conn.MaxPayload(); int64(len(data)) > limit {
+		s.respondError(msg, fmt.Errorf(
+			"%w: %d bytes over %d, set the %s header to get a chunked reply",
+			model.ErrReplyTooLarge, len(data), limit, client.HeaderChunked))
+		return
+	} else {
+		err = msg.Respond(data)
+	}
+	if err != nil {
+		slog.Error("Error in respond", "err", err)
+	}
+}
@@ -1,11 +1,114 @@
 package server
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
+
+	"log_shelter/internal/factory"
+	"log_shelter/internal/model"
+	"log_shelter/internal/usecase"
+	"log_shelter/pkg/client"
 )
 
+const exportChunkSize = 32 * 1024
+
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrLogNotFound), errors.Is(err, model.ErrSavedSearchNotFound),
+		errors.Is(err, model.ErrLegalHoldNotFound), errors.Is(err, model.ErrChainNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, model.ErrSavedSearchExists):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrQueryRejected):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, model.ErrUnsupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(resp http.ResponseWriter, status int, err error) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(status)
+	json.NewEncoder(resp).Encode(errorResponse{Error: err.Error()})
+}
+
+// decodeBody unmarshals the request body into dst. An empty body leaves dst
+// untouched.
+func decodeBody(req *http.Request, dst any) error {
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(data, dst)
+	if err != nil {
+		return fmt.Errorf("%w: %v", model.ErrInvalidRequest, err)
+	}
+	return nil
+}
+
+// httpContext is the context the HTTP request req is served under.
+func (s *Server) httpContext(req *http.Request) context.Context {
+	return s.decryptContext(req.Context(), req.Header.Get(client.HeaderDecryptToken))
+}
+
+// serveHTTP runs the usecase against a fresh usecase factory and writes its
+// JSON result or an error response.
+func (s *Server) serveHTTP(
+	resp http.ResponseWriter,
+	req *http.Request,
+	run func(*factory.UsecaseFactory) ([]byte, error),
+) {
+	s.serveHTTPWith(resp, req, s.factory.GetUsecaseFactory, run)
+}
+
+// serveHTTPRead is serveHTTP for read-only usecases, which run on a replica
+// when there is one.
+func (s *Server) serveHTTPRead(
+	resp http.ResponseWriter,
+	req *http.Request,
+	run func(*factory.UsecaseFactory) ([]byte, error),
+) {
+	s.serveHTTPWith(resp, req, s.factory.GetReadUsecaseFactory, run)
+}
+
+func (s *Server) serveHTTPWith(
+	resp http.ResponseWriter,
+	req *http.Request,
+	get func(context.Context) (*factory.UsecaseFactory, error),
+	run func(*factory.UsecaseFactory) ([]byte, error),
+) {
+	f, err := get(s.httpContext(req))
+	if err != nil {
+		slog.Error("Cannot get factory", "err", err)
+		writeError(resp, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer f.Close()
+
+	data, err := run(f)
+	if err != nil {
+		slog.Error("Error in usecase", "err", err)
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write(data)
+}
+
 func (s *Server) handlerSearch(resp http.ResponseWriter, req *http.Request) {
 	v, e := req.URL.Query()["q"]
 	if !e || len(v) == 0 {
@@ -28,9 +131,320 @@ func (s *Server) handlerSearch(resp http.ResponseWriter, req *http.Request) {
 	json.NewEncoder(resp).Encode(ret)
 }
 
+func (s *Server) handlerHTTPListSavedSearches(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.ListSavedSearchesRequest
+	if owner := req.URL.Query().Get("owner"); owner != "" {
+		input.Owner = &owner
+	}
+	input.Tags = req.URL.Query()["tag"]
+
+	s.serveHTTPRead(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetListSavedSearchesUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPCreateSavedSearch(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.SavedSearchRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+
+	s.serveHTTP(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetCreateSavedSearchUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPGetSavedSearch(resp http.ResponseWriter, req *http.Request) {
+	input := usecase.SavedSearchNameRequest{Name: req.PathValue("name")}
+
+	s.serveHTTPRead(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetGetSavedSearchUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPUpdateSavedSearch(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.SavedSearchRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+	input.Name = req.PathValue("name")
+
+	s.serveHTTP(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return okResponse, f.GetUpdateSavedSearchUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPDeleteSavedSearch(resp http.ResponseWriter, req *http.Request) {
+	input := usecase.SavedSearchNameRequest{Name: req.PathValue("name")}
+
+	s.serveHTTP(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return okResponse, f.GetDeleteSavedSearchUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPRunSavedSearch(resp http.ResponseWriter, req *http.Request) {
+	var overrides json.RawMessage
+	if err := decodeBody(req, &overrides); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+	input := usecase.RunSavedSearchRequest{Name: req.PathValue("name"), Overrides: overrides}
+
+	s.serveHTTPRead(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetRunSavedSearchUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPRestoreLogs(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.RestoreLogsRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+
+	s.serveHTTP(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetRestoreLogsUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPListRestores(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.ListRestoresRequest
+	if restored_by := req.URL.Query().Get("restored_by"); restored_by != "" {
+		input.RestoredBy = &restored_by
+	}
+	if limit := req.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.ParseUint(limit, 10, 64)
+		if err != nil {
+			err = fmt.Errorf("%w: limit: %v", model.ErrInvalidRequest, err)
+			writeError(resp, httpStatus(err), err)
+			return
+		}
+		input.Limit = n
+	}
+
+	s.serveHTTPRead(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetListRestoresUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPGetPartitions(resp http.ResponseWriter, req *http.Request) {
+	s.serveHTTPRead(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetGetPartitionsUsecase().Run()
+	})
+}
+
+func (s *Server) handlerHTTPListSegments(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.SegmentsRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+
+	s.serveHTTPRead(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetListSegmentsUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPRehydrate(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.SegmentsRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+
+	s.serveHTTP(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetRehydrateUsecase(s.archiver).Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPCreateLegalHold(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.CreateLegalHoldRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+
+	s.serveHTTP(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetCreateLegalHoldUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPListLegalHolds(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.ListLegalHoldsRequest
+	input.Inactive = req.URL.Query().Get("inactive") == "true"
+	if limit := req.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.ParseUint(limit, 10, 64)
+		if err != nil {
+			err = fmt.Errorf("%w: limit: %v", model.ErrInvalidRequest, err)
+			writeError(resp, httpStatus(err), err)
+			return
+		}
+		input.Limit = n
+	}
+
+	s.serveHTTPRead(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetListLegalHoldsUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPReleaseLegalHold(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.ReleaseLegalHoldRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+	id, err := strconv.ParseUint(req.PathValue("id"), 10, 64)
+	if err != nil {
+		err = fmt.Errorf("%w: id: %v", model.ErrInvalidRequest, err)
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+	input.ID = id
+
+	s.serveHTTP(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return okResponse, f.GetReleaseLegalHoldUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPVerifyChain(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.VerifyChainRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+
+	s.serveHTTPRead(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetVerifyChainUsecase().Run(input)
+	})
+}
+
+func (s *Server) handlerHTTPGetStats(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.GetStatsRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+
+	s.serveHTTPRead(resp, req, func(f *factory.UsecaseFactory) ([]byte, error) {
+		return f.GetGetStatsUsecase().Run(input)
+	})
+}
+
+// flushWriter pushes every write to the client, so the buffered writer in
+// front of it controls the chunk size of the response.
+type flushWriter struct {
+	w       http.ResponseWriter
+	f       http.Flusher
+	written bool
+}
+
+func (w *flushWriter) Write(p []byte) (int, error) {
+	w.written = true
+	n, err := w.w.Write(p)
+	if w.f != nil {
+		w.f.Flush()
+	}
+	return n, err
+}
+
+func (s *Server) handlerHTTPExportLogs(resp http.ResponseWriter, req *http.Request) {
+	var input usecase.ExportLogsRequest
+	if err := decodeBody(req, &input); err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+	format, err := input.ExportFormat()
+	if err != nil {
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+
+	f, err := s.factory.GetReadUsecaseFactory(s.httpContext(req))
+	if err != nil {
+		slog.Error("Cannot get factory", "err", err)
+		writeError(resp, http.StatusServiceUnavailable, err)
+		return
+	}
+	defer f.Close()
+
+	resp.Header().Set("Content-Type", format.ContentType(input.Gzip))
+	resp.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="logs.%s"`, format.Extension(input.Gzip)))
+
+	flusher, _ := resp.(http.Flusher)
+	fw := &flushWriter{w: resp, f: flusher}
+	w := bufio.NewWriterSize(fw, exportChunkSize)
+
+	err = f.GetExportLogsUsecase().Run(input, w)
+	if err != nil && !fw.written {
+		slog.Error("Error in export", "err", err)
+		resp.Header().Del("Content-Disposition")
+		writeError(resp, httpStatus(err), err)
+		return
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		// Headers are already sent, aborting the handler makes the client
+		// see a truncated chunked body instead of a valid file.
+		slog.Error("Error in export", "err", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// handlerHTTPHealth answers 503 while the primary database is down.
+func (s *Server) handlerHTTPHealth(resp http.ResponseWriter, req *http.Request) {
+	health := s.db.Health()
+	resp.Header().Set("Content-Type", "application/json")
+	if health.Status == model.HealthDown {
+		resp.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(resp).Encode(health)
+}
+
+// postgresOnlyHTTP answers the requests of handler with
+// model.ErrUnsupported unless logs are stored in Postgres.
+func (s *Server) postgresOnlyHTTP(handler http.HandlerFunc) http.HandlerFunc {
+	if s.pg != nil {
+		return handler
+	}
+	return func(resp http.ResponseWriter, req *http.Request) {
+		writeError(resp, httpStatus(model.ErrUnsupported), model.ErrUnsupported)
+	}
+}
+
 func (s *Server) setupHTTPAPI() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/search", s.handlerSearch)
+	mux.HandleFunc("GET /health", s.handlerHTTPHealth)
+
+	mux.HandleFunc("GET /saved_searches", s.postgresOnlyHTTP(s.handlerHTTPListSavedSearches))
+	mux.HandleFunc("POST /saved_searches", s.postgresOnlyHTTP(s.handlerHTTPCreateSavedSearch))
+	mux.HandleFunc("GET /saved_searches/{name}", s.postgresOnlyHTTP(s.handlerHTTPGetSavedSearch))
+	mux.HandleFunc("PUT /saved_searches/{name}", s.postgresOnlyHTTP(s.handlerHTTPUpdateSavedSearch))
+	mux.HandleFunc("DELETE /saved_searches/{name}", s.postgresOnlyHTTP(s.handlerHTTPDeleteSavedSearch))
+	mux.HandleFunc("GET /saved_searches/{name}/run", s.postgresOnlyHTTP(s.handlerHTTPRunSavedSearch))
+	mux.HandleFunc("POST /saved_searches/{name}/run", s.postgresOnlyHTTP(s.handlerHTTPRunSavedSearch))
+
+	mux.HandleFunc("POST /export", s.postgresOnlyHTTP(s.handlerHTTPExportLogs))
+
+	mux.HandleFunc("POST /restore", s.postgresOnlyHTTP(s.handlerHTTPRestoreLogs))
+	mux.HandleFunc("GET /restores", s.postgresOnlyHTTP(s.handlerHTTPListRestores))
+
+	mux.HandleFunc("GET /partitions", s.postgresOnlyHTTP(s.handlerHTTPGetPartitions))
+
+	mux.HandleFunc("POST /archive/segments", s.postgresOnlyHTTP(s.handlerHTTPListSegments))
+	mux.HandleFunc("POST /rehydrate", s.postgresOnlyHTTP(s.handlerHTTPRehydrate))
+
+	mux.HandleFunc("GET /legal_holds", s.postgresOnlyHTTP(s.handlerHTTPListLegalHolds))
+	mux.HandleFunc("POST /legal_holds", s.postgresOnlyHTTP(s.handlerHTTPCreateLegalHold))
+	mux.HandleFunc("POST /legal_holds/{id}/release", s.postgresOnlyHTTP(s.handlerHTTPReleaseLegalHold))
+	mux.HandleFunc("POST /verify", s.postgresOnlyHTTP(s.handlerHTTPVerifyChain))
+	mux.HandleFunc("POST /stats", s.postgresOnlyHTTP(s.handlerHTTPGetStats))
+
 	srv := &http.Server{Addr: "0.0.0.0:80", Handler: mux}
 
 	go func() {
@@ -3,14 +3,16 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
 	"time"
 
 	"github.com/nats-io/nats.go"
 
+	"log_shelter/internal/factory"
 	"log_shelter/internal/infra/notifications"
-	"log_shelter/internal/infra/reader"
-	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
 	"log_shelter/internal/usecase"
 )
 
@@ -23,32 +25,102 @@ func ParseInput[T any](data []byte) (*T, error) {
 	return &r, nil
 }
 
-func (s *Server) handlerAppendLog(msg *nats.Msg) {
-	nc := s.nats.Conn
+type errorResponse struct {
+	Error string `json:"error"`
+}
 
-	nc.Publish("nats.__internal.append", msg.Data)
+func (s *Server) respondError(msg *nats.Msg, err error) {
+	if size, ok := s.chunkSize(msg); ok {
+		e := newChunkWriter(msg, size).Close(err)
+		if e != nil {
+			slog.Error("Error in respond", "err", e)
+		}
+		return
+	}
+	data, e := json.Marshal(errorResponse{Error: err.Error()})
+	if e != nil {
+		slog.Error("Error while marshaling error response", "err", e)
+		return
+	}
+	e = msg.Respond(data)
+	if e != nil {
+		slog.Error("Error in respond", "err", e)
+	}
 }
 
-func (s *Server) handlerGetLog(msg *nats.Msg) {
-	input, err := ParseInput[usecase.GetLogRequest](msg.Data)
+// postgresOnly answers the requests of handler with model.ErrUnsupported
+// unless logs are stored in Postgres.
+func (s *Server) postgresOnly(handler nats.MsgHandler) nats.MsgHandler {
+	if s.pg != nil {
+		return handler
+	}
+	return func(msg *nats.Msg) {
+		s.respondError(msg, model.ErrUnsupported)
+	}
+}
+
+var okResponse = []byte(`{"status":"ok"}`)
+
+// serveNats parses the request, runs it against a fresh usecase factory and
+// replies with the result or with an error response.
+func serveNats[T any](
+	s *Server,
+	msg *nats.Msg,
+	run func(*factory.UsecaseFactory, T) ([]byte, error),
+) {
+	serveNatsWith(s, msg, s.factory.GetUsecaseFactory, run)
+}
+
+// serveNatsRead is serveNats for read-only usecases, which run on a
+// replica when there is one.
+func serveNatsRead[T any](
+	s *Server,
+	msg *nats.Msg,
+	run func(*factory.UsecaseFactory, T) ([]byte, error),
+) {
+	serveNatsWith(s, msg, s.factory.GetReadUsecaseFactory, run)
+}
+
+func serveNatsWith[T any](
+	s *Server,
+	msg *nats.Msg,
+	get func(context.Context) (*factory.UsecaseFactory, error),
+	run func(*factory.UsecaseFactory, T) ([]byte, error),
+) {
+	input, err := ParseInput[T](msg.Data)
 	if err != nil {
 		slog.Error("Error while parsing input", "err", err)
+		s.respondError(msg, err)
 		return
 	}
-	f, err := s.factory.GetUsecaseFactory(s.ctx)
+	f, err := get(s.natsContext(msg))
 	if err != nil {
 		slog.Error("Cannot get factory", "err", err)
+		s.respondError(msg, err)
 		return
 	}
-	data, err := f.GetGetLogUsecase().Run(*input)
-	if err != nil {
-		return
-	}
-	err = msg.Respond(data)
+	defer f.Close()
+
+	data, err := run(f, *input)
 	if err != nil {
-		slog.Error("Error in respond", "err", err)
+		slog.Error("Error in usecase", "err", err)
+		s.respondError(msg, err)
 		return
 	}
+	s.respond(msg, data)
+}
+
+func (s *Server) handlerAppendLog(msg *nats.Msg) {
+	nc := s.nats.Conn
+
+	nc.Publish("nats.__internal.append", msg.Data)
+}
+
+func (s *Server) handlerGetLog(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.GetLogRequest) ([]byte, error) {
+			return f.GetGetLogUsecase().Run(in)
+		})
 }
 
 func (s *Server) internalAppendHandler(ctx context.Context, sub *nats.Subscription) {
@@ -75,28 +147,27 @@ func (s *Server) internalAppendHandler(ctx context.Context, sub *nats.Subscripti
 				continue
 			}
 
-			conn, tx, err := s.pg.GetTranscation()
+			f, err := s.factory.GetUsecaseFactory(s.ctx)
 			if err != nil {
 				slog.Error("Error before transaction", "err", err)
 				continue
 			}
 
-			u := usecase.AppendLogUsecase{Tx: tx, LogRepo: repository.NewLogRepository(s.ctx, tx)}
-
+			u := f.GetAppendLogUsecase()
 			err = u.Run(*input)
+			f.Close()
 			if err != nil {
-				e := tx.Rollback()
-				conn.Close()
-				if e != nil {
-					slog.Error("Cannot rollback", "err", e)
-				}
 				slog.Error("Error in usecase", "err", err)
 				continue
 			}
-			conn.Close()
 			if s.tg.ShouldNotify(input.LogLevel) {
+				// Encrypted logs don't go out to Telegram in clear.
+				raw_log := input.RawLog
+				if u.Encrypts(input.Source) {
+					raw_log = "(encrypted)"
+				}
 				s.tg.Notify(notifications.NotifyLogModel{
-					RawLog:     input.RawLog,
+					RawLog:     raw_log,
 					LogLevel:   input.LogLevel,
 					Source:     input.Source,
 					RequestID:  input.RequestID,
@@ -112,34 +183,263 @@ func (s *Server) internalAppendHandler(ctx context.Context, sub *nats.Subscripti
 }
 
 func (s *Server) handlerGetTimeline(msg *nats.Msg) {
-	input, err := ParseInput[usecase.GetTimelineRequest](msg.Data)
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.GetTimelineRequest) ([]byte, error) {
+			return f.GetGetTimelineUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerGetContext(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.GetContextRequest) ([]byte, error) {
+			return f.GetGetContextUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerGetTrace(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.GetTraceRequest) ([]byte, error) {
+			return f.GetGetTraceUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerGetFacets(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.GetFacetsRequest) ([]byte, error) {
+			return f.GetGetFacetsUsecase(s.facetCache).Run(in)
+		})
+}
+
+func (s *Server) handlerGetStats(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.GetStatsRequest) ([]byte, error) {
+			return f.GetGetStatsUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerGetTop(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.GetTopRequest) ([]byte, error) {
+			return f.GetGetTopUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerGetSimilar(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.GetSimilarRequest) ([]byte, error) {
+			return f.GetGetSimilarUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerCompareWindows(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.CompareWindowsRequest) ([]byte, error) {
+			return f.GetCompareWindowsUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerGetPartitions(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, _ struct{}) ([]byte, error) {
+			return f.GetGetPartitionsUsecase().Run()
+		})
+}
+
+func (s *Server) handlerListSegments(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.SegmentsRequest) ([]byte, error) {
+			return f.GetListSegmentsUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerRehydrate(msg *nats.Msg) {
+	serveNats(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.SegmentsRequest) ([]byte, error) {
+			return f.GetRehydrateUsecase(s.archiver).Run(in)
+		})
+}
+
+func (s *Server) handlerCreateLegalHold(msg *nats.Msg) {
+	serveNats(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.CreateLegalHoldRequest) ([]byte, error) {
+			return f.GetCreateLegalHoldUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerListLegalHolds(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.ListLegalHoldsRequest) ([]byte, error) {
+			return f.GetListLegalHoldsUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerReleaseLegalHold(msg *nats.Msg) {
+	serveNats(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.ReleaseLegalHoldRequest) ([]byte, error) {
+			return okResponse, f.GetReleaseLegalHoldUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerVerifyChain(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.VerifyChainRequest) ([]byte, error) {
+			return f.GetVerifyChainUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerCreateSavedSearch(msg *nats.Msg) {
+	serveNats(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.SavedSearchRequest) ([]byte, error) {
+			return f.GetCreateSavedSearchUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerUpdateSavedSearch(msg *nats.Msg) {
+	serveNats(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.SavedSearchRequest) ([]byte, error) {
+			return okResponse, f.GetUpdateSavedSearchUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerDeleteSavedSearch(msg *nats.Msg) {
+	serveNats(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.SavedSearchNameRequest) ([]byte, error) {
+			return okResponse, f.GetDeleteSavedSearchUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerGetSavedSearch(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.SavedSearchNameRequest) ([]byte, error) {
+			return f.GetGetSavedSearchUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerListSavedSearches(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.ListSavedSearchesRequest) ([]byte, error) {
+			return f.GetListSavedSearchesUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerRunSavedSearch(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.RunSavedSearchRequest) ([]byte, error) {
+			return f.GetRunSavedSearchUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerRestoreLogs(msg *nats.Msg) {
+	serveNats(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.RestoreLogsRequest) ([]byte, error) {
+			return f.GetRestoreLogsUsecase().Run(in)
+		})
+}
+
+func (s *Server) handlerListRestores(msg *nats.Msg) {
+	serveNatsRead(s, msg,
+		func(f *factory.UsecaseFactory, in usecase.ListRestoresRequest) ([]byte, error) {
+			return f.GetListRestoresUsecase().Run(in)
+		})
+}
+
+// handlerExportLogs streams the export into the JetStream object store and
+// replies with a reference to the stored object. Requesters that negotiated
+// chunked replies get the exported file itself as a chunked stream instead.
+func (s *Server) handlerExportLogs(msg *nats.Msg) {
+	input, err := ParseInput[usecase.ExportLogsRequest](msg.Data)
 	if err != nil {
 		slog.Error("Error while parsing input", "err", err)
+		s.respondError(msg, err)
 		return
 	}
-
-	conn, tx, err := s.pg.GetTranscation()
+	format, err := input.ExportFormat()
 	if err != nil {
-		slog.Error("Error before transaction", "err", err)
+		s.respondError(msg, err)
+		return
+	}
+	if size, ok := s.chunkSize(msg); ok {
+		s.streamExport(msg, *input, size)
+		return
+	}
+	store, err := s.nats.ObjectStore(s.cfg.Export.Bucket)
+	if err != nil {
+		slog.Error("Cannot open object store", "err", err)
+		s.respondError(msg, err)
+		return
+	}
+	f, err := s.factory.GetReadUsecaseFactory(s.natsContext(msg))
+	if err != nil {
+		slog.Error("Cannot get factory", "err", err)
+		s.respondError(msg, err)
 		return
 	}
-	defer conn.Close()
+	defer f.Close()
 
-	u := usecase.GetTimelineUsecase{Tx: tx, LogReader: reader.NewLogReader(s.ctx, tx)}
+	name := fmt.Sprintf("logs-%s.%s",
+		time.Now().UTC().Format("20060102T150405.000000000Z"),
+		format.Extension(input.Gzip))
 
-	data, err := u.Run(*input)
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(f.GetExportLogsUsecase().Run(*input, pw))
+	}()
+
+	info, err := store.Put(&nats.ObjectMeta{Name: name}, pr)
+	pr.CloseWithError(err)
+	<-done
 	if err != nil {
-		tx.Rollback()
-		slog.Error("Error in usecase", "err", err)
+		slog.Error("Error in export", "err", err)
+		s.respondError(msg, err)
 		return
 	}
 
+	data, err := json.Marshal(model.ExportReference{
+		Bucket: info.Bucket,
+		Name:   info.Name,
+		Size:   info.Size,
+		Chunks: info.Chunks,
+		Digest: info.Digest,
+	})
+	if err != nil {
+		s.respondError(msg, err)
+		return
+	}
 	err = msg.Respond(data)
 	if err != nil {
-		tx.Rollback()
 		slog.Error("Error in respond", "err", err)
+	}
+}
+
+func (s *Server) streamExport(msg *nats.Msg, input usecase.ExportLogsRequest, size int) {
+	f, err := s.factory.GetReadUsecaseFactory(s.natsContext(msg))
+	if err != nil {
+		slog.Error("Cannot get factory", "err", err)
+		s.respondError(msg, err)
+		return
+	}
+	defer f.Close()
+
+	w := newChunkWriter(msg, size)
+	err = f.GetExportLogsUsecase().Run(input, w)
+	if err != nil {
+		slog.Error("Error in export", "err", err)
+	}
+	err = w.Close(err)
+	if err != nil {
+		slog.Error("Error in respond", "err", err)
+	}
+}
+
+func (s *Server) handlerHealth(msg *nats.Msg) {
+	data, err := json.Marshal(s.db.Health())
+	if err != nil {
+		s.respondError(msg, err)
 		return
 	}
+	s.respond(msg, data)
 }
 
 func (s *Server) handlerDebezium(msg *nats.Msg) {
@@ -180,6 +480,94 @@ func (s *Server) setupNatsAPI() {
 		slog.Default().Error("Cannot create subscriber", "err", err)
 	}
 
+	_, err = nc.Subscribe(
+		"log_shelter.context",
+		s.postgresOnly(s.handlerGetContext),
+	)
+	if err != nil {
+		slog.Default().Error("Cannot create subscriber", "err", err)
+	}
+
+	_, err = nc.Subscribe(
+		"log_shelter.trace",
+		s.postgresOnly(s.handlerGetTrace),
+	)
+	if err != nil {
+		slog.Default().Error("Cannot create subscriber", "err", err)
+	}
+
+	_, err = nc.Subscribe(
+		"log_shelter.export",
+		s.postgresOnly(s.handlerExportLogs),
+	)
+	if err != nil {
+		slog.Default().Error("Cannot create subscriber", "err", err)
+	}
+
+	_, err = nc.Subscribe(
+		"log_shelter.facets",
+		s.postgresOnly(s.handlerGetFacets),
+	)
+	if err != nil {
+		slog.Default().Error("Cannot create subscriber", "err", err)
+	}
+
+	_, err = nc.Subscribe(
+		"log_shelter.top",
+		s.postgresOnly(s.handlerGetTop),
+	)
+	if err != nil {
+		slog.Default().Error("Cannot create subscriber", "err", err)
+	}
+
+	_, err = nc.Subscribe(
+		"log_shelter.similar",
+		s.postgresOnly(s.handlerGetSimilar),
+	)
+	if err != nil {
+		slog.Default().Error("Cannot create subscriber", "err", err)
+	}
+
+	_, err = nc.Subscribe(
+		"log_shelter.compare",
+		s.postgresOnly(s.handlerCompareWindows),
+	)
+	if err != nil {
+		slog.Default().Error("Cannot create subscriber", "err", err)
+	}
+
+	_, err = nc.Subscribe(
+		"log_shelter.health",
+		s.handlerHealth,
+	)
+	if err != nil {
+		slog.Default().Error("Cannot create subscriber", "err", err)
+	}
+
+	for subject, handler := range map[string]nats.MsgHandler{
+		"log_shelter.saved.create":  s.handlerCreateSavedSearch,
+		"log_shelter.saved.update":  s.handlerUpdateSavedSearch,
+		"log_shelter.saved.delete":  s.handlerDeleteSavedSearch,
+		"log_shelter.saved.get":     s.handlerGetSavedSearch,
+		"log_shelter.saved.list":    s.handlerListSavedSearches,
+		"log_shelter.saved.run":     s.handlerRunSavedSearch,
+		"log_shelter.restore":       s.handlerRestoreLogs,
+		"log_shelter.restore.list":  s.handlerListRestores,
+		"log_shelter.partitions":    s.handlerGetPartitions,
+		"log_shelter.archive.list":  s.handlerListSegments,
+		"log_shelter.rehydrate":     s.handlerRehydrate,
+		"log_shelter.holds.create":  s.handlerCreateLegalHold,
+		"log_shelter.holds.list":    s.handlerListLegalHolds,
+		"log_shelter.holds.release": s.handlerReleaseLegalHold,
+		"log_shelter.verify":        s.handlerVerifyChain,
+		"log_shelter.stats":         s.handlerGetStats,
+	} {
+		_, err = nc.Subscribe(subject, s.postgresOnly(handler))
+		if err != nil {
+			slog.Default().Error("Cannot create subscriber", "err", err, "subject", subject)
+		}
+	}
+
 	_, err = nc.Subscribe(
 		"log_shelter.__internal.postgres.*.*",
 		s.handlerDebezium,
@@ -2,21 +2,42 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
 
 	"log_shelter/internal/config"
 	"log_shelter/internal/factory"
 	"log_shelter/internal/infra"
+	"log_shelter/internal/infra/archive"
+	"log_shelter/internal/infra/cache"
+	"log_shelter/internal/infra/envelope"
+	"log_shelter/internal/infra/integrity"
 	"log_shelter/internal/infra/notifications"
+	"log_shelter/pkg/client"
 )
 
 type Server struct {
-	ctx     context.Context
-	cfg     *config.Config
-	nats    *infra.NatsInfra
+	ctx  context.Context
+	cfg  *config.Config
+	nats *infra.NatsInfra
+	// pg is nil with the sqlite storage backend.
 	pg      *infra.PostgresInfra
+	db      factory.Database
 	tg      *notifications.TelegramNotifications
 	es      *infra.ElastickInfra
 	factory *factory.Factory
+
+	// archiver is nil when archiving is disabled.
+	archiver *archive.Archiver
+	// signer is nil without a checkpoint signing key.
+	signer *integrity.Signer
+	// keys is nil without a master key.
+	keys *envelope.Keyring
+
+	facetCache *cache.MemoryCache[[]byte]
 }
 
 func NewServer(ctx context.Context, cfg *config.Config) *Server {
@@ -28,7 +49,35 @@ func NewServer(ctx context.Context, cfg *config.Config) *Server {
 		panic(err)
 	}
 
-	pg, err := infra.NewPostgresInfra(ctx, &cfg.Postgres)
+	var db factory.Database
+	switch cfg.Storage.Backend {
+	case "", config.StoragePostgres:
+		pg, err := infra.NewPostgresInfra(ctx, &cfg.Postgres)
+		if err != nil {
+			panic(err)
+		}
+		if cfg.Postgres.MigrateOnStartup {
+			err = pg.Migrate()
+			if err != nil {
+				panic(err)
+			}
+		}
+		srv.pg = pg
+		db = pg
+	case config.StorageSQLite:
+		if cfg.Partitions.Enabled || cfg.Archive.Enabled || len(cfg.Integrity.Sources) != 0 ||
+			cfg.Encryption.HasMasterKey() {
+			panic("partitions, archive, integrity and encryption need the postgres storage backend")
+		}
+		db, err = infra.NewSQLiteInfra(ctx, &cfg.Storage.SQLite)
+		if err != nil {
+			panic(err)
+		}
+	default:
+		panic(fmt.Sprintf("unknown storage backend %q", cfg.Storage.Backend))
+	}
+
+	err = factory.CheckPartitionRetention(cfg)
 	if err != nil {
 		panic(err)
 	}
@@ -38,15 +87,41 @@ func NewServer(ctx context.Context, cfg *config.Config) *Server {
 		panic(err)
 	}
 
+	archiver, err := archive.NewArchiver(&cfg.Archive)
+	if err != nil {
+		panic(err)
+	}
+
+	if cfg.Integrity.SigningKeyFile != "" {
+		srv.signer, err = integrity.LoadSigner(cfg.Integrity.SigningKeyFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var keys *envelope.Keyring
+	if cfg.Encryption.HasMasterKey() {
+		keys, err = envelope.LoadKeyring(cfg.Encryption.MasterKeyFile, cfg.Encryption.MasterKeyEnv)
+		if err != nil {
+			panic(err)
+		}
+	} else if len(cfg.Encryption.Sources) != 0 {
+		panic("encrypted sources need a master key")
+	}
+
 	srv.nats = nats
 	srv.ctx = ctx
-	srv.pg = pg
+	srv.db = db
 	srv.tg = tg
+	srv.archiver = archiver
+	srv.keys = keys
 	srv.es = infra.NewElastickInfra()
 
-	f := factory.NewFactory(srv.pg)
+	f := factory.NewFactory(db, cfg, keys, srv.signer)
 	srv.factory = f
 
+	srv.facetCache = cache.NewMemoryCache[[]byte](time.Duration(cfg.Facets.CacheTTL))
+
 	return &srv
 }
 
@@ -55,3 +130,25 @@ func (s *Server) Run() {
 
 	<-s.ctx.Done()
 }
+
+// decryptContext returns ctx allowed to decrypt logs when token is one of
+// the configured decrypt tokens, ctx itself otherwise.
+func (s *Server) decryptContext(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	for _, allowed := range s.cfg.Encryption.DecryptTokens {
+		if subtle.ConstantTimeCompare([]byte(allowed), []byte(token)) == 1 {
+			return envelope.AllowDecrypt(ctx)
+		}
+	}
+	return ctx
+}
+
+// natsContext is the context the NATS request msg is served under.
+func (s *Server) natsContext(msg *nats.Msg) context.Context {
+	if msg.Header == nil {
+		return s.ctx
+	}
+	return s.decryptContext(s.ctx, msg.Header.Get(client.HeaderDecryptToken))
+}
@@ -5,36 +5,181 @@ import (
 	"log/slog"
 	"time"
 
+	"log_shelter/internal/infra/integrity"
 	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
 )
 
 type AppendLogRequest struct {
-	RawLog     string    `json:"raw_log"`
-	LogLevel   string    `json:"log_level"`
-	Source     string    `json:"source"`
-	CreatedAt  time.Time `json:"created_at"`
-	RequestID  *string   `json:"request_id,omitempty"`
-	LoggerName *string   `json:"logger_name,omitempty"`
+	RawLog       string         `json:"raw_log"`
+	LogLevel     string         `json:"log_level"`
+	Source       string         `json:"source"`
+	CreatedAt    time.Time      `json:"created_at"`
+	RequestID    *string        `json:"request_id,omitempty"`
+	LoggerName   *string        `json:"logger_name,omitempty"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Traceparent  *string        `json:"traceparent,omitempty"`
+	TraceID      *string        `json:"trace_id,omitempty"`
+	SpanID       *string        `json:"span_id,omitempty"`
+	ParentSpanID *string        `json:"parent_span_id,omitempty"`
+}
+
+// validID returns id when it is a lowercase hex ID of size characters.
+// Other IDs are dropped, as they wouldn't fit their columns.
+func validID(name string, id *string, size int) *string {
+	if id == nil || isHexID(*id, size) {
+		return id
+	}
+	slog.Warn("Ignoring malformed "+name, name, *id)
+	return nil
+}
+
+// traceContext returns the trace and span IDs of the entry. Explicit
+// trace_id/span_id win over the ones carried by traceparent, which is also
+// looked up in attributes when it isn't set at the top level.
+func (r *AppendLogRequest) traceContext() (*string, *string) {
+	trace_id := validID("trace_id", r.TraceID, 32)
+	span_id := validID("span_id", r.SpanID, 16)
+	if trace_id != nil && span_id != nil {
+		return trace_id, span_id
+	}
+
+	header := r.Traceparent
+	if header == nil {
+		if v, ok := r.Attributes["traceparent"].(string); ok {
+			header = &v
+		}
+	}
+	if header == nil {
+		return trace_id, span_id
+	}
+
+	tp, err := ParseTraceparent(*header)
+	if err != nil {
+		slog.Warn("Ignoring malformed traceparent", "traceparent", *header)
+		return trace_id, span_id
+	}
+	if trace_id == nil {
+		trace_id = &tp.TraceID
+	}
+	if span_id == nil {
+		span_id = &tp.SpanID
+	}
+	return trace_id, span_id
 }
 
 type AppendLogUsecase struct {
 	Tx      *sql.Tx
-	LogRepo *repository.LogRepository
+	LogRepo LogAppender
+	// Chain is nil unless hash chains are enabled.
+	Chain *Chaining
+	// Encryption is nil without a master key.
+	Encryption *Encryption
+	// Rollups is nil with storage backends that don't keep rollups.
+	Rollups *repository.RollupRepository
 }
 
 func (u *AppendLogUsecase) Run(data AppendLogRequest) error {
+	trace_id, span_id := data.traceContext()
+	data.ParentSpanID = validID("parent_span_id", data.ParentSpanID, 16)
+	log_level := model.NormalizeLevel(data.LogLevel)
+	var level_rank *int16
+	if l, ok := model.ParseLevel(data.LogLevel); ok {
+		rank := int16(l)
+		level_rank = &rank
+	}
+
+	err := u.append(data, log_level, level_rank, trace_id, span_id)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... append", "Err", err)
+	}
+	u.Tx.Commit()
+	return err
+}
+
+// Encrypts tells whether logs of source are stored encrypted, so their
+// payload must not leave the server in clear either.
+func (u *AppendLogUsecase) Encrypts(source string) bool {
+	return u.Encryption != nil && u.Encryption.covers(source)
+}
+
+func (u *AppendLogUsecase) append(
+	data AppendLogRequest,
+	log_level string,
+	level_rank *int16,
+	trace_id *string,
+	span_id *string,
+) error {
+	created_at := data.CreatedAt
+	var link *model.ChainLink
+	if u.Chain != nil && u.Chain.covers(data.Source) {
+		created_at = integrity.Truncate(created_at)
+		entry := model.LogModel{
+			RawLog:       data.RawLog,
+			LogLevel:     log_level,
+			RawLevel:     &data.LogLevel,
+			Source:       data.Source,
+			CreatedAt:    created_at,
+			RequestID:    data.RequestID,
+			Attributes:   data.Attributes,
+			TraceID:      trace_id,
+			SpanID:       span_id,
+			ParentSpanID: data.ParentSpanID,
+		}
+		if data.LoggerName != nil {
+			entry.LoggerName = *data.LoggerName
+		}
+		next, err := u.Chain.next(&entry)
+		if err != nil {
+			return err
+		}
+		link = &next
+	}
+
+	// The chain covers the payload before it's encrypted, so it survives
+	// re-encryption.
+	raw_log, attributes := data.RawLog, data.Attributes
+	var sealed *model.Sealed
+	if u.Encryption != nil && u.Encryption.covers(data.Source) {
+		// The AAD binds created_at as Postgres stores it.
+		created_at = integrity.Truncate(created_at)
+		var err error
+		sealed, err = u.Encryption.seal(data.Source, created_at, raw_log, attributes)
+		if err != nil {
+			return err
+		}
+		raw_log, attributes = "", nil
+	}
+
 	err := u.LogRepo.AppendLog(
-		data.RawLog,
+		raw_log,
+		log_level,
 		data.LogLevel,
+		level_rank,
 		data.Source,
-		data.CreatedAt,
+		created_at,
 		data.RequestID,
 		data.LoggerName,
+		attributes,
+		trace_id,
+		span_id,
+		data.ParentSpanID,
+		link,
+		sealed,
 	)
 	if err != nil {
-		u.Tx.Rollback()
-		slog.Error("oops... append", "Err", err)
+		return err
 	}
-	u.Tx.Commit()
-	return err
+	if link != nil {
+		err = u.Chain.ChainRepo.AdvanceHead(data.Source, *link)
+		if err != nil {
+			return err
+		}
+	}
+	if u.Rollups != nil {
+		return u.Rollups.CountLog(
+			created_at, data.Source, log_level, level_rank, data.LoggerName, len(data.RawLog))
+	}
+	return nil
 }
@@ -0,0 +1,286 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/infra/archive"
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultArchiveBatch  = 1000
+	defaultSegmentsLimit = 100
+	maxSegmentsLimit     = 1000
+	rehydrateInsertBatch = 1000
+)
+
+// Archival deletes logs for good only after writing them to the archive
+// and its manifest. A single batch runs in Tx, Delete commits every batch
+// in a transaction of its own from Fresh.
+type Archival struct {
+	Ctx         context.Context
+	Tx          *sql.Tx
+	Archiver    *archive.Archiver
+	LogReader   *reader.LogReader
+	LogRepo     *repository.LogRepository
+	ArchiveRepo *repository.ArchiveRepository
+	BatchSize   uint64
+	// Fresh returns an Archival over a new transaction.
+	Fresh func() (*Archival, error)
+}
+
+func (a *Archival) batchSize() uint64 {
+	if a.BatchSize == 0 {
+		return defaultArchiveBatch
+	}
+	return a.BatchSize
+}
+
+// batch archives and deletes up to limit logs matching where.
+func (a *Archival) batch(where squirrel.Sqlizer, limit uint64) (int64, error) {
+	logs, err := a.LogReader.ReadExpiring(where, limit)
+	if err != nil || len(logs) == 0 {
+		return 0, err
+	}
+
+	segments, err := a.Archiver.Archive(a.Ctx, logs)
+	if err != nil {
+		return 0, err
+	}
+	for _, segment := range segments {
+		_, err = a.ArchiveRepo.RecordSegment(segment)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	ids := make([]uint64, 0, len(logs))
+	for _, l := range logs {
+		ids = append(ids, l.ID)
+	}
+	return a.LogRepo.DeleteLogs(ids)
+}
+
+// Delete archives and deletes every log matching where, committing batch
+// by batch like the purge does, so a large deletion neither runs in one
+// long transaction nor loses the batches already archived on failure.
+func (a *Archival) Delete(where squirrel.Sqlizer) (int64, error) {
+	limit := a.batchSize()
+	var total int64
+	for {
+		next, err := a.Fresh()
+		if err != nil {
+			return total, err
+		}
+		n, err := next.batch(where, limit)
+		if err != nil {
+			next.Tx.Rollback()
+			return total, err
+		}
+		err = next.Tx.Commit()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if uint64(n) < limit {
+			return total, nil
+		}
+	}
+}
+
+type PurgeLogsUsecase struct {
+	Tx      *sql.Tx
+	LogRepo LogExpirer
+	// Archive is nil when archiving is disabled.
+	Archive *Archival
+}
+
+// Run deletes a single batch of logs soft-deleted more than grace before
+// now and returns how many were removed.
+func (u *PurgeLogsUsecase) Run(now time.Time, grace time.Duration, limit uint64) (int64, error) {
+	var n int64
+	var err error
+	if u.Archive != nil {
+		n, err = u.Archive.batch(reader.Purgeable(now.Add(-grace)), limit)
+	} else {
+		n, err = u.LogRepo.PurgeDeleted(grace, limit)
+	}
+	if err != nil {
+		u.Tx.Rollback()
+		return 0, err
+	}
+	return n, u.Tx.Commit()
+}
+
+// SegmentsRequest selects archived segments by source and by the time
+// range of the logs they hold.
+type SegmentsRequest struct {
+	Sources []string  `json:"sources,omitempty"`
+	From    *TimeExpr `json:"from,omitempty"`
+	To      *TimeExpr `json:"to,omitempty"`
+	Tz      *string   `json:"tz,omitempty"`
+	Limit   uint64    `json:"limit,omitempty"`
+}
+
+func (r *SegmentsRequest) limit() uint64 {
+	if r.Limit == 0 {
+		return defaultSegmentsLimit
+	}
+	return min(r.Limit, maxSegmentsLimit)
+}
+
+func (r *SegmentsRequest) bounds(now time.Time) (*time.Time, *time.Time, error) {
+	loc, err := location(r.Tz)
+	if err != nil {
+		return nil, nil, err
+	}
+	var from, to *time.Time
+	if r.From != nil {
+		t, err := r.From.Resolve(now, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		from = &t
+	}
+	if r.To != nil {
+		t, err := r.To.Resolve(now, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		to = &t
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, fmt.Errorf("%w: to is before from", model.ErrInvalidRequest)
+	}
+	return from, to, nil
+}
+
+type ListSegmentsUsecase struct {
+	Tx            *sql.Tx
+	ArchiveReader *reader.ArchiveReader
+}
+
+func (u *ListSegmentsUsecase) Run(data SegmentsRequest) ([]byte, error) {
+	from, to, err := data.bounds(time.Now())
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	result, err := u.ArchiveReader.ReadSegments(data.Sources, from, to, data.limit())
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
+
+type RehydrateUsecase struct {
+	Ctx           context.Context
+	Tx            *sql.Tx
+	Archiver      *archive.Archiver
+	ArchiveReader *reader.ArchiveReader
+	ArchiveRepo   *repository.ArchiveRepository
+}
+
+// load copies the logs of a segment into logs_rehydrated.
+func (u *RehydrateUsecase) load(segment model.ArchiveSegment) (int64, error) {
+	dec, err := u.Archiver.Open(u.Ctx, segment)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	batch := make([]model.LogModel, 0, rehydrateInsertBatch)
+	flush := func() error {
+		n, err := u.ArchiveRepo.RehydrateLogs(segment.ID, batch)
+		total += n
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		entry, err := dec.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("archive %s: %w", segment.Key, err)
+		}
+		batch = append(batch, *entry)
+		if len(batch) == rehydrateInsertBatch {
+			err = flush()
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	return total, flush()
+}
+
+// Run loads the selected segments back into logs_rehydrated, where they
+// stay until deleted by hand.
+func (u *RehydrateUsecase) Run(data SegmentsRequest) ([]byte, error) {
+	if u.Archiver == nil {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: archiving is disabled", model.ErrInvalidRequest)
+	}
+	if data.From == nil || data.To == nil {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: from and to are required", model.ErrInvalidRequest)
+	}
+	from, to, err := data.bounds(time.Now())
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	segments, err := u.ArchiveReader.ReadSegments(data.Sources, from, to, data.limit())
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+
+	result := model.Rehydration{Segments: segments, Table: reader.RehydratedTable}
+	for _, segment := range segments {
+		n, err := u.load(segment)
+		if err != nil {
+			u.Tx.Rollback()
+			slog.Error("oops... rehydrate", "Err", err, "segment", segment.Key)
+			return nil, err
+		}
+		result.Rows += n
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	err = u.Tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return bytes, nil
+}
@@ -0,0 +1,365 @@
+package usecase
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"log_shelter/internal/infra/integrity"
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultVerifyLimit = 100_000
+	maxVerifyLimit     = 10_000_000
+	verifyBatchSize    = 1000
+)
+
+// Chaining links the logs of Sources into per-source hash chains as they
+// are appended. Sources accept "*" patterns.
+type Chaining struct {
+	Sources   []string
+	ChainRepo *repository.ChainRepository
+}
+
+func (c *Chaining) covers(source string) bool {
+	return matchAnyPattern(c.Sources, source)
+}
+
+func matchAnyPattern(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches s against pattern, where "*" stands for any run of
+// characters.
+func matchPattern(pattern string, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// next locks the chain of entry's source and returns the link of entry.
+func (c *Chaining) next(entry *model.LogModel) (model.ChainLink, error) {
+	head, err := c.ChainRepo.LockHead(entry.Source)
+	if err != nil {
+		return model.ChainLink{}, err
+	}
+	return integrity.Link(head, entry)
+}
+
+type CheckpointChainsUsecase struct {
+	Tx          *sql.Tx
+	ChainReader *reader.ChainReader
+	ChainRepo   *repository.ChainRepository
+	Signer      *integrity.Signer
+}
+
+// Run signs the heads of the chains that moved since their last
+// checkpoint and returns the new checkpoints.
+func (u *CheckpointChainsUsecase) Run(now time.Time) ([]model.ChainCheckpoint, error) {
+	heads, err := u.ChainReader.ReadHeads(nil)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+
+	ret := make([]model.ChainCheckpoint, 0)
+	for _, head := range heads {
+		if head.Link.Seq == 0 || head.Link.Seq <= head.Checkpoint {
+			continue
+		}
+		checkpoint := u.Signer.Checkpoint(head, now)
+		err := u.ChainRepo.RecordCheckpoint(checkpoint)
+		if err != nil {
+			u.Tx.Rollback()
+			slog.Error("oops... checkpoint", "Err", err, "source", head.Source)
+			return nil, err
+		}
+		ret = append(ret, checkpoint)
+	}
+
+	err = u.Tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// VerifyChainRequest walks the chain of Source from FromSeq to ToSeq,
+// which default to the oldest link left and the head. Limit caps the
+// number of logs walked.
+type VerifyChainRequest struct {
+	Source  string  `json:"source"`
+	FromSeq *uint64 `json:"from_seq,omitempty"`
+	ToSeq   *uint64 `json:"to_seq,omitempty"`
+	Limit   uint64  `json:"limit,omitempty"`
+}
+
+// VerifyChainUsecase checks the signatures of the checkpoints made with
+// the key of Signer, when set, along with the hashes every checkpoint pins.
+type VerifyChainUsecase struct {
+	Tx          *sql.Tx
+	ChainReader *reader.ChainReader
+	Signer      *integrity.Signer
+}
+
+func (u *VerifyChainUsecase) Run(data VerifyChainRequest) ([]byte, error) {
+	if data.Source == "" {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: source is required", model.ErrInvalidRequest)
+	}
+
+	ret, err := u.verify(data)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... verify", "Err", err, "source", data.Source)
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(ret)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
+
+func (u *VerifyChainUsecase) verify(data VerifyChainRequest) (*model.ChainVerification, error) {
+	heads, err := u.ChainReader.ReadHeads(&data.Source)
+	if err != nil {
+		return nil, err
+	}
+	if len(heads) == 0 {
+		return nil, fmt.Errorf("%w: %s", model.ErrChainNotFound, data.Source)
+	}
+	head := heads[0]
+
+	ret := &model.ChainVerification{Source: data.Source, Head: head.Link.Seq, Intact: true}
+
+	from := uint64(1)
+	if data.FromSeq != nil {
+		from = max(*data.FromSeq, 1)
+	} else {
+		first, err := u.ChainReader.ReadFirstSeq(data.Source)
+		if err != nil {
+			return nil, err
+		}
+		from = max(first, 1)
+	}
+	to := head.Link.Seq
+	if data.ToSeq != nil {
+		to = min(*data.ToSeq, to)
+	}
+	if from > to {
+		return nil, fmt.Errorf("%w: from_seq %d is past to_seq %d (head is %d)",
+			model.ErrInvalidRequest, from, to, head.Link.Seq)
+	}
+	ret.From, ret.To = from, to
+
+	limit := data.Limit
+	if limit == 0 {
+		limit = defaultVerifyLimit
+	}
+	limit = min(limit, maxVerifyLimit)
+
+	tombstones, err := u.ChainReader.ReadTombstones(data.Source, from-1, to)
+	if err != nil {
+		return nil, err
+	}
+	// deleted returns the tombstone recording the deletion of the link
+	// with seq, nil when it wasn't deleted.
+	deleted := func(seq uint64) *model.ChainTombstone {
+		i := sort.Search(len(tombstones), func(i int) bool { return tombstones[i].ToSeq >= seq })
+		if i < len(tombstones) && tombstones[i].FromSeq <= seq {
+			return &tombstones[i]
+		}
+		return nil
+	}
+
+	// Checkpoints past to may cover the last link of a tombstone.
+	upto := to
+	if len(tombstones) != 0 {
+		upto = max(upto, tombstones[len(tombstones)-1].ToSeq)
+	}
+	checkpoints := make(map[uint64]model.ChainCheckpoint)
+	list, err := u.ChainReader.ReadCheckpoints(data.Source, from-1, upto)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range list {
+		checkpoints[c.Seq] = c
+	}
+
+	// trusted tells whether the hash t kept can be built on: t is signed
+	// with the signing key, or a checkpoint signed with it covers its last
+	// link. Anyone able to write to the database could forge any other.
+	trusted := func(t *model.ChainTombstone) bool {
+		if u.Signer == nil {
+			return false
+		}
+		if u.Signer.VerifyTombstone(*t) {
+			return true
+		}
+		c, ok := checkpoints[t.ToSeq]
+		return ok && u.Signer.Verify(c) && bytes.Equal(c.Hash, t.Hash)
+	}
+
+	// prev is the hash the link at from builds on. When that link is gone
+	// without a trusted tombstone, from is trusted as stored and the walk
+	// is anchored there.
+	var prev []byte
+	if from == 1 {
+		prev = integrity.Genesis
+	} else {
+		link, err := u.ChainReader.ReadLink(data.Source, from-1)
+		if err != nil {
+			return nil, err
+		}
+		if link != nil {
+			prev = link.Hash
+		} else if t := deleted(from - 1); t != nil && trusted(t) {
+			prev = t.Hash
+		} else {
+			ret.Anchored = true
+		}
+	}
+
+	expected := from
+	for expected <= to && ret.Rows < limit {
+		// Links deleted with a trusted tombstone are skipped, the link after
+		// them builds on the hash the tombstone kept.
+		if t := deleted(expected); t != nil {
+			if !trusted(t) {
+				ret.Break = &model.ChainBreak{
+					Seq: expected, Reason: model.ChainBreakUnsignedTombstone,
+				}
+				break
+			}
+			if c, ok := checkpoints[t.ToSeq]; ok {
+				ret.Checkpoints++
+				if u.Signer != nil && c.KeyID == u.Signer.KeyID && !u.Signer.Verify(c) {
+					ret.Break = &model.ChainBreak{Seq: t.ToSeq, Reason: model.ChainBreakBadSignature}
+					break
+				}
+				if !bytes.Equal(c.Hash, t.Hash) {
+					ret.Break = &model.ChainBreak{
+						Seq:      t.ToSeq,
+						Reason:   model.ChainBreakCheckpointMismatch,
+						Expected: c.Hash,
+						Actual:   t.Hash,
+					}
+					break
+				}
+			}
+			ret.Pruned += min(t.ToSeq, to) - expected + 1
+			prev = t.Hash
+			expected = t.ToSeq + 1
+			continue
+		}
+
+		batch := min(uint64(verifyBatchSize), limit-ret.Rows, to-expected+1)
+		links, err := u.ChainReader.ReadChain(data.Source, expected, to, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		skip := false
+		for _, entry := range links {
+			if entry.Link.Seq != expected {
+				if deleted(expected) != nil {
+					skip = true
+					break
+				}
+				ret.Break = &model.ChainBreak{Seq: expected, Reason: model.ChainBreakMissing}
+				break
+			}
+
+			id := entry.ID
+			if entry.Encrypted {
+				return nil, fmt.Errorf("log %d is encrypted, verifying it needs the master key", id)
+			}
+			if prev != nil {
+				sum, err := integrity.Hash(prev, entry.Link.Seq, &entry.LogModel)
+				if err != nil {
+					return nil, err
+				}
+				if !bytes.Equal(sum, entry.Link.Hash) {
+					ret.Break = &model.ChainBreak{
+						Seq:      expected,
+						LogID:    &id,
+						Reason:   model.ChainBreakModified,
+						Expected: sum,
+						Actual:   entry.Link.Hash,
+					}
+					break
+				}
+			}
+
+			if c, ok := checkpoints[expected]; ok {
+				ret.Checkpoints++
+				if u.Signer != nil && c.KeyID == u.Signer.KeyID && !u.Signer.Verify(c) {
+					ret.Break = &model.ChainBreak{
+						Seq: expected, LogID: &id, Reason: model.ChainBreakBadSignature,
+					}
+					break
+				}
+				if !bytes.Equal(c.Hash, entry.Link.Hash) {
+					ret.Break = &model.ChainBreak{
+						Seq:      expected,
+						LogID:    &id,
+						Reason:   model.ChainBreakCheckpointMismatch,
+						Expected: c.Hash,
+						Actual:   entry.Link.Hash,
+					}
+					break
+				}
+			}
+
+			prev = entry.Link.Hash
+			expected++
+			ret.Rows++
+		}
+		if ret.Break != nil {
+			break
+		}
+		if skip || (uint64(len(links)) < batch && deleted(expected) != nil) {
+			continue
+		}
+		if uint64(len(links)) < batch {
+			ret.Break = &model.ChainBreak{Seq: expected, Reason: model.ChainBreakMissing}
+			break
+		}
+	}
+
+	ret.Intact = ret.Break == nil
+	ret.Complete = ret.Break != nil || expected > to
+	return ret, nil
+}
@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultCompareLimit     = 20
+	maxCompareLimit         = 1000
+	defaultCompareMinEffect = 3
+	// compareCandidates bounds how many values per field are counted before
+	// ranking, the busiest ones are kept.
+	compareCandidates = 10000
+)
+
+type TimeWindowRequest struct {
+	From TimeExpr `json:"from"`
+	To   TimeExpr `json:"to"`
+}
+
+func (r *TimeWindowRequest) resolve(name string, now time.Time, loc *time.Location) (model.TimeWindow, error) {
+	from, err := r.From.Resolve(now, loc)
+	if err != nil {
+		return model.TimeWindow{}, err
+	}
+	to, err := r.To.Resolve(now, loc)
+	if err != nil {
+		return model.TimeWindow{}, err
+	}
+	if !from.Before(to) {
+		return model.TimeWindow{}, fmt.Errorf("%w: %s window is empty", model.ErrInvalidRequest, name)
+	}
+	return model.TimeWindow{From: from, To: to}, nil
+}
+
+// CompareWindowsRequest compares logs matching the filter in the baseline
+// window against the target window. The windows replace the before and
+// after bounds of the filter.
+type CompareWindowsRequest struct {
+	LogFilterRequest
+	Baseline  TimeWindowRequest `json:"baseline"`
+	Target    TimeWindowRequest `json:"target"`
+	Fields    []string          `json:"fields,omitempty"`
+	MinEffect *float64          `json:"min_effect,omitempty"`
+	Unchanged bool              `json:"include_unchanged,omitempty"`
+	Limit     *uint64           `json:"limit,omitempty"`
+}
+
+func (r *CompareWindowsRequest) wants(field reader.TopField) bool {
+	return len(r.Fields) == 0 || slices.Contains(r.Fields, string(field))
+}
+
+// classify fills in the ratio of rates, the effect size and the kind of
+// change. The effect is the z-score of the target count under the binomial
+// model where, without any change, every log falls into a window with
+// probability proportional to the window length.
+func classify(c *model.WindowChange, baseline, target time.Duration, min_effect float64) {
+	p := target.Seconds() / (baseline.Seconds() + target.Seconds())
+	n := float64(c.Baseline + c.Target)
+	c.Effect = (float64(c.Target) - n*p) / math.Sqrt(n*p*(1-p))
+
+	if c.Baseline != 0 {
+		ratio := (float64(c.Target) / target.Seconds()) /
+			(float64(c.Baseline) / baseline.Seconds())
+		c.Ratio = &ratio
+	}
+
+	switch {
+	case c.Baseline == 0:
+		c.Change = model.ChangeAppeared
+	case c.Target == 0:
+		c.Change = model.ChangeDisappeared
+	case c.Effect >= min_effect:
+		c.Change = model.ChangeIncreased
+	case c.Effect <= -min_effect:
+		c.Change = model.ChangeDecreased
+	default:
+		c.Change = model.ChangeUnchanged
+	}
+}
+
+type CompareWindowsUsecase struct {
+	Tx        *sql.Tx
+	LogReader *reader.LogReader
+}
+
+func (u *CompareWindowsUsecase) compare(
+	field reader.TopField,
+	filter reader.LogFilter,
+	result *model.WindowComparison,
+	data *CompareWindowsRequest,
+	limit uint64,
+) ([]model.WindowChange, error) {
+	counts, err := u.LogReader.ReadWindowCounts(
+		field, filter, result.Baseline, result.Target, compareCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	min_effect := float64(defaultCompareMinEffect)
+	if data.MinEffect != nil {
+		min_effect = *data.MinEffect
+	}
+	baseline := result.Baseline.To.Sub(result.Baseline.From)
+	target := result.Target.To.Sub(result.Target.From)
+
+	ret := make([]model.WindowChange, 0, len(counts))
+	for _, c := range counts {
+		classify(&c, baseline, target, min_effect)
+		if c.Change == model.ChangeUnchanged && !data.Unchanged {
+			continue
+		}
+		ret = append(ret, c)
+	}
+	slices.SortStableFunc(ret, func(a, b model.WindowChange) int {
+		return cmp.Compare(math.Abs(b.Effect), math.Abs(a.Effect))
+	})
+	if uint64(len(ret)) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+func (u *CompareWindowsUsecase) Run(data CompareWindowsRequest) ([]byte, error) {
+	loc, err := data.Location()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	now := time.Now()
+
+	var result model.WindowComparison
+	result.Baseline, err = data.Baseline.resolve("baseline", now, loc)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	result.Target, err = data.Target.resolve("target", now, loc)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	filter, err := data.filter(now, loc)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	limit := uint64(defaultCompareLimit)
+	if data.Limit != nil && *data.Limit != 0 {
+		limit = min(*data.Limit, maxCompareLimit)
+	}
+
+	targets := []struct {
+		field reader.TopField
+		dst   *[]model.WindowChange
+	}{
+		{reader.TopSource, &result.Sources},
+		{reader.TopLogLevel, &result.Levels},
+		{reader.TopMessage, &result.Patterns},
+	}
+	for _, t := range targets {
+		if !data.wants(t.field) {
+			continue
+		}
+		changes, err := u.compare(t.field, filter, &result, &data, limit)
+		if err != nil {
+			u.Tx.Rollback()
+			slog.Error("oops... compare", "Err", err)
+			return nil, err
+		}
+		*t.dst = changes
+	}
+
+	if data.Tz != nil {
+		result.Baseline = model.TimeWindow{From: result.Baseline.From.In(loc), To: result.Baseline.To.In(loc)}
+		result.Target = model.TimeWindow{From: result.Target.From.In(loc), To: result.Target.To.In(loc)}
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
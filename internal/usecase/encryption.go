@@ -0,0 +1,252 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"log_shelter/internal/infra/envelope"
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
+)
+
+const defaultRotationBatch = 1000
+
+// Encryption encrypts the raw_log and attributes of the logs of Sources as
+// they are appended. Sources accept "*" patterns.
+type Encryption struct {
+	Sources       []string
+	Keys          *envelope.Keyring
+	DataKeyReader *reader.DataKeyReader
+	DataKeyRepo   *repository.DataKeyRepository
+}
+
+func (e *Encryption) covers(source string) bool {
+	return matchAnyPattern(e.Sources, source)
+}
+
+func (e *Encryption) dataKey(key *model.DataKey) ([]byte, error) {
+	return e.Keys.DataKey(key.ID, func() ([]byte, string, error) {
+		return key.Wrapped, key.MasterKeyID, nil
+	})
+}
+
+// activeKey returns the data key of source, which is created on first use.
+func (e *Encryption) activeKey(source string) (uint64, []byte, error) {
+	key, err := e.DataKeyReader.ReadActiveKey(source)
+	if err != nil {
+		return 0, nil, err
+	}
+	if key == nil {
+		_, wrapped, err := e.Keys.NewDataKey()
+		if err != nil {
+			return 0, nil, err
+		}
+		err = e.DataKeyRepo.CreateDataKey(source, wrapped, e.Keys.MasterKeyID())
+		if err != nil {
+			return 0, nil, err
+		}
+		key, err = e.DataKeyReader.ReadActiveKey(source)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	data, err := e.dataKey(key)
+	return key.ID, data, err
+}
+
+// seal encrypts the payload of a log of source created at created_at with
+// the active data key of source.
+func (e *Encryption) seal(
+	source string,
+	created_at time.Time,
+	raw_log string,
+	attributes map[string]any,
+) (*model.Sealed, error) {
+	id, key, err := e.activeKey(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes_json []byte
+	if len(attributes) != 0 {
+		attributes_json, err = json.Marshal(attributes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret, err := sealWith(key, source, created_at, []byte(raw_log), attributes_json)
+	if err != nil {
+		return nil, err
+	}
+	ret.DataKeyID = id
+	return ret, nil
+}
+
+func sealWith(
+	key []byte,
+	source string,
+	created_at time.Time,
+	raw_log []byte,
+	attributes []byte,
+) (*model.Sealed, error) {
+	var ret model.Sealed
+	var err error
+	ret.RawLog, err = envelope.Seal(key, raw_log,
+		envelope.AAD(source, envelope.FieldRawLog, created_at))
+	if err != nil {
+		return nil, err
+	}
+	if attributes != nil {
+		ret.Attributes, err = envelope.Seal(key, attributes,
+			envelope.AAD(source, envelope.FieldAttributes, created_at))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &ret, nil
+}
+
+// reseal decrypts entry with key and encrypts it again with the active
+// data key of its source.
+func (e *Encryption) reseal(key []byte, entry model.SealedLog) (*model.Sealed, error) {
+	raw_log, err := envelope.Open(key, entry.Sealed.RawLog,
+		envelope.AAD(entry.Source, envelope.FieldRawLog, entry.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+	var attributes []byte
+	if entry.Sealed.Attributes != nil {
+		attributes, err = envelope.Open(key, entry.Sealed.Attributes,
+			envelope.AAD(entry.Source, envelope.FieldAttributes, entry.CreatedAt))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	id, active, err := e.activeKey(entry.Source)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := sealWith(active, entry.Source, entry.CreatedAt, raw_log, attributes)
+	if err != nil {
+		return nil, err
+	}
+	ret.DataKeyID = id
+	return ret, nil
+}
+
+// RotateKeysUsecase rewraps data keys wrapped by an older master key with
+// the current one, retires data keys older than MaxAge, re-encrypts up to
+// BatchSize logs off retired keys with the active key of their source and
+// drops the retired keys no log uses anymore, archived and rehydrated ones
+// included.
+type RotateKeysUsecase struct {
+	Tx         *sql.Tx
+	Encryption *Encryption
+	LogRepo    *repository.LogRepository
+	MaxAge     time.Duration
+	BatchSize  uint64
+}
+
+func (u *RotateKeysUsecase) fail(what string, err error) (*model.KeyRotation, error) {
+	u.Tx.Rollback()
+	slog.Error("oops... "+what, "Err", err)
+	return nil, err
+}
+
+func (u *RotateKeysUsecase) Run(now time.Time) (*model.KeyRotation, error) {
+	e := u.Encryption
+	ret := &model.KeyRotation{}
+
+	keys, err := e.DataKeyReader.ReadKeys()
+	if err != nil {
+		return u.fail("read", err)
+	}
+
+	current := e.Keys.MasterKeyID()
+	for _, key := range keys {
+		if key.MasterKeyID == current {
+			continue
+		}
+		wrapped, err := e.Keys.Rewrap(key.Wrapped, key.MasterKeyID)
+		if err != nil {
+			return u.fail("rewrap", err)
+		}
+		err = e.DataKeyRepo.RewrapKey(key.ID, wrapped, current)
+		if err != nil {
+			return u.fail("rewrap", err)
+		}
+		ret.Rewrapped++
+	}
+
+	if u.MaxAge != 0 {
+		for _, key := range keys {
+			if key.RetiredAt != nil || !key.CreatedAt.Before(now.Add(-u.MaxAge)) {
+				continue
+			}
+			err = e.DataKeyRepo.RetireKey(key.ID, now)
+			if err != nil {
+				return u.fail("retire", err)
+			}
+			ret.Retired++
+		}
+	}
+
+	batch := u.BatchSize
+	if batch == 0 {
+		batch = defaultRotationBatch
+	}
+	// Keys retired by this run wait for the next one, so appends that
+	// picked them before the retirement committed can't lose their key.
+	for _, key := range keys {
+		if key.RetiredAt == nil || uint64(ret.Reencrypted) >= batch {
+			continue
+		}
+		limit := batch - uint64(ret.Reencrypted)
+		logs, err := e.DataKeyReader.ReadSealed(key.ID, limit)
+		if err != nil {
+			return u.fail("read", err)
+		}
+		if len(logs) != 0 {
+			data, err := e.dataKey(&key)
+			if err != nil {
+				return u.fail("unwrap", err)
+			}
+			for _, entry := range logs {
+				sealed, err := e.reseal(data, entry)
+				if err != nil {
+					return u.fail("reseal", err)
+				}
+				err = u.LogRepo.ResealLog(entry.ID, entry.CreatedAt, *sealed)
+				if err != nil {
+					return u.fail("reseal", err)
+				}
+				ret.Reencrypted++
+			}
+		}
+		if uint64(len(logs)) < limit {
+			archived, err := e.DataKeyReader.IsKeyArchived(key.ID)
+			if err != nil {
+				return u.fail("read", err)
+			}
+			if archived {
+				continue
+			}
+			err = e.DataKeyRepo.DeleteKey(key.ID)
+			if err != nil {
+				return u.fail("drop key", err)
+			}
+			ret.Dropped++
+		}
+	}
+
+	err = u.Tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"log_shelter/internal/infra/export"
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+const defaultExportBatch = 1000
+
+type ExportLogsRequest struct {
+	GetLogRequest
+	Format string `json:"format"`
+	Gzip   bool   `json:"gzip,omitempty"`
+}
+
+func (r *ExportLogsRequest) ExportFormat() (export.Format, error) {
+	switch f := export.Format(r.Format); f {
+	case "":
+		return export.FormatNDJSON, nil
+	case export.FormatNDJSON, export.FormatCSV, export.FormatParquet:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w: unknown export format %q", model.ErrInvalidRequest, r.Format)
+	}
+}
+
+type ExportLogsUsecase struct {
+	Tx        *sql.Tx
+	LogReader *reader.LogReader
+	BatchSize uint64
+}
+
+// Run streams every log matching the request into w. Page and page size
+// of the embedded GetLogRequest are ignored.
+func (u *ExportLogsUsecase) Run(data ExportLogsRequest, w io.Writer) error {
+	format, err := data.ExportFormat()
+	if err != nil {
+		u.Tx.Rollback()
+		return err
+	}
+
+	loc, err := data.Location()
+	if err != nil {
+		u.Tx.Rollback()
+		return err
+	}
+	filter, err := data.filter(time.Now(), loc)
+	if err != nil {
+		u.Tx.Rollback()
+		return err
+	}
+	filter.Rehydrated = data.Rehydrated
+
+	enc, err := export.NewEncoder(w, format, data.Gzip)
+	if err != nil {
+		u.Tx.Rollback()
+		return err
+	}
+
+	batch := u.BatchSize
+	if batch == 0 {
+		batch = defaultExportBatch
+	}
+
+	write := enc.Write
+	if data.Tz != nil {
+		write = func(entry *model.LogModel) error {
+			entry.CreatedAt = entry.CreatedAt.In(loc)
+			return enc.Write(entry)
+		}
+	}
+
+	err = u.LogReader.StreamLogs(filter, reader.OrderT(data.Order), batch, write)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... export", "Err", err)
+		return err
+	}
+	err = enc.Close()
+	if err != nil {
+		u.Tx.Rollback()
+		return err
+	}
+	return u.Tx.Commit()
+}
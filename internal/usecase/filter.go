@@ -0,0 +1,148 @@
+package usecase
+
+import (
+	"fmt"
+	"time"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+type LogFilterRequest struct {
+	Sources    []string  `json:"sources,omitempty"`
+	Levels     []string  `json:"levels,omitempty"`
+	Before     *TimeExpr `json:"before,omitempty"`
+	After      *TimeExpr `json:"after,omitempty"`
+	Tz         *string   `json:"tz,omitempty"`
+	RequestID  *string   `json:"request_id,omitempty"`
+	LoggerName *string   `json:"logger_name,omitempty"`
+	MinLevel   *string   `json:"min_level,omitempty"`
+	MaxLevel   *string   `json:"max_level,omitempty"`
+
+	ExcludeSources     []string `json:"exclude_sources,omitempty"`
+	ExcludeLevels      []string `json:"exclude_levels,omitempty"`
+	ExcludeLoggerNames []string `json:"exclude_logger_names,omitempty"`
+
+	RawLogContains     *string `json:"raw_log_contains,omitempty"`
+	RawLogRegex        *string `json:"raw_log_regex,omitempty"`
+	LoggerNameContains *string `json:"logger_name_contains,omitempty"`
+	LoggerNameRegex    *string `json:"logger_name_regex,omitempty"`
+
+	AnyOf []LogFilterRequest `json:"any_of,omitempty"`
+
+	IncludeDeleted bool `json:"include_deleted,omitempty"`
+	OnlyDeleted    bool `json:"only_deleted,omitempty"`
+
+	AllowUnbounded bool `json:"allow_unbounded,omitempty"`
+}
+
+func parseLevel(name string, raw *string) (*model.Level, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	l, ok := model.ParseLevel(*raw)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown %s %q", model.ErrInvalidRequest, name, *raw)
+	}
+	return &l, nil
+}
+
+func resolveTime(t *TimeExpr, now time.Time, loc *time.Location) (*time.Time, error) {
+	if t == nil {
+		return nil, nil
+	}
+	ret, err := t.Resolve(now, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Location returns the time zone of the request, UTC by default.
+func (r *LogFilterRequest) Location() (*time.Location, error) {
+	return location(r.Tz)
+}
+
+// Filter resolves the request into a reader filter, evaluating relative
+// times against the current time.
+func (r *LogFilterRequest) Filter() (reader.LogFilter, error) {
+	loc, err := r.Location()
+	if err != nil {
+		return reader.LogFilter{}, err
+	}
+	return r.filter(time.Now(), loc)
+}
+
+func (r *LogFilterRequest) filter(now time.Time, loc *time.Location) (reader.LogFilter, error) {
+	if r.Tz != nil {
+		var err error
+		loc, err = r.Location()
+		if err != nil {
+			return reader.LogFilter{}, err
+		}
+	}
+	before, err := resolveTime(r.Before, now, loc)
+	if err != nil {
+		return reader.LogFilter{}, err
+	}
+	after, err := resolveTime(r.After, now, loc)
+	if err != nil {
+		return reader.LogFilter{}, err
+	}
+
+	min_level, err := parseLevel("min_level", r.MinLevel)
+	if err != nil {
+		return reader.LogFilter{}, err
+	}
+	max_level, err := parseLevel("max_level", r.MaxLevel)
+	if err != nil {
+		return reader.LogFilter{}, err
+	}
+
+	ret := reader.LogFilter{
+		Sources:    r.Sources,
+		Levels:     model.NormalizeLevels(r.Levels),
+		Before:     before,
+		After:      after,
+		RequestID:  r.RequestID,
+		LoggerName: r.LoggerName,
+		MinLevel:   min_level,
+		MaxLevel:   max_level,
+
+		ExcludeSources:     r.ExcludeSources,
+		ExcludeLevels:      model.NormalizeLevels(r.ExcludeLevels),
+		ExcludeLoggerNames: r.ExcludeLoggerNames,
+
+		RawLogContains:     r.RawLogContains,
+		RawLogRegex:        r.RawLogRegex,
+		LoggerNameContains: r.LoggerNameContains,
+		LoggerNameRegex:    r.LoggerNameRegex,
+
+		AllowUnbounded: r.AllowUnbounded,
+	}
+	switch {
+	case r.IncludeDeleted && r.OnlyDeleted:
+		return reader.LogFilter{}, fmt.Errorf(
+			"%w: include_deleted and only_deleted are mutually exclusive",
+			model.ErrInvalidRequest)
+	case r.OnlyDeleted:
+		ret.Deleted = reader.DeletedOnly
+	case r.IncludeDeleted:
+		ret.Deleted = reader.DeletedInclude
+	}
+	for i := range r.AnyOf {
+		// Deleted logs and unbounded reads are decided for the whole
+		// filter, a group can't widen them.
+		if r.AnyOf[i].IncludeDeleted || r.AnyOf[i].OnlyDeleted || r.AnyOf[i].AllowUnbounded {
+			return reader.LogFilter{}, fmt.Errorf(
+				"%w: include_deleted, only_deleted and allow_unbounded can't be set within any_of",
+				model.ErrInvalidRequest)
+		}
+		group, err := r.AnyOf[i].filter(now, loc)
+		if err != nil {
+			return reader.LogFilter{}, err
+		}
+		ret.AnyOf = append(ret.AnyOf, group)
+	}
+	return ret, nil
+}
@@ -0,0 +1,41 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+
+	"log_shelter/internal/model"
+)
+
+func TestLogFilterRequestRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		request LogFilterRequest
+	}{
+		{
+			name:    "include and only deleted",
+			request: LogFilterRequest{IncludeDeleted: true, OnlyDeleted: true},
+		},
+		{
+			name:    "include_deleted in any_of",
+			request: LogFilterRequest{AnyOf: []LogFilterRequest{{IncludeDeleted: true}}},
+		},
+		{
+			name:    "only_deleted in any_of",
+			request: LogFilterRequest{AnyOf: []LogFilterRequest{{OnlyDeleted: true}}},
+		},
+		{
+			name:    "allow_unbounded in any_of",
+			request: LogFilterRequest{AnyOf: []LogFilterRequest{{AllowUnbounded: true}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.request.Filter()
+			if !errors.Is(err, model.ErrInvalidRequest) {
+				t.Fatalf("got %v, want %v", err, model.ErrInvalidRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultContextLines = 10
+	maxContextLines     = 1000
+)
+
+type GetContextRequest struct {
+	ID     uint64  `json:"id"`
+	Before *uint64 `json:"before,omitempty"`
+	After  *uint64 `json:"after,omitempty"`
+	Scope  string  `json:"scope,omitempty"`
+	Tz     *string `json:"tz,omitempty"`
+}
+
+func contextLines(n *uint64) uint64 {
+	if n == nil {
+		return defaultContextLines
+	}
+	return min(*n, maxContextLines)
+}
+
+type GetContextUsecase struct {
+	Tx        *sql.Tx
+	LogReader *reader.LogReader
+}
+
+func (u *GetContextUsecase) Run(data GetContextRequest) ([]byte, error) {
+	scope := reader.ContextScope(data.Scope)
+	switch scope {
+	case "":
+		scope = reader.ContextScopeSource
+	case reader.ContextScopeSource, reader.ContextScopeLoggerName:
+	default:
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("unknown context scope %q", data.Scope)
+	}
+
+	loc, err := location(data.Tz)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	anchor, err := u.LogReader.ReadLog(data.ID)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+
+	before, after, err := u.LogReader.ReadContext(
+		*anchor,
+		contextLines(data.Before),
+		contextLines(data.After),
+		scope,
+	)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+
+	if data.Tz != nil {
+		localize(before, loc)
+		localize(after, loc)
+		anchor.CreatedAt = anchor.CreatedAt.In(loc)
+	}
+
+	bytes, err := json.Marshal(model.LogContext{
+		Before: before,
+		Entry:  *anchor,
+		After:  after,
+	})
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
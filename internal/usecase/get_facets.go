@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"slices"
+
+	"log_shelter/internal/infra/cache"
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+const defaultFacetLimit = 20
+
+type GetFacetsRequest struct {
+	LogFilterRequest
+	Fields []string `json:"fields,omitempty"`
+	Prefix *string  `json:"prefix,omitempty"`
+	Limit  *uint64  `json:"limit,omitempty"`
+}
+
+func (r *GetFacetsRequest) wants(field reader.FacetField) bool {
+	return len(r.Fields) == 0 || slices.Contains(r.Fields, string(field))
+}
+
+type GetFacetsUsecase struct {
+	Tx        *sql.Tx
+	LogReader *reader.LogReader
+	Cache     *cache.MemoryCache[[]byte]
+}
+
+func (u *GetFacetsUsecase) Run(data GetFacetsRequest) ([]byte, error) {
+	key, err := json.Marshal(data)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	if cached, ok := u.Cache.Get(string(key)); ok {
+		u.Tx.Rollback()
+		return cached, nil
+	}
+
+	filter, err := data.Filter()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	limit := uint64(defaultFacetLimit)
+	if data.Limit != nil {
+		limit = *data.Limit
+	}
+
+	var result model.Facets
+	targets := []struct {
+		field reader.FacetField
+		dst   *[]model.FacetValue
+	}{
+		{reader.FacetSource, &result.Sources},
+		{reader.FacetLogLevel, &result.LogLevels},
+		{reader.FacetLoggerName, &result.LoggerNames},
+		{reader.FacetAttributeKey, &result.AttributeKeys},
+	}
+	for _, t := range targets {
+		if !data.wants(t.field) {
+			continue
+		}
+		values, err := u.LogReader.ReadFacet(t.field, filter, data.Prefix, limit)
+		if err != nil {
+			u.Tx.Rollback()
+			slog.Error("oops... facets", "Err", err)
+			return nil, err
+		}
+		*t.dst = values
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	u.Cache.Set(string(key), bytes)
+	return bytes, nil
+}
@@ -10,35 +10,48 @@ import (
 )
 
 type GetLogRequest struct {
-	Page       uint64     `json:"page"`
-	PageSize   *uint64    `json:"page_size,omitempty"`
-	Sources    []string   `json:"sources,omitempty"`
-	Levels     []string   `json:"levels,omitempty"`
-	Before     *time.Time `json:"before,omitempty"`
-	After      *time.Time `json:"after,omitempty"`
-	RequestID  *string    `json:"request_id,omitempty"`
-	LoggerName *string    `json:"logger_name,omitempty"`
-	Order      string     `json:"order"`
+	LogFilterRequest
+	Page     uint64  `json:"page"`
+	PageSize *uint64 `json:"page_size,omitempty"`
+	Order    string  `json:"order"`
+	// Rehydrated searches the logs loaded back from the archive.
+	Rehydrated bool `json:"rehydrated,omitempty"`
 }
 
 type GetLogUsecase struct {
 	Tx        *sql.Tx
-	LogReader *reader.LogReader
+	LogReader LogSearcher
 }
 
 func (u *GetLogUsecase) Run(data GetLogRequest) ([]byte, error) {
+	loc, err := data.Location()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	filter, err := data.filter(time.Now(), loc)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	filter.Rehydrated = data.Rehydrated
+	// get keeps its original contract: sources and levels have to be
+	// given, "*" matching every value, and an empty list matches no log.
+	if len(data.Sources) == 0 || len(data.Levels) == 0 {
+		u.Tx.Commit()
+		return []byte("[]"), nil
+	}
 	result, err := u.LogReader.ReadLogs(data.Page,
 		data.PageSize,
-		data.Sources,
-		data.Levels,
-		data.Before,
-		data.After,
-		data.RequestID,
-		data.LoggerName,
+		filter,
 		reader.OrderT(data.Order))
 	if err != nil {
 		u.Tx.Rollback()
 		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+	if data.Tz != nil {
+		localize(result, loc)
 	}
 	bytes, err := json.Marshal(result)
 	if err != nil {
@@ -0,0 +1,143 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultSimilarThreshold = 0.5
+	defaultSimilarLimit     = 50
+	maxSimilarLimit         = 1000
+)
+
+// GetSimilarRequest looks for logs similar to the log with ID. The filter
+// narrows down where to look and defaults to all sources.
+type GetSimilarRequest struct {
+	LogFilterRequest
+	ID        uint64   `json:"id"`
+	Method    string   `json:"method,omitempty"`
+	Threshold *float64 `json:"threshold,omitempty"`
+	Limit     *uint64  `json:"limit,omitempty"`
+}
+
+func (r *GetSimilarRequest) method() (reader.SimilarMethod, error) {
+	switch m := reader.SimilarMethod(r.Method); m {
+	case "":
+		return reader.SimilarTrigram, nil
+	case reader.SimilarTrigram, reader.SimilarFingerprint:
+		return m, nil
+	default:
+		return "", fmt.Errorf("%w: unknown similarity method %q", model.ErrInvalidRequest, r.Method)
+	}
+}
+
+func (r *GetSimilarRequest) threshold() (float64, error) {
+	if r.Threshold == nil {
+		return defaultSimilarThreshold, nil
+	}
+	if *r.Threshold <= 0 || *r.Threshold > 1 {
+		return 0, fmt.Errorf("%w: threshold must be in (0, 1]", model.ErrInvalidRequest)
+	}
+	return *r.Threshold, nil
+}
+
+// similarSources groups matches by source, keeping the order of the first
+// match of every source.
+func similarSources(matches []model.SimilarLog) []model.SimilarSource {
+	ret := make([]model.SimilarSource, 0)
+	index := map[string]int{}
+	for _, m := range matches {
+		i, ok := index[m.Source]
+		if !ok {
+			i = len(ret)
+			index[m.Source] = i
+			ret = append(ret, model.SimilarSource{
+				Source:    m.Source,
+				FirstSeen: m.CreatedAt,
+				LastSeen:  m.CreatedAt,
+			})
+		}
+		ret[i].Count++
+		if m.CreatedAt.Before(ret[i].FirstSeen) {
+			ret[i].FirstSeen = m.CreatedAt
+		}
+		if m.CreatedAt.After(ret[i].LastSeen) {
+			ret[i].LastSeen = m.CreatedAt
+		}
+	}
+	return ret
+}
+
+type GetSimilarUsecase struct {
+	Tx        *sql.Tx
+	LogReader *reader.LogReader
+}
+
+func (u *GetSimilarUsecase) Run(data GetSimilarRequest) ([]byte, error) {
+	method, err := data.method()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	threshold, err := data.threshold()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	limit := uint64(defaultSimilarLimit)
+	if data.Limit != nil && *data.Limit != 0 {
+		limit = min(*data.Limit, maxSimilarLimit)
+	}
+
+	loc, err := data.Location()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	filter, err := data.filter(time.Now(), loc)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	anchor, err := u.LogReader.ReadLog(data.ID)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+
+	matches, err := u.LogReader.ReadSimilar(*anchor, filter, method, threshold, limit)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... similar", "Err", err)
+		return nil, err
+	}
+
+	if data.Tz != nil {
+		anchor.CreatedAt = anchor.CreatedAt.In(loc)
+		for i := range matches {
+			matches[i].CreatedAt = matches[i].CreatedAt.In(loc)
+		}
+	}
+
+	bytes, err := json.Marshal(model.SimilarLogs{
+		Anchor:  *anchor,
+		Matches: matches,
+		Sources: similarSources(matches),
+	})
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
@@ -7,34 +7,115 @@ import (
 	"time"
 
 	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultTimelineMinLevel = model.LevelWarn
+	defaultTimelineWindow   = time.Second
+	defaultTimelineLimit    = 1000
+	maxTimelineLimit        = 10000
 )
 
 type GetTimelineRequest struct {
-	ID     uint64         `json:"id"`
-	Before *time.Duration `json:"before,omitempty"`
-	After  *time.Duration `json:"after,omitempty"`
+	ID          uint64    `json:"id"`
+	Before      *Duration `json:"before,omitempty"`
+	After       *Duration `json:"after,omitempty"`
+	Levels      []string  `json:"levels,omitempty"`
+	MinLevel    *string   `json:"min_level,omitempty"`
+	CorrelateBy []string  `json:"correlate_by,omitempty"`
+	// CorrelationWindow bounds the correlate_by matches around the anchor,
+	// before and after when unset.
+	CorrelationWindow *Duration `json:"correlation_window,omitempty"`
+	CrossSource       bool      `json:"cross_source,omitempty"`
+	Limit             uint64    `json:"limit,omitempty"`
+	Tz                *string   `json:"tz,omitempty"`
+	// Rehydrated anchors the timeline on a rehydrated log and builds it
+	// out of the other rehydrated ones.
+	Rehydrated bool `json:"rehydrated,omitempty"`
+}
+
+func (r *GetTimelineRequest) query() (reader.TimelineQuery, error) {
+	ret := reader.TimelineQuery{
+		Levels:      model.NormalizeLevels(r.Levels),
+		MinLevel:    defaultTimelineMinLevel,
+		CorrelateBy: r.CorrelateBy,
+		Before:      defaultTimelineWindow,
+		After:       defaultTimelineWindow,
+		CrossSource: r.CrossSource,
+		Limit:       defaultTimelineLimit,
+		Rehydrated:  r.Rehydrated,
+	}
+	min_level, err := parseLevel("min_level", r.MinLevel)
+	if err != nil {
+		return ret, err
+	}
+	if min_level != nil {
+		ret.MinLevel = *min_level
+	}
+	if len(ret.CorrelateBy) == 0 {
+		ret.CorrelateBy = []string{reader.CorrelateRequestID}
+	}
+	if r.Before != nil {
+		ret.Before = time.Duration(*r.Before)
+	}
+	if r.After != nil {
+		ret.After = time.Duration(*r.After)
+	}
+	if r.CorrelationWindow != nil {
+		ret.CorrelationWindow = time.Duration(*r.CorrelationWindow)
+	}
+	if r.Limit != 0 {
+		ret.Limit = min(r.Limit, maxTimelineLimit)
+	}
+	return ret, nil
 }
 
 type GetTimelineUsecase struct {
 	Tx        *sql.Tx
-	LogReader *reader.LogReader
+	LogReader TimelineReader
 }
 
 func (u *GetTimelineUsecase) Run(data GetTimelineRequest) ([]byte, error) {
-	result, err := u.LogReader.GetTimeLineFor(
-		data.ID,
-		data.Before,
-		data.After,
-	)
+	query, err := data.query()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	loc, err := location(data.Tz)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	read := u.LogReader.ReadLog
+	if data.Rehydrated {
+		read = u.LogReader.ReadRehydratedLog
+	}
+	anchor, err := read(data.ID)
 	if err != nil {
 		u.Tx.Rollback()
 		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+
+	result, err := u.LogReader.GetTimeLineFor(*anchor, query)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+	if data.Tz != nil {
+		for i := range result.Entries {
+			result.Entries[i].CreatedAt = result.Entries[i].CreatedAt.In(loc)
+		}
 	}
 	bytes, err := json.Marshal(result)
 	if err != nil {
 		u.Tx.Rollback()
 		slog.Error("oops... to json", "Err", err)
+		return nil, err
 	}
 	u.Tx.Commit()
-	return bytes, err
+	return bytes, nil
 }
@@ -0,0 +1,66 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultTopLimit = 10
+	maxTopLimit     = 1000
+)
+
+type GetTopRequest struct {
+	LogFilterRequest
+	Field string  `json:"field"`
+	Rank  string  `json:"rank,omitempty"`
+	Limit *uint64 `json:"limit,omitempty"`
+}
+
+type GetTopUsecase struct {
+	Tx        *sql.Tx
+	LogReader *reader.LogReader
+}
+
+func (u *GetTopUsecase) Run(data GetTopRequest) ([]byte, error) {
+	rank := reader.TopRank(data.Rank)
+	switch rank {
+	case "":
+		rank = reader.TopRankCount
+	case reader.TopRankCount, reader.TopRankDelta:
+	default:
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: unknown rank %q", model.ErrInvalidRequest, data.Rank)
+	}
+
+	limit := uint64(defaultTopLimit)
+	if data.Limit != nil && *data.Limit != 0 {
+		limit = min(*data.Limit, maxTopLimit)
+	}
+
+	filter, err := data.Filter()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	result, err := u.LogReader.ReadTop(reader.TopField(data.Field), filter, rank, limit)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... top", "Err", err)
+		return nil, err
+	}
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
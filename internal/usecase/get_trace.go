@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+var ErrTraceIDRequired = errors.New("trace_id or request_id is required")
+
+type GetTraceRequest struct {
+	TraceID   *string `json:"trace_id,omitempty"`
+	RequestID *string `json:"request_id,omitempty"`
+}
+
+type GetTraceUsecase struct {
+	Tx        *sql.Tx
+	LogReader *reader.LogReader
+}
+
+func durationMs(from time.Time, to time.Time) float64 {
+	return float64(to.Sub(from)) / float64(time.Millisecond)
+}
+
+// buildTrace groups time-ordered logs into a span tree. Spans whose parent
+// is not part of the result become roots; logs without span_id are
+// returned separately.
+func buildTrace(
+	logs []model.LogModel,
+) ([]*model.TraceSpan, []model.TraceService, []model.LogModel) {
+	spans := make(map[string]*model.TraceSpan)
+	order := make([]*model.TraceSpan, 0)
+	services := make(map[string]*model.TraceService)
+	service_order := make([]string, 0)
+	unspanned := make([]model.LogModel, 0)
+
+	for _, l := range logs {
+		svc, ok := services[l.Source]
+		if !ok {
+			svc = &model.TraceService{Source: l.Source, FirstAt: l.CreatedAt}
+			services[l.Source] = svc
+			service_order = append(service_order, l.Source)
+		}
+		svc.LastAt = l.CreatedAt
+		svc.Count += 1
+
+		if l.SpanID == nil {
+			unspanned = append(unspanned, l)
+			continue
+		}
+
+		span, ok := spans[*l.SpanID]
+		if !ok {
+			span = &model.TraceSpan{
+				SpanID:   *l.SpanID,
+				FirstAt:  l.CreatedAt,
+				Sources:  make([]string, 0, 1),
+				Logs:     make([]model.LogModel, 0),
+				Children: make([]*model.TraceSpan, 0),
+			}
+			spans[*l.SpanID] = span
+			order = append(order, span)
+		}
+		if span.ParentSpanID == nil && l.ParentSpanID != nil {
+			span.ParentSpanID = l.ParentSpanID
+		}
+		if !slices.Contains(span.Sources, l.Source) {
+			span.Sources = append(span.Sources, l.Source)
+		}
+		span.LastAt = l.CreatedAt
+		span.Logs = append(span.Logs, l)
+	}
+
+	roots := make([]*model.TraceSpan, 0)
+	for _, span := range order {
+		span.DurationMs = durationMs(span.FirstAt, span.LastAt)
+		if span.ParentSpanID != nil {
+			if parent, ok := spans[*span.ParentSpanID]; ok && parent != span {
+				parent.Children = append(parent.Children, span)
+				continue
+			}
+		}
+		roots = append(roots, span)
+	}
+
+	ret_services := make([]model.TraceService, 0, len(service_order))
+	for _, source := range service_order {
+		svc := services[source]
+		svc.DurationMs = durationMs(svc.FirstAt, svc.LastAt)
+		ret_services = append(ret_services, *svc)
+	}
+
+	return roots, ret_services, unspanned
+}
+
+func (u *GetTraceUsecase) Run(data GetTraceRequest) ([]byte, error) {
+	if data.TraceID == nil && data.RequestID == nil {
+		u.Tx.Rollback()
+		return nil, ErrTraceIDRequired
+	}
+
+	logs, err := u.LogReader.ReadTrace(data.TraceID, data.RequestID)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+
+	spans, services, unspanned := buildTrace(logs)
+	bytes, err := json.Marshal(model.Trace{
+		TraceID:   data.TraceID,
+		RequestID: data.RequestID,
+		Spans:     spans,
+		Services:  services,
+		Unspanned: unspanned,
+	})
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
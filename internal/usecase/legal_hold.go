@@ -0,0 +1,182 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultLegalHoldsLimit = 100
+	maxLegalHoldsLimit     = 1000
+)
+
+// CreateLegalHoldRequest holds the logs of a source, a request, a time
+// range or any combination of them.
+type CreateLegalHoldRequest struct {
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"created_by"`
+	ExpiresAt *TimeExpr `json:"expires_at,omitempty"`
+	Source    *string   `json:"source,omitempty"`
+	RequestID *string   `json:"request_id,omitempty"`
+	From      *TimeExpr `json:"from,omitempty"`
+	To        *TimeExpr `json:"to,omitempty"`
+	Tz        *string   `json:"tz,omitempty"`
+}
+
+type ListLegalHoldsRequest struct {
+	// Inactive also lists expired and released holds.
+	Inactive bool   `json:"inactive,omitempty"`
+	Limit    uint64 `json:"limit,omitempty"`
+}
+
+type ReleaseLegalHoldRequest struct {
+	ID         uint64 `json:"id"`
+	ReleasedBy string `json:"released_by"`
+}
+
+type CreateLegalHoldUsecase struct {
+	Tx              *sql.Tx
+	LegalHoldReader *reader.LegalHoldReader
+	LegalHoldRepo   *repository.LegalHoldRepository
+}
+
+func (u *CreateLegalHoldUsecase) Run(data CreateLegalHoldRequest) ([]byte, error) {
+	if data.Reason == "" {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: reason is required", model.ErrInvalidRequest)
+	}
+	if data.CreatedBy == "" {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: created_by is required", model.ErrInvalidRequest)
+	}
+	if data.Source == nil && data.RequestID == nil && data.From == nil && data.To == nil {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: source, request_id, from or to is required",
+			model.ErrInvalidRequest)
+	}
+
+	now := time.Now()
+	loc, err := location(data.Tz)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	var from, to, expires_at *time.Time
+	for _, t := range []struct {
+		name string
+		expr *TimeExpr
+		dst  **time.Time
+	}{
+		{"from", data.From, &from},
+		{"to", data.To, &to},
+		{"expires_at", data.ExpiresAt, &expires_at},
+	} {
+		*t.dst, err = resolveTime(t.expr, now, loc)
+		if err != nil {
+			u.Tx.Rollback()
+			return nil, fmt.Errorf("%s: %w", t.name, err)
+		}
+	}
+	if from != nil && to != nil && !to.After(*from) {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: to is not after from", model.ErrInvalidRequest)
+	}
+	if expires_at != nil && !expires_at.After(now) {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: expires_at is in the past", model.ErrInvalidRequest)
+	}
+
+	id, err := u.LegalHoldRepo.CreateHold(
+		data.Reason,
+		data.CreatedBy,
+		expires_at,
+		data.Source,
+		data.RequestID,
+		from,
+		to,
+	)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... create hold", "Err", err)
+		return nil, err
+	}
+
+	holds, err := u.LegalHoldReader.ReadHolds([]uint64{id}, true, 1)
+	if err == nil && len(holds) == 0 {
+		err = model.ErrLegalHoldNotFound
+	}
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+	bytes, err := json.Marshal(holds[0])
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	return bytes, u.Tx.Commit()
+}
+
+type ListLegalHoldsUsecase struct {
+	Tx              *sql.Tx
+	LegalHoldReader *reader.LegalHoldReader
+}
+
+func (u *ListLegalHoldsUsecase) Run(data ListLegalHoldsRequest) ([]byte, error) {
+	limit := data.Limit
+	if limit == 0 {
+		limit = defaultLegalHoldsLimit
+	}
+	limit = min(limit, maxLegalHoldsLimit)
+
+	holds, err := u.LegalHoldReader.ReadHolds(nil, data.Inactive, limit)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+	protected, err := u.LegalHoldReader.ReadProtected()
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(model.LegalHolds{Holds: holds, Protected: protected})
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
+
+type ReleaseLegalHoldUsecase struct {
+	Tx            *sql.Tx
+	LegalHoldRepo *repository.LegalHoldRepository
+}
+
+func (u *ReleaseLegalHoldUsecase) Run(data ReleaseLegalHoldRequest) error {
+	if data.ReleasedBy == "" {
+		u.Tx.Rollback()
+		return fmt.Errorf("%w: released_by is required", model.ErrInvalidRequest)
+	}
+
+	err := u.LegalHoldRepo.ReleaseHold(data.ID, data.ReleasedBy)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... release hold", "Err", err)
+		return err
+	}
+	return u.Tx.Commit()
+}
@@ -0,0 +1,242 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
+)
+
+const (
+	PartitionDaily  = "daily"
+	PartitionHourly = "hourly"
+)
+
+// PartitionPlan describes the partitions to keep around: Premake future
+// partitions of Interval length, and nothing older than Retention unless
+// Retention is zero.
+type PartitionPlan struct {
+	Interval  string
+	Premake   int
+	Retention time.Duration
+}
+
+func (p *PartitionPlan) step() (time.Duration, string, error) {
+	switch p.Interval {
+	case "", PartitionDaily:
+		return 24 * time.Hour, "20060102", nil
+	case PartitionHourly:
+		return time.Hour, "2006010215", nil
+	default:
+		return 0, "", fmt.Errorf("unknown partition interval %q", p.Interval)
+	}
+}
+
+type GetPartitionsUsecase struct {
+	Tx              *sql.Tx
+	PartitionReader *reader.PartitionReader
+}
+
+func (u *GetPartitionsUsecase) Run() ([]byte, error) {
+	result, err := u.PartitionReader.ReadPartitions()
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
+
+type MaintainPartitionsUsecase struct {
+	Tx              *sql.Tx
+	PartitionReader *reader.PartitionReader
+	PartitionRepo   *repository.PartitionRepository
+	Plan            PartitionPlan
+	// Archive is nil when archiving is disabled, otherwise the logs of a
+	// partition are archived before it is dropped.
+	Archive *Archival
+	// Detach detaches a partition concurrently, outside of any transaction.
+	Detach func(name string) error
+	// Fresh returns the usecase over a new transaction. Partitions are
+	// dropped in transactions of their own, after Tx committed, so no lock
+	// taken by creating or dropping one blocks archiving the next.
+	Fresh func() (*MaintainPartitionsUsecase, error)
+}
+
+// dropPartition archives the logs of p, batch by batch, and drops it.
+// Unless logs has a default partition, which Postgres doesn't detach
+// concurrently around, p is detached concurrently first so the drop
+// doesn't lock logs as a whole.
+func (u *MaintainPartitionsUsecase) dropPartition(p model.Partition, concurrently bool) error {
+	if u.Archive != nil {
+		where := squirrel.And{squirrel.Lt{"created_at": *p.To}}
+		if p.From != nil {
+			where = append(where, squirrel.GtOrEq{"created_at": *p.From})
+		}
+		_, err := u.Archive.Delete(where)
+		if err != nil {
+			return err
+		}
+	}
+
+	if concurrently {
+		err := u.Detach(p.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	next, err := u.Fresh()
+	if err != nil {
+		return err
+	}
+	err = next.PartitionRepo.DropPartition(p.Name)
+	if err != nil {
+		next.Tx.Rollback()
+		return err
+	}
+	return next.Tx.Commit()
+}
+
+// createPartition creates the partition for [from, to). Logs created ahead
+// of time land in the default partition until then, they are moved over.
+// A partition of that name already in status is kept when its bounds match
+// and fails otherwise. Returns whether the partition was created.
+func (u *MaintainPartitionsUsecase) createPartition(
+	changes *model.PartitionChanges,
+	status *model.PartitionStatus,
+	name string,
+	default_name string,
+	from time.Time,
+	to time.Time,
+) (bool, error) {
+	for _, p := range status.Partitions {
+		if p.Name != name {
+			continue
+		}
+		if p.From == nil || p.To == nil || !p.From.Equal(from) || !p.To.Equal(to) {
+			return false, fmt.Errorf("partition %s exists with other bounds than [%s, %s)",
+				name, from.Format(time.RFC3339), to.Format(time.RFC3339))
+		}
+		return false, nil
+	}
+
+	if default_name != "" {
+		early, err := u.PartitionReader.HasRowsIn(default_name, from, to)
+		if err != nil {
+			return false, err
+		}
+		if early {
+			moved, err := u.PartitionRepo.CreatePartitionOver(name, default_name, from, to)
+			changes.Moved += moved
+			return err == nil, err
+		}
+	}
+	err := u.PartitionRepo.CreatePartition(name, from, to)
+	return err == nil, err
+}
+
+// Run creates the missing partitions up to Premake intervals after now and
+// drops the partitions that ended more than Retention before now.
+func (u *MaintainPartitionsUsecase) Run(now time.Time) (*model.PartitionChanges, error) {
+	step, layout, err := u.Plan.step()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	status, err := u.PartitionReader.ReadPartitions()
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+
+	changes := model.PartitionChanges{
+		Created: make([]string, 0),
+		Dropped: make([]string, 0),
+		Held:    make([]string, 0),
+	}
+	if !status.Partitioned {
+		u.Tx.Rollback()
+		return &changes, nil
+	}
+
+	// New partitions start where the existing ones end, so they never
+	// overlap, even after the interval was changed.
+	start := now.UTC().Truncate(step)
+	var default_name string
+	for _, p := range status.Partitions {
+		if p.Default {
+			default_name = p.Name
+		}
+		if p.To != nil && p.To.After(start) {
+			start = *p.To
+		}
+	}
+	end := now.UTC().Truncate(step).Add(time.Duration(u.Plan.Premake+1) * step)
+	for from := start; from.Before(end); {
+		to := from.Truncate(step).Add(step)
+		name := "logs_p" + from.UTC().Format(layout)
+		created, err := u.createPartition(&changes, status, name, default_name, from, to)
+		if err != nil {
+			u.Tx.Rollback()
+			slog.Error("oops... create partition", "Err", err, "partition", name)
+			return nil, err
+		}
+		if created {
+			changes.Created = append(changes.Created, name)
+		}
+		from = to
+	}
+
+	drop := make([]model.Partition, 0)
+	if u.Plan.Retention != 0 {
+		cutoff := now.Add(-u.Plan.Retention)
+		for _, p := range status.Partitions {
+			if p.Default || p.To == nil || p.To.After(cutoff) {
+				continue
+			}
+			held, err := u.PartitionReader.IsPartitionHeld(p.Name)
+			if err != nil {
+				u.Tx.Rollback()
+				slog.Error("oops... read holds", "Err", err, "partition", p.Name)
+				return nil, err
+			}
+			if held {
+				changes.Held = append(changes.Held, p.Name)
+				continue
+			}
+			drop = append(drop, p)
+		}
+	}
+
+	err = u.Tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range drop {
+		err = u.dropPartition(p, default_name == "")
+		if err != nil {
+			slog.Error("oops... drop partition", "Err", err, "partition", p.Name)
+			return nil, err
+		}
+		changes.Dropped = append(changes.Dropped, p.Name)
+	}
+	return &changes, nil
+}
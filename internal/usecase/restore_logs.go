@@ -0,0 +1,160 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultRestoresLimit = 50
+	maxRestoresLimit     = 1000
+
+	defaultRestoreRetention = 30 * 24 * time.Hour
+)
+
+// RestoreLogsRequest restores soft-deleted logs either by ids or by filter.
+// When both are given, rows matching either of them are restored.
+type RestoreLogsRequest struct {
+	IDs        []uint64          `json:"ids,omitempty"`
+	Filter     *LogFilterRequest `json:"filter,omitempty"`
+	RestoredBy string            `json:"restored_by"`
+	Reason     *string           `json:"reason,omitempty"`
+}
+
+type RestoreLogsResponse struct {
+	RestoreID   uint64    `json:"restore_id"`
+	Restored    int       `json:"restored"`
+	LogIDs      []int64   `json:"log_ids"`
+	RetainUntil time.Time `json:"retain_until"`
+}
+
+type ListRestoresRequest struct {
+	RestoredBy *string `json:"restored_by,omitempty"`
+	Limit      uint64  `json:"limit,omitempty"`
+}
+
+type RestoreLogsUsecase struct {
+	Tx        *sql.Tx
+	LogReader *reader.LogReader
+	LogRepo   *repository.LogRepository
+	// RetainFor keeps restored logs from retention, which would expire
+	// them again otherwise.
+	RetainFor time.Duration
+}
+
+func (u *RestoreLogsUsecase) where(data RestoreLogsRequest) (squirrel.Sqlizer, error) {
+	if data.RestoredBy == "" {
+		return nil, fmt.Errorf("%w: restored_by is required", model.ErrInvalidRequest)
+	}
+	if len(data.IDs) == 0 && data.Filter == nil {
+		return nil, fmt.Errorf("%w: ids or filter is required", model.ErrInvalidRequest)
+	}
+
+	ret := squirrel.Or{}
+	if len(data.IDs) != 0 {
+		ret = append(ret, squirrel.Eq{"id": data.IDs})
+	}
+	if data.Filter != nil {
+		filter, err := data.Filter.Filter()
+		if err != nil {
+			return nil, err
+		}
+		filter.Deleted = reader.DeletedOnly
+		err = u.LogReader.CheckFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, filter.Where())
+	}
+	return ret, nil
+}
+
+func (u *RestoreLogsUsecase) Run(data RestoreLogsRequest) ([]byte, error) {
+	where, err := u.where(data)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	retain_for := u.RetainFor
+	if retain_for == 0 {
+		retain_for = defaultRestoreRetention
+	}
+	retain_until := time.Now().UTC().Add(retain_for)
+
+	ids, err := u.LogRepo.RestoreLogs(where, retain_until)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... restore", "Err", err)
+		return nil, err
+	}
+
+	request, err := json.Marshal(data)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	id, err := u.LogRepo.RecordRestore(data.RestoredBy, data.Reason, request, ids)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... record restore", "Err", err)
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(RestoreLogsResponse{
+		RestoreID:   id,
+		Restored:    len(ids),
+		LogIDs:      ids,
+		RetainUntil: retain_until,
+	})
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	err = u.Tx.Commit()
+	if err != nil {
+		slog.Error("oops... commit", "Err", err)
+		return nil, err
+	}
+	slog.Info("Logs restored", "restore_id", id, "restored_by", data.RestoredBy, "count", len(ids))
+	return bytes, nil
+}
+
+type ListRestoresUsecase struct {
+	Tx            *sql.Tx
+	RestoreReader *reader.RestoreReader
+}
+
+func (u *ListRestoresUsecase) Run(data ListRestoresRequest) ([]byte, error) {
+	limit := data.Limit
+	if limit == 0 {
+		limit = defaultRestoresLimit
+	}
+	limit = min(limit, maxRestoresLimit)
+
+	result, err := u.RestoreReader.ReadRestores(data.RestoredBy, limit)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return bytes, nil
+}
@@ -0,0 +1,99 @@
+package usecase
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+// RetentionRule is a compiled retention rule. Match selects its logs
+// regardless of deletion, limits that are zero are not enforced.
+type RetentionRule struct {
+	Name     string
+	Match    reader.LogFilter
+	MaxAge   time.Duration
+	MaxRows  uint64
+	MaxBytes uint64
+	Hard     bool
+}
+
+type ApplyRetentionUsecase struct {
+	Tx      *sql.Tx
+	LogRepo LogExpirer
+	// Rules in priority order, a log belongs to the first rule matching it.
+	Rules []RetentionRule
+	// Archive is nil when archiving is disabled. Otherwise hard rules
+	// archive the logs they delete, soft ones leave it to the purge.
+	Archive *Archival
+}
+
+// scope selects the logs a rule applies to: the ones it matches and no
+// earlier rule does.
+func (u *ApplyRetentionUsecase) scope(i int) reader.RetentionScope {
+	rule := u.Rules[i]
+	ret := reader.RetentionScope{Match: rule.Match, LiveOnly: !rule.Hard}
+	for _, earlier := range u.Rules[:i] {
+		ret.Exclude = append(ret.Exclude, earlier.Match)
+	}
+	return ret
+}
+
+func (u *ApplyRetentionUsecase) fail(rule RetentionRule, limit string, err error) error {
+	u.Tx.Rollback()
+	slog.Error("oops... retention", "Err", err, "rule", rule.Name, "limit", limit)
+	return err
+}
+
+func (u *ApplyRetentionUsecase) expire(
+	rule RetentionRule,
+	scope reader.RetentionScope,
+	limit reader.RetentionLimit,
+) (int64, error) {
+	if rule.Hard && u.Archive != nil {
+		return u.Archive.Delete(reader.RetentionWhere(scope, limit))
+	}
+	return u.LogRepo.ExpireLogs(scope, limit, rule.Hard)
+}
+
+func (u *ApplyRetentionUsecase) Run(now time.Time) ([]model.RetentionResult, error) {
+	ret := make([]model.RetentionResult, 0)
+	for i, rule := range u.Rules {
+		scope := u.scope(i)
+		limits := []struct {
+			enabled bool
+			limit   reader.RetentionLimit
+		}{
+			{rule.MaxAge != 0, reader.RetentionLimit{
+				Kind: model.ExpireMaxAge, Before: now.Add(-rule.MaxAge),
+			}},
+			{rule.MaxRows != 0, reader.RetentionLimit{
+				Kind: model.ExpireMaxRows, Max: rule.MaxRows,
+			}},
+			{rule.MaxBytes != 0, reader.RetentionLimit{
+				Kind: model.ExpireMaxBytes, Max: rule.MaxBytes,
+			}},
+		}
+
+		for _, l := range limits {
+			if !l.enabled {
+				continue
+			}
+			n, err := u.expire(rule, scope, l.limit)
+			if err != nil {
+				return nil, u.fail(rule, l.limit.Kind, err)
+			}
+			ret = append(ret, model.RetentionResult{
+				Rule: rule.Name, Limit: l.limit.Kind, Hard: rule.Hard, Expired: n,
+			})
+		}
+	}
+
+	err := u.Tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,218 @@
+package usecase
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
+)
+
+type SavedSearchRequest struct {
+	Name        string          `json:"name"`
+	Query       json.RawMessage `json:"query"`
+	Owner       *string         `json:"owner,omitempty"`
+	Description *string         `json:"description,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+}
+
+type SavedSearchNameRequest struct {
+	Name string `json:"name"`
+}
+
+type ListSavedSearchesRequest struct {
+	Owner *string  `json:"owner,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type RunSavedSearchRequest struct {
+	Name      string          `json:"name"`
+	Overrides json.RawMessage `json:"overrides,omitempty"`
+}
+
+func decodeGetLogRequest(data []byte) (*GetLogRequest, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var ret GetLogRequest
+	err := dec.Decode(&ret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: query: %v", model.ErrInvalidRequest, err)
+	}
+	return &ret, nil
+}
+
+func (r *SavedSearchRequest) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", model.ErrInvalidRequest)
+	}
+	if len(r.Query) == 0 {
+		return fmt.Errorf("%w: query is required", model.ErrInvalidRequest)
+	}
+	// tags is NOT NULL, and a nil slice binds as NULL.
+	if r.Tags == nil {
+		r.Tags = []string{}
+	}
+	_, err := decodeGetLogRequest(r.Query)
+	return err
+}
+
+// mergeQuery applies overrides on top of the saved query. Both must be JSON
+// objects; keys from overrides replace the saved ones as a whole.
+func mergeQuery(saved []byte, overrides []byte) ([]byte, error) {
+	if len(overrides) == 0 {
+		return saved, nil
+	}
+
+	var base, patch map[string]json.RawMessage
+	err := json.Unmarshal(saved, &base)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(overrides, &patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: overrides: %v", model.ErrInvalidRequest, err)
+	}
+	if base == nil {
+		base = make(map[string]json.RawMessage)
+	}
+	for k, v := range patch {
+		base[k] = v
+	}
+	return json.Marshal(base)
+}
+
+type CreateSavedSearchUsecase struct {
+	Tx              *sql.Tx
+	SavedSearchRepo *repository.SavedSearchRepository
+}
+
+func (u *CreateSavedSearchUsecase) Run(data SavedSearchRequest) ([]byte, error) {
+	err := data.validate()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	id, err := u.SavedSearchRepo.CreateSavedSearch(
+		data.Name,
+		data.Query,
+		data.Owner,
+		data.Description,
+		data.Tags,
+	)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... create saved search", "Err", err)
+		return nil, err
+	}
+	err = u.Tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]uint64{"id": id})
+}
+
+type UpdateSavedSearchUsecase struct {
+	Tx              *sql.Tx
+	SavedSearchRepo *repository.SavedSearchRepository
+}
+
+func (u *UpdateSavedSearchUsecase) Run(data SavedSearchRequest) error {
+	err := data.validate()
+	if err != nil {
+		u.Tx.Rollback()
+		return err
+	}
+
+	err = u.SavedSearchRepo.UpdateSavedSearch(
+		data.Name,
+		data.Query,
+		data.Owner,
+		data.Description,
+		data.Tags,
+	)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... update saved search", "Err", err)
+		return err
+	}
+	return u.Tx.Commit()
+}
+
+type DeleteSavedSearchUsecase struct {
+	Tx              *sql.Tx
+	SavedSearchRepo *repository.SavedSearchRepository
+}
+
+func (u *DeleteSavedSearchUsecase) Run(data SavedSearchNameRequest) error {
+	err := u.SavedSearchRepo.DeleteSavedSearch(data.Name)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... delete saved search", "Err", err)
+		return err
+	}
+	return u.Tx.Commit()
+}
+
+type GetSavedSearchUsecase struct {
+	Tx                *sql.Tx
+	SavedSearchReader *reader.SavedSearchReader
+}
+
+func (u *GetSavedSearchUsecase) Run(data SavedSearchNameRequest) ([]byte, error) {
+	result, err := u.SavedSearchReader.ReadSavedSearch(data.Name)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	u.Tx.Commit()
+	return json.Marshal(result)
+}
+
+type ListSavedSearchesUsecase struct {
+	Tx                *sql.Tx
+	SavedSearchReader *reader.SavedSearchReader
+}
+
+func (u *ListSavedSearchesUsecase) Run(data ListSavedSearchesRequest) ([]byte, error) {
+	result, err := u.SavedSearchReader.ReadSavedSearches(data.Owner, data.Tags)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... read", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return json.Marshal(result)
+}
+
+type RunSavedSearchUsecase struct {
+	Tx                *sql.Tx
+	SavedSearchReader *reader.SavedSearchReader
+	GetLog            *GetLogUsecase
+}
+
+func (u *RunSavedSearchUsecase) Run(data RunSavedSearchRequest) ([]byte, error) {
+	saved, err := u.SavedSearchReader.ReadSavedSearch(data.Name)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	query, err := mergeQuery(saved.Query, data.Overrides)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	request, err := decodeGetLogRequest(query)
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	return u.GetLog.Run(*request)
+}
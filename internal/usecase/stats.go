@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/infra/repository"
+	"log_shelter/internal/model"
+)
+
+const (
+	defaultStatsLimit = 1000
+	maxStatsLimit     = 10000
+)
+
+// GetStatsRequest counts logs from the rollups, grouped by GroupBy fields
+// and, for histograms, by Interval: "minute", "hour" or "day". The filter
+// can only use what rollups keep: sources, levels, logger names and time.
+type GetStatsRequest struct {
+	LogFilterRequest
+	GroupBy  []string `json:"group_by,omitempty"`
+	Interval string   `json:"interval,omitempty"`
+	Limit    uint64   `json:"limit,omitempty"`
+}
+
+// rowLevel names the first filter of r that rollups can't answer.
+func (r *LogFilterRequest) rowLevel() string {
+	switch {
+	case r.RequestID != nil:
+		return "request_id"
+	case r.RawLogContains != nil:
+		return "raw_log_contains"
+	case r.RawLogRegex != nil:
+		return "raw_log_regex"
+	case r.IncludeDeleted:
+		return "include_deleted"
+	case r.OnlyDeleted:
+		return "only_deleted"
+	}
+	for i := range r.AnyOf {
+		if name := r.AnyOf[i].rowLevel(); name != "" {
+			return "any_of." + name
+		}
+	}
+	return ""
+}
+
+// isUTC tells whether loc is UTC, the zone rollup days are bucketed in.
+func isUTC(loc *time.Location) bool {
+	return loc == time.UTC || loc.String() == "UTC" || loc.String() == "Etc/UTC"
+}
+
+func (r *GetStatsRequest) fields() ([]reader.StatsField, error) {
+	ret := make([]reader.StatsField, 0, len(r.GroupBy))
+	for _, name := range r.GroupBy {
+		field := reader.StatsField(name)
+		switch field {
+		case reader.StatsSource, reader.StatsLogLevel, reader.StatsLoggerName:
+		default:
+			return nil, fmt.Errorf("%w: unknown group_by field %q", model.ErrInvalidRequest, name)
+		}
+		ret = append(ret, field)
+	}
+	return ret, nil
+}
+
+type GetStatsUsecase struct {
+	Tx           *sql.Tx
+	RollupReader *reader.RollupReader
+}
+
+func (u *GetStatsUsecase) Run(data GetStatsRequest) ([]byte, error) {
+	if name := data.rowLevel(); name != "" {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: %s needs the logs themselves, stats come from rollups",
+			model.ErrInvalidRequest, name)
+	}
+	switch data.Interval {
+	case "", model.ResolutionMinute, model.ResolutionHour, model.ResolutionDay:
+	default:
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: interval must be minute, hour or day", model.ErrInvalidRequest)
+	}
+	fields, err := data.fields()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	loc, err := data.Location()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+	filter, err := data.Filter()
+	if err != nil {
+		u.Tx.Rollback()
+		return nil, err
+	}
+
+	// Buckets finer than the rollups the range falls on would be empty
+	// but for the first of every rollup bucket.
+	resolution, err := u.RollupReader.ReadResolution(filter)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... stats", "Err", err)
+		return nil, err
+	}
+	if data.Interval != "" &&
+		model.ResolutionStep(data.Interval) < model.ResolutionStep(resolution) {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: interval %s is finer than the %s rollups the time range falls on",
+			model.ErrInvalidRequest, data.Interval, resolution)
+	}
+	if data.Interval != "" && resolution == model.ResolutionDay && !isUTC(loc) {
+		u.Tx.Rollback()
+		return nil, fmt.Errorf("%w: the time range falls on daily rollups, which are bucketed "+
+			"in UTC days, so tz must be UTC", model.ErrInvalidRequest)
+	}
+
+	limit := data.Limit
+	if limit == 0 {
+		limit = defaultStatsLimit
+	}
+	limit = min(limit, maxStatsLimit)
+
+	points, count, bytes, err := u.RollupReader.ReadStats(
+		filter, fields, data.Interval, loc.String(), limit)
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... stats", "Err", err)
+		return nil, err
+	}
+	for i := range points {
+		if points[i].Bucket != nil {
+			bucket := points[i].Bucket.In(loc)
+			points[i].Bucket = &bucket
+		}
+	}
+
+	ret, err := json.Marshal(model.Stats{
+		Interval:   data.Interval,
+		Resolution: resolution,
+		Count:      count,
+		Bytes:      bytes,
+		Points:     points,
+	})
+	if err != nil {
+		u.Tx.Rollback()
+		slog.Error("oops... to json", "Err", err)
+		return nil, err
+	}
+	u.Tx.Commit()
+	return ret, nil
+}
+
+// DownsampleRollupsUsecase folds minute rollups older than HourlyAfter into
+// hours and hour rollups older than DailyAfter into days. A zero age
+// keeps the finer rollups.
+type DownsampleRollupsUsecase struct {
+	Tx          *sql.Tx
+	RollupRepo  *repository.RollupRepository
+	HourlyAfter time.Duration
+	DailyAfter  time.Duration
+}
+
+func (u *DownsampleRollupsUsecase) Run(now time.Time) (*model.Downsampling, error) {
+	now = now.UTC()
+	ret := &model.Downsampling{}
+
+	// Cutoffs fall on bucket boundaries, so only whole hours and days are
+	// folded.
+	if u.HourlyAfter != 0 {
+		before := now.Add(-u.HourlyAfter).Truncate(time.Hour)
+		n, err := u.RollupRepo.Downsample(model.ResolutionMinute, model.ResolutionHour, before)
+		if err != nil {
+			u.Tx.Rollback()
+			slog.Error("oops... downsample", "Err", err)
+			return nil, err
+		}
+		ret.Hourly = n
+	}
+	if u.DailyAfter != 0 {
+		t := now.Add(-u.DailyAfter)
+		before := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// Minutes that weren't folded into hours yet go through hours.
+		n, err := u.RollupRepo.Downsample(model.ResolutionMinute, model.ResolutionHour, before)
+		if err != nil {
+			u.Tx.Rollback()
+			slog.Error("oops... downsample", "Err", err)
+			return nil, err
+		}
+		ret.Hourly += n
+		n, err = u.RollupRepo.Downsample(model.ResolutionHour, model.ResolutionDay, before)
+		if err != nil {
+			u.Tx.Rollback()
+			slog.Error("oops... downsample", "Err", err)
+			return nil, err
+		}
+		ret.Daily = n
+	}
+
+	err := u.Tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"time"
+
+	"log_shelter/internal/infra/reader"
+	"log_shelter/internal/model"
+)
+
+// The interfaces below are what the append, search, timeline and retention
+// usecases need from a storage backend. Postgres implements them with
+// repository.LogRepository and reader.LogReader, SQLite with
+// sqlite.LogStore; the other usecases are Postgres only.
+
+type LogAppender interface {
+	AppendLog(
+		raw_log string,
+		log_level string,
+		raw_level string,
+		level_rank *int16,
+		source string,
+		created_at time.Time,
+		request_id *string,
+		logger_name *string,
+		attributes map[string]any,
+		trace_id *string,
+		span_id *string,
+		parent_span_id *string,
+		chain *model.ChainLink,
+		sealed *model.Sealed,
+	) error
+}
+
+type LogSearcher interface {
+	ReadLogs(
+		page uint64,
+		page_size *uint64,
+		filter reader.LogFilter,
+		order reader.OrderT,
+	) ([]model.LogModel, error)
+}
+
+type TimelineReader interface {
+	ReadLog(id uint64) (*model.LogModel, error)
+	ReadRehydratedLog(id uint64) (*model.LogModel, error)
+	GetTimeLineFor(anchor model.LogModel, query reader.TimelineQuery) (*model.Timeline, error)
+}
+
+type LogExpirer interface {
+	ExpireLogs(scope reader.RetentionScope, limit reader.RetentionLimit, hard bool) (int64, error)
+	PurgeDeleted(grace time.Duration, limit uint64) (int64, error)
+}
@@ -0,0 +1,261 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"log_shelter/internal/model"
+)
+
+// unixMillisThreshold separates Unix seconds from Unix milliseconds: 1e11
+// seconds is year 5138, while 1e11 milliseconds is March 1973.
+const unixMillisThreshold = 100_000_000_000
+
+// TimeExpr is a point in time given as RFC3339, a local "2006-01-02" or
+// "2006-01-02T15:04:05" in the request time zone, Unix seconds or
+// milliseconds, or a relative expression such as "now-15m", "now/d" or
+// "now-1d/d". Relative expressions are resolved when the query runs.
+type TimeExpr string
+
+func (t *TimeExpr) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) != 0 && data[0] == '"' {
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+		*t = TimeExpr(s)
+		return nil
+	}
+	var n json.Number
+	err := json.Unmarshal(data, &n)
+	if err != nil {
+		return fmt.Errorf("time must be a string or a number: %v", err)
+	}
+	*t = TimeExpr(n)
+	return nil
+}
+
+func unix(n int64) time.Time {
+	if n >= unixMillisThreshold || n <= -unixMillisThreshold {
+		return time.UnixMilli(n)
+	}
+	return time.Unix(n, 0)
+}
+
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Resolve returns the point in time relative to now, aligning relative
+// expressions and local timestamps to loc.
+func (t TimeExpr) Resolve(now time.Time, loc *time.Location) (time.Time, error) {
+	s := strings.TrimSpace(string(t))
+
+	if rest, ok := strings.CutPrefix(s, "now"); ok {
+		ret, err := relative(now.In(loc), rest)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: time %q: %v", model.ErrInvalidRequest, s, err)
+		}
+		return ret, nil
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return unix(n), nil
+	}
+	if ret, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return ret, nil
+	}
+	for _, layout := range localLayouts {
+		if ret, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return ret, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: unknown time format %q", model.ErrInvalidRequest, s)
+}
+
+// relative applies a chain of "+<n><unit>", "-<n><unit>" and "/<unit>"
+// operations, the latter rounding down to the start of the unit.
+func relative(t time.Time, ops string) (time.Time, error) {
+	for ops != "" {
+		op := ops[0]
+		ops = ops[1:]
+
+		switch op {
+		case '+', '-':
+			i := 0
+			for i < len(ops) && ops[i] >= '0' && ops[i] <= '9' {
+				i++
+			}
+			if i == 0 {
+				return t, fmt.Errorf("missing amount after %q", op)
+			}
+			n, err := strconv.Atoi(ops[:i])
+			if err != nil {
+				return t, err
+			}
+			if op == '-' {
+				n = -n
+			}
+			unit, rest := cutUnit(ops[i:])
+			t, err = addUnit(t, n, unit)
+			if err != nil {
+				return t, err
+			}
+			ops = rest
+		case '/':
+			unit, rest := cutUnit(ops)
+			var err error
+			t, err = truncateUnit(t, unit)
+			if err != nil {
+				return t, err
+			}
+			ops = rest
+		default:
+			return t, fmt.Errorf("unexpected %q", op)
+		}
+	}
+	return t, nil
+}
+
+func cutUnit(s string) (string, string) {
+	if strings.HasPrefix(s, "ms") {
+		return "ms", s[2:]
+	}
+	if s == "" {
+		return "", ""
+	}
+	return s[:1], s[1:]
+}
+
+func addUnit(t time.Time, n int, unit string) (time.Time, error) {
+	switch unit {
+	case "ms":
+		return t.Add(time.Duration(n) * time.Millisecond), nil
+	case "s":
+		return t.Add(time.Duration(n) * time.Second), nil
+	case "m":
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case "d":
+		return t.AddDate(0, 0, n), nil
+	case "w":
+		return t.AddDate(0, 0, 7*n), nil
+	case "M":
+		return t.AddDate(0, n, 0), nil
+	case "y":
+		return t.AddDate(n, 0, 0), nil
+	default:
+		return t, fmt.Errorf("unknown unit %q", unit)
+	}
+}
+
+// truncateUnit rounds t down in its own location, so "/d" means the local
+// midnight and "/w" the local Monday.
+func truncateUnit(t time.Time, unit string) (time.Time, error) {
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	loc := t.Location()
+
+	switch unit {
+	case "s":
+		return time.Date(y, mo, d, h, mi, s, 0, loc), nil
+	case "m":
+		return time.Date(y, mo, d, h, mi, 0, 0, loc), nil
+	case "h":
+		return time.Date(y, mo, d, h, 0, 0, 0, loc), nil
+	case "d":
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc), nil
+	case "w":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, mo, d-offset, 0, 0, 0, 0, loc), nil
+	case "M":
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc), nil
+	case "y":
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, loc), nil
+	default:
+		return t, fmt.Errorf("unknown unit %q", unit)
+	}
+}
+
+// Duration is a JSON duration given either as a string such as "5m",
+// "1h30m" or "2d", or as a number of nanoseconds.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) != 0 && data[0] == '"' {
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+		x, err := parseDuration(s)
+		if err != nil {
+			return fmt.Errorf("%w: duration %q: %v", model.ErrInvalidRequest, s, err)
+		}
+		*d = Duration(x)
+		return nil
+	}
+	var n int64
+	err := json.Unmarshal(data, &n)
+	if err != nil {
+		return fmt.Errorf("duration must be a string or a number: %v", err)
+	}
+	*d = Duration(n)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// parseDuration extends time.ParseDuration with a leading number of days.
+func parseDuration(s string) (time.Duration, error) {
+	days, rest, ok := strings.Cut(s, "d")
+	if !ok {
+		return time.ParseDuration(s)
+	}
+	n, err := strconv.ParseUint(days, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	ret := time.Duration(n) * 24 * time.Hour
+	if rest == "" {
+		return ret, nil
+	}
+	x, err := time.ParseDuration(rest)
+	return ret + x, err
+}
+
+// location loads the IANA time zone, defaulting to UTC.
+func location(tz *string) (*time.Location, error) {
+	if tz == nil || *tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		return nil, fmt.Errorf("%w: tz: %v", model.ErrInvalidRequest, err)
+	}
+	return loc, nil
+}
+
+// localize renders the timestamps of logs in loc.
+func localize(logs []model.LogModel, loc *time.Location) {
+	for i := range logs {
+		logs[i].CreatedAt = logs[i].CreatedAt.In(loc)
+		if logs[i].DeletedAt != nil {
+			deleted_at := logs[i].DeletedAt.In(loc)
+			logs[i].DeletedAt = &deleted_at
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+type Traceparent struct {
+	TraceID string
+	SpanID  string
+}
+
+func isHexID(s string, size int) bool {
+	if len(s) != size || strings.Trim(s, "0") == "" {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// ParseTraceparent parses a W3C trace context header of the form
+// "version-trace_id-parent_id-flags", e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(header string) (*Traceparent, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return nil, ErrInvalidTraceparent
+	}
+	version, trace_id, span_id, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || version == "ff" || len(flags) != 2 {
+		return nil, ErrInvalidTraceparent
+	}
+	if version == "00" && len(parts) != 4 {
+		return nil, ErrInvalidTraceparent
+	}
+	if !isHexID(trace_id, 32) || !isHexID(span_id, 16) {
+		return nil, ErrInvalidTraceparent
+	}
+	return &Traceparent{TraceID: trace_id, SpanID: span_id}, nil
+}
@@ -1,4 +1,4 @@
-CREATE TABLE logs (
+CREATE TABLE IF NOT EXISTS logs (
     id BIGSERIAL PRIMARY KEY,
     raw_log TEXT NOT NULL,
     log_level VARCHAR(16) NOT NULL,
@@ -0,0 +1 @@
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS attributes JSONB;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS logs_trace_id_idx;
+
+ALTER TABLE logs DROP COLUMN IF EXISTS parent_span_id;
+ALTER TABLE logs DROP COLUMN IF EXISTS span_id;
+ALTER TABLE logs DROP COLUMN IF EXISTS trace_id;
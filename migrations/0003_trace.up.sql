@@ -0,0 +1,5 @@
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS trace_id VARCHAR(32);
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS span_id VARCHAR(16);
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS parent_span_id VARCHAR(16);
+
+CREATE INDEX IF NOT EXISTS logs_trace_id_idx ON logs (trace_id, created_at) WHERE trace_id IS NOT NULL;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL UNIQUE,
+    query JSONB NOT NULL,
+    owner VARCHAR(128),
+    description TEXT,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
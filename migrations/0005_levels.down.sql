@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS logs_level_rank_created_at_idx;
+
+UPDATE logs SET log_level = raw_level WHERE raw_level IS NOT NULL AND length(raw_level) <= 16;
+
+ALTER TABLE logs DROP COLUMN IF EXISTS level_rank;
+ALTER TABLE logs DROP COLUMN IF EXISTS raw_level;
@@ -0,0 +1,66 @@
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS raw_level VARCHAR(32);
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS level_rank SMALLINT;
+
+UPDATE logs SET
+    raw_level = log_level,
+    log_level = CASE upper(trim(log_level))
+        WHEN 'TRC' THEN 'TRACE'
+        WHEN 'DBG' THEN 'DEBUG'
+        WHEN 'VERBOSE' THEN 'DEBUG'
+        WHEN 'INF' THEN 'INFO'
+        WHEN 'INFORMATION' THEN 'INFO'
+        WHEN 'INFORMATIONAL' THEN 'INFO'
+        WHEN 'WRN' THEN 'WARN'
+        WHEN 'WARNING' THEN 'WARN'
+        WHEN 'ERR' THEN 'ERROR'
+        WHEN 'CRIT' THEN 'CRITICAL'
+        WHEN 'PANIC' THEN 'FATAL'
+        WHEN 'EMERG' THEN 'FATAL'
+        WHEN 'EMERGENCY' THEN 'FATAL'
+        WHEN '0' THEN 'FATAL'
+        WHEN '1' THEN 'ALERT'
+        WHEN '2' THEN 'CRITICAL'
+        WHEN '3' THEN 'ERROR'
+        WHEN '4' THEN 'WARN'
+        WHEN '5' THEN 'NOTICE'
+        WHEN '6' THEN 'INFO'
+        WHEN '7' THEN 'DEBUG'
+        ELSE coalesce((
+            -- slog offsets such as ERROR+2 or INFO-4, bucketed the way
+            -- model.ParseLevel does.
+            SELECT CASE
+                WHEN v < -4 THEN 'TRACE'
+                WHEN v < 0 THEN 'DEBUG'
+                WHEN v < 4 THEN 'INFO'
+                WHEN v < 8 THEN 'WARN'
+                WHEN v < 12 THEN 'ERROR'
+                ELSE 'CRITICAL'
+            END
+            FROM (
+                SELECT CASE m[1]
+                    WHEN 'DEBUG' THEN -4
+                    WHEN 'INFO' THEN 0
+                    WHEN 'WARN' THEN 4
+                    ELSE 8
+                END + m[2]::numeric AS v
+                FROM regexp_match(upper(trim(log_level)), '^(DEBUG|INFO|WARN|ERROR)([+-][0-9]+)$') AS m
+            ) AS slog
+            WHERE v IS NOT NULL
+        ), upper(trim(log_level)))
+    END
+WHERE raw_level IS NULL;
+
+UPDATE logs SET level_rank = CASE log_level
+    WHEN 'TRACE' THEN 0
+    WHEN 'DEBUG' THEN 1
+    WHEN 'INFO' THEN 2
+    WHEN 'NOTICE' THEN 3
+    WHEN 'WARN' THEN 4
+    WHEN 'ERROR' THEN 5
+    WHEN 'CRITICAL' THEN 6
+    WHEN 'ALERT' THEN 7
+    WHEN 'FATAL' THEN 8
+END
+WHERE level_rank IS NULL;
+
+CREATE INDEX IF NOT EXISTS logs_level_rank_created_at_idx ON logs (level_rank, created_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS log_restores;
+
+DROP INDEX IF EXISTS logs_deleted_at_idx;
+
+ALTER TABLE logs DROP COLUMN IF EXISTS deleted_at;
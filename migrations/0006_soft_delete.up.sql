@@ -0,0 +1,14 @@
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+UPDATE logs SET deleted_at = now() WHERE is_deleted = true AND deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS logs_deleted_at_idx ON logs (deleted_at) WHERE is_deleted = true;
+
+CREATE TABLE IF NOT EXISTS log_restores (
+    id BIGSERIAL PRIMARY KEY,
+    restored_by VARCHAR(128) NOT NULL,
+    reason TEXT,
+    request JSONB NOT NULL,
+    log_ids BIGINT[] NOT NULL,
+    restored_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
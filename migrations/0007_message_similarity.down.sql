@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS logs_message_fingerprint_idx;
+DROP INDEX IF EXISTS logs_message_trgm_idx;
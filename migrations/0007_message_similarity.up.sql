@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS logs_message_trgm_idx ON logs USING gin ((
+    left(regexp_replace(regexp_replace(regexp_replace(raw_log,
+        '[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}', '<uuid>', 'g'),
+        '\m(0x|)[0-9a-fA-F]{16,}\M', '<hex>', 'g'),
+        '\d+(\.\d+)*', '<num>', 'g'), 256)
+) gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS logs_message_fingerprint_idx ON logs (md5(
+    left(regexp_replace(regexp_replace(regexp_replace(raw_log,
+        '[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}', '<uuid>', 'g'),
+        '\m(0x|)[0-9a-fA-F]{16,}\M', '<hex>', 'g'),
+        '\d+(\.\d+)*', '<num>', 'g'), 256)
+));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS logs_log_level_created_at_idx;
+DROP INDEX IF EXISTS logs_request_id_idx;
+DROP INDEX IF EXISTS logs_source_created_at_idx;
+DROP INDEX IF EXISTS logs_created_at_idx;
@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS logs_created_at_idx ON logs (created_at DESC);
+CREATE INDEX IF NOT EXISTS logs_source_created_at_idx ON logs (source, created_at);
+CREATE INDEX IF NOT EXISTS logs_request_id_idx ON logs (request_id) WHERE request_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS logs_log_level_created_at_idx ON logs (log_level, created_at);
@@ -0,0 +1,24 @@
+ALTER TABLE logs DETACH PARTITION logs_legacy;
+
+ALTER TABLE logs_legacy DROP CONSTRAINT IF EXISTS logs_legacy_created_at_check;
+ALTER TABLE logs_legacy DROP CONSTRAINT IF EXISTS logs_legacy_pkey;
+
+INSERT INTO logs_legacy SELECT * FROM logs;
+
+ALTER SEQUENCE logs_id_seq OWNED BY logs_legacy.id;
+
+DROP TABLE logs;
+
+ALTER TABLE logs_legacy RENAME TO logs;
+
+ALTER TABLE logs ADD PRIMARY KEY (id);
+
+ALTER INDEX IF EXISTS logs_legacy_trace_id_idx RENAME TO logs_trace_id_idx;
+ALTER INDEX IF EXISTS logs_legacy_level_rank_created_at_idx RENAME TO logs_level_rank_created_at_idx;
+ALTER INDEX IF EXISTS logs_legacy_deleted_at_idx RENAME TO logs_deleted_at_idx;
+ALTER INDEX IF EXISTS logs_legacy_message_trgm_idx RENAME TO logs_message_trgm_idx;
+ALTER INDEX IF EXISTS logs_legacy_message_fingerprint_idx RENAME TO logs_message_fingerprint_idx;
+ALTER INDEX IF EXISTS logs_legacy_created_at_idx RENAME TO logs_created_at_idx;
+ALTER INDEX IF EXISTS logs_legacy_source_created_at_idx RENAME TO logs_source_created_at_idx;
+ALTER INDEX IF EXISTS logs_legacy_request_id_idx RENAME TO logs_request_id_idx;
+ALTER INDEX IF EXISTS logs_legacy_log_level_created_at_idx RENAME TO logs_log_level_created_at_idx;
@@ -0,0 +1,66 @@
+-- Turns logs into a table partitioned by created_at. The existing table is
+-- kept as the logs_legacy partition holding everything before the cutover,
+-- so no rows are copied. The server creates the following daily or hourly
+-- partitions, logs_default only catches rows outside of them.
+ALTER TABLE logs RENAME TO logs_legacy;
+
+-- A partition can't keep its own primary key, ATTACH creates the
+-- (id, created_at) one of the parent instead.
+ALTER TABLE logs_legacy DROP CONSTRAINT logs_pkey;
+
+ALTER INDEX IF EXISTS logs_trace_id_idx RENAME TO logs_legacy_trace_id_idx;
+ALTER INDEX IF EXISTS logs_level_rank_created_at_idx RENAME TO logs_legacy_level_rank_created_at_idx;
+ALTER INDEX IF EXISTS logs_deleted_at_idx RENAME TO logs_legacy_deleted_at_idx;
+ALTER INDEX IF EXISTS logs_message_trgm_idx RENAME TO logs_legacy_message_trgm_idx;
+ALTER INDEX IF EXISTS logs_message_fingerprint_idx RENAME TO logs_legacy_message_fingerprint_idx;
+ALTER INDEX IF EXISTS logs_created_at_idx RENAME TO logs_legacy_created_at_idx;
+ALTER INDEX IF EXISTS logs_source_created_at_idx RENAME TO logs_legacy_source_created_at_idx;
+ALTER INDEX IF EXISTS logs_request_id_idx RENAME TO logs_legacy_request_id_idx;
+ALTER INDEX IF EXISTS logs_log_level_created_at_idx RENAME TO logs_legacy_log_level_created_at_idx;
+
+CREATE TABLE logs (LIKE logs_legacy INCLUDING DEFAULTS INCLUDING CONSTRAINTS)
+    PARTITION BY RANGE (created_at);
+
+ALTER SEQUENCE logs_id_seq OWNED BY logs.id;
+
+ALTER TABLE logs ADD PRIMARY KEY (id, created_at);
+
+CREATE INDEX logs_trace_id_idx ON logs (trace_id, created_at) WHERE trace_id IS NOT NULL;
+CREATE INDEX logs_level_rank_created_at_idx ON logs (level_rank, created_at);
+CREATE INDEX logs_deleted_at_idx ON logs (deleted_at) WHERE is_deleted = true;
+CREATE INDEX logs_message_trgm_idx ON logs USING gin ((
+    left(regexp_replace(regexp_replace(regexp_replace(raw_log,
+        '[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}', '<uuid>', 'g'),
+        '\m(0x|)[0-9a-fA-F]{16,}\M', '<hex>', 'g'),
+        '\d+(\.\d+)*', '<num>', 'g'), 256)
+) gin_trgm_ops);
+CREATE INDEX logs_message_fingerprint_idx ON logs (md5(
+    left(regexp_replace(regexp_replace(regexp_replace(raw_log,
+        '[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}', '<uuid>', 'g'),
+        '\m(0x|)[0-9a-fA-F]{16,}\M', '<hex>', 'g'),
+        '\d+(\.\d+)*', '<num>', 'g'), 256)
+));
+CREATE INDEX logs_created_at_idx ON logs (created_at DESC);
+CREATE INDEX logs_source_created_at_idx ON logs (source, created_at);
+CREATE INDEX logs_request_id_idx ON logs (request_id) WHERE request_id IS NOT NULL;
+CREATE INDEX logs_log_level_created_at_idx ON logs (log_level, created_at);
+
+DO $$
+DECLARE
+    cutover TIMESTAMPTZ := date_trunc('day', now() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' + INTERVAL '1 day';
+BEGIN
+    -- The constraint lets ATTACH skip its own validation scan.
+    EXECUTE format(
+        'ALTER TABLE logs_legacy ADD CONSTRAINT logs_legacy_created_at_check CHECK (created_at < %L)',
+        cutover);
+    EXECUTE format(
+        'ALTER TABLE logs ATTACH PARTITION logs_legacy FOR VALUES FROM (MINVALUE) TO (%L)',
+        cutover);
+
+    IF EXISTS (SELECT 1 FROM pg_publication WHERE pubname = 'dbz_publication') THEN
+        ALTER PUBLICATION dbz_publication SET (publish_via_partition_root = true);
+    END IF;
+END
+$$;
+
+CREATE TABLE logs_default PARTITION OF logs DEFAULT;
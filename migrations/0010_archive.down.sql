@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS logs_rehydrated;
+DROP TABLE IF EXISTS archive_segments;
@@ -0,0 +1,33 @@
+-- Manifest of the segments retention and purge archived logs into before
+-- deleting them.
+CREATE TABLE IF NOT EXISTS archive_segments (
+    id BIGSERIAL PRIMARY KEY,
+    key TEXT NOT NULL UNIQUE,
+    source VARCHAR(128) NOT NULL,
+    day DATE NOT NULL,
+    format VARCHAR(16) NOT NULL,
+    row_count BIGINT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    first_id BIGINT NOT NULL,
+    last_id BIGINT NOT NULL,
+    first_created_at TIMESTAMPTZ NOT NULL,
+    last_created_at TIMESTAMPTZ NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS archive_segments_source_day_idx ON archive_segments (source, day);
+CREATE INDEX IF NOT EXISTS archive_segments_day_idx ON archive_segments (day);
+
+-- Rehydrated logs are kept apart from logs, so retention doesn't expire
+-- them again right away.
+CREATE TABLE IF NOT EXISTS logs_rehydrated (
+    LIKE logs,
+    segment_id BIGINT NOT NULL REFERENCES archive_segments (id) ON DELETE CASCADE,
+    rehydrated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS logs_rehydrated_source_created_at_idx
+    ON logs_rehydrated (source, created_at);
+CREATE INDEX IF NOT EXISTS logs_rehydrated_segment_id_idx ON logs_rehydrated (segment_id);
@@ -0,0 +1,21 @@
+-- Legal holds keep the logs they match from retention, purge and partition
+-- drops until they expire or are released. Every set selector has to
+-- match, a hold without any would hold everything.
+CREATE TABLE IF NOT EXISTS legal_holds (
+    id BIGSERIAL PRIMARY KEY,
+    reason TEXT NOT NULL,
+    created_by VARCHAR(128) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ,
+    released_by VARCHAR(128),
+    released_at TIMESTAMPTZ,
+    source VARCHAR(128),
+    request_id VARCHAR(64),
+    from_time TIMESTAMPTZ,
+    to_time TIMESTAMPTZ,
+    CHECK (source IS NOT NULL OR request_id IS NOT NULL
+        OR from_time IS NOT NULL OR to_time IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS legal_holds_unreleased_idx ON legal_holds (expires_at)
+    WHERE released_at IS NULL;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS chain_checkpoints;
+DROP TABLE IF EXISTS chain_heads;
+DROP INDEX IF EXISTS logs_source_chain_seq_idx;
+ALTER TABLE logs DROP COLUMN IF EXISTS chain_hash;
+ALTER TABLE logs DROP COLUMN IF EXISTS chain_seq;
@@ -0,0 +1,26 @@
+-- Logs of chained sources carry their link in the hash chain of the
+-- source, chain_heads holds the newest link of every chain and serializes
+-- appends to it.
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS chain_seq BIGINT;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS chain_hash BYTEA;
+
+CREATE INDEX IF NOT EXISTS logs_source_chain_seq_idx ON logs (source, chain_seq)
+    WHERE chain_seq IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS chain_heads (
+    source VARCHAR(128) PRIMARY KEY,
+    seq BIGINT NOT NULL,
+    hash BYTEA NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS chain_checkpoints (
+    id BIGSERIAL PRIMARY KEY,
+    source VARCHAR(128) NOT NULL,
+    seq BIGINT NOT NULL,
+    hash BYTEA NOT NULL,
+    key_id VARCHAR(32) NOT NULL,
+    signature BYTEA NOT NULL,
+    signed_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (source, seq)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS logs_data_key_id_idx;
+ALTER TABLE logs DROP COLUMN IF EXISTS attributes_enc;
+ALTER TABLE logs DROP COLUMN IF EXISTS raw_log_enc;
+ALTER TABLE logs DROP COLUMN IF EXISTS data_key_id;
+DROP TABLE IF EXISTS data_keys;
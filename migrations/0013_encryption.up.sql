@@ -0,0 +1,23 @@
+-- Data keys encrypt the logs of encrypted sources and are stored wrapped
+-- by the master key. A source has at most one active key; retired keys
+-- are dropped once no log uses them anymore.
+CREATE TABLE IF NOT EXISTS data_keys (
+    id BIGSERIAL PRIMARY KEY,
+    source VARCHAR(128) NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+    master_key_id VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    retired_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS data_keys_active_source_idx ON data_keys (source)
+    WHERE retired_at IS NULL;
+
+-- Encrypted logs keep raw_log empty and attributes NULL, so they stay out
+-- of the search indexes.
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS data_key_id BIGINT REFERENCES data_keys (id);
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS raw_log_enc BYTEA;
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS attributes_enc BYTEA;
+
+CREATE INDEX IF NOT EXISTS logs_data_key_id_idx ON logs (data_key_id)
+    WHERE data_key_id IS NOT NULL;
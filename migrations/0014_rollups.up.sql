@@ -0,0 +1,31 @@
+-- Rollups count the logs appended per minute, source, level and logger,
+-- and are left alone by retention. Old minutes are downsampled into hours
+-- and days, bucketed in UTC; logger_name is '' for logs without one.
+CREATE TABLE IF NOT EXISTS log_rollups (
+    resolution VARCHAR(8) NOT NULL,
+    bucket TIMESTAMPTZ NOT NULL,
+    source VARCHAR(128) NOT NULL,
+    log_level VARCHAR(16) NOT NULL,
+    level_rank SMALLINT,
+    logger_name VARCHAR(128) NOT NULL,
+    count BIGINT NOT NULL,
+    bytes BIGINT NOT NULL,
+    PRIMARY KEY (resolution, bucket, source, log_level, logger_name)
+);
+
+CREATE INDEX IF NOT EXISTS log_rollups_bucket_idx ON log_rollups (bucket);
+
+-- Backfill from the logs still around, unless rollups were kept already.
+INSERT INTO log_rollups (resolution, bucket, source, log_level, level_rank, logger_name, count, bytes)
+SELECT
+    'minute',
+    date_trunc('minute', created_at),
+    source,
+    log_level,
+    max(level_rank),
+    coalesce(logger_name, ''),
+    count(*),
+    coalesce(sum(octet_length(raw_log)), 0)
+FROM logs
+WHERE NOT EXISTS (SELECT 1 FROM log_rollups)
+GROUP BY 2, 3, 4, 6;
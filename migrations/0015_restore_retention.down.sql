@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS logs_retain_until_idx;
+
+ALTER TABLE logs DROP COLUMN IF EXISTS retain_until;
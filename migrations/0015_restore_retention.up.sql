@@ -0,0 +1,5 @@
+-- Restored logs keep retention away until retain_until, otherwise the next
+-- retention cycle would delete them again as they are still past max_age.
+ALTER TABLE logs ADD COLUMN IF NOT EXISTS retain_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS logs_retain_until_idx ON logs (retain_until) WHERE retain_until IS NOT NULL;
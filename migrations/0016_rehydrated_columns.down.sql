@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS logs_rehydrated_request_id_idx;
+DROP INDEX IF EXISTS logs_rehydrated_created_at_idx;
+
+ALTER TABLE logs_rehydrated DROP COLUMN IF EXISTS attributes_enc;
+ALTER TABLE logs_rehydrated DROP COLUMN IF EXISTS raw_log_enc;
+ALTER TABLE logs_rehydrated DROP COLUMN IF EXISTS data_key_id;
+ALTER TABLE logs_rehydrated DROP COLUMN IF EXISTS chain_hash;
+ALTER TABLE logs_rehydrated DROP COLUMN IF EXISTS chain_seq;
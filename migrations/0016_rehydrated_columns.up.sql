@@ -0,0 +1,12 @@
+-- logs_rehydrated was created LIKE logs before the hash chain and
+-- encryption columns were added to logs. Rehydrated logs are searched with
+-- the same columns as live ones.
+ALTER TABLE logs_rehydrated ADD COLUMN IF NOT EXISTS chain_seq BIGINT;
+ALTER TABLE logs_rehydrated ADD COLUMN IF NOT EXISTS chain_hash BYTEA;
+ALTER TABLE logs_rehydrated ADD COLUMN IF NOT EXISTS data_key_id BIGINT;
+ALTER TABLE logs_rehydrated ADD COLUMN IF NOT EXISTS raw_log_enc BYTEA;
+ALTER TABLE logs_rehydrated ADD COLUMN IF NOT EXISTS attributes_enc BYTEA;
+
+CREATE INDEX IF NOT EXISTS logs_rehydrated_created_at_idx ON logs_rehydrated (created_at);
+CREATE INDEX IF NOT EXISTS logs_rehydrated_request_id_idx ON logs_rehydrated (request_id)
+    WHERE request_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS chain_tombstones_source_to_seq_idx;
+DROP TABLE IF EXISTS chain_tombstones;
@@ -0,0 +1,16 @@
+-- Retention, purges and partition drops delete chained logs. Every run of
+-- deleted links leaves a tombstone holding the hash of its last link, which
+-- the link after the run builds on, so verify can skip over it. Tombstones
+-- are signed with the checkpoint key, verify doesn't trust unsigned ones.
+CREATE TABLE IF NOT EXISTS chain_tombstones (
+    id BIGSERIAL PRIMARY KEY,
+    source VARCHAR(128) NOT NULL,
+    from_seq BIGINT NOT NULL,
+    to_seq BIGINT NOT NULL,
+    hash BYTEA NOT NULL,
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    key_id VARCHAR(32),
+    signature BYTEA
+);
+
+CREATE INDEX IF NOT EXISTS chain_tombstones_source_to_seq_idx ON chain_tombstones (source, to_seq);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS logs_rehydrated_data_key_id_idx;
+DROP INDEX IF EXISTS archive_segments_data_key_ids_idx;
+ALTER TABLE archive_segments DROP COLUMN IF EXISTS data_key_ids;
@@ -0,0 +1,7 @@
+-- Encrypted logs are archived sealed. Segments list the data keys their
+-- logs are encrypted with, which the key rotation keeps around.
+ALTER TABLE archive_segments ADD COLUMN IF NOT EXISTS data_key_ids BIGINT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS archive_segments_data_key_ids_idx ON archive_segments USING gin (data_key_ids);
+CREATE INDEX IF NOT EXISTS logs_rehydrated_data_key_id_idx ON logs_rehydrated (data_key_id)
+    WHERE data_key_id IS NOT NULL;
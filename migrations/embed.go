@@ -0,0 +1,10 @@
+// Package migrations embeds the versioned schema migrations. Every version
+// is a pair of NNNN_name.up.sql and NNNN_name.down.sql files. Up migrations
+// are idempotent, so databases created before the runner existed can be
+// adopted by running them once.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
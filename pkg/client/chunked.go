@@ -0,0 +1,127 @@
+// Package client holds helpers for talking to log_shelter over NATS.
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const defaultIdleTimeout = 30 * time.Second
+
+var ErrBrokenStream = errors.New("broken chunked stream")
+
+// RemoteError is an error reported by the server at the end of a stream.
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+type ChunkOptions struct {
+	// ChunkSize asks the server for chunks of at most this many bytes. The
+	// server caps it by the NATS max payload either way.
+	ChunkSize int
+	// IdleTimeout bounds the wait for every single message of the stream.
+	IdleTimeout time.Duration
+}
+
+// RequestChunked sends a request asking for a chunked reply and returns the
+// reassembled body.
+func RequestChunked(
+	ctx context.Context,
+	nc *nats.Conn,
+	subject string,
+	data []byte,
+	opts *ChunkOptions,
+) ([]byte, error) {
+	var buf bytes.Buffer
+	err := StreamChunked(ctx, nc, subject, data, &buf, opts)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// StreamChunked sends a request asking for a chunked reply and writes the
+// chunks to w in order as they arrive. Replies of servers that don't speak
+// the protocol are written as a single chunk.
+func StreamChunked(
+	ctx context.Context,
+	nc *nats.Conn,
+	subject string,
+	data []byte,
+	w io.Writer,
+	opts *ChunkOptions,
+) error {
+	idle := defaultIdleTimeout
+	req := nats.NewMsg(subject)
+	req.Data = data
+	req.Header.Set(HeaderChunked, "true")
+	if opts != nil {
+		if opts.ChunkSize > 0 {
+			req.Header.Set(HeaderChunkSize, strconv.Itoa(opts.ChunkSize))
+		}
+		if opts.IdleTimeout > 0 {
+			idle = opts.IdleTimeout
+		}
+	}
+
+	req.Reply = nc.NewInbox()
+	sub, err := nc.SubscribeSync(req.Reply)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	err = sub.SetPendingLimits(-1, -1)
+	if err != nil {
+		return err
+	}
+
+	err = nc.PublishMsg(req)
+	if err != nil {
+		return err
+	}
+
+	for seq := 0; ; seq++ {
+		msg_ctx, cancel := context.WithTimeout(ctx, idle)
+		msg, err := sub.NextMsgWithContext(msg_ctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		if msg.Header.Get(HeaderEOS) != "" {
+			if text := msg.Header.Get(HeaderError); text != "" {
+				return &RemoteError{Message: text}
+			}
+			chunks, err := strconv.Atoi(msg.Header.Get(HeaderChunks))
+			if err != nil || chunks != seq {
+				return fmt.Errorf("%w: got %d of %s chunks",
+					ErrBrokenStream, seq, msg.Header.Get(HeaderChunks))
+			}
+			return nil
+		}
+
+		raw_seq := msg.Header.Get(HeaderSeq)
+		if raw_seq == "" && seq == 0 {
+			_, err = w.Write(msg.Data)
+			return err
+		}
+		if raw_seq != strconv.Itoa(seq) {
+			return fmt.Errorf("%w: expected chunk %d, got %q", ErrBrokenStream, seq, raw_seq)
+		}
+		_, err = w.Write(msg.Data)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package client
+
+// Headers of the chunked reply protocol. A requester opts in by setting
+// HeaderChunked on the request. Every data chunk of the reply then carries
+// its 0-based HeaderSeq, and the stream ends with an empty message marked
+// with HeaderEOS and the number of data chunks in HeaderChunks. A failure
+// is reported by HeaderError on that final message.
+const (
+	HeaderChunked   = "Log-Shelter-Chunked"
+	HeaderChunkSize = "Log-Shelter-Chunk-Size"
+	HeaderSeq       = "Log-Shelter-Seq"
+	HeaderEOS       = "Log-Shelter-Eos"
+	HeaderChunks    = "Log-Shelter-Chunks"
+	HeaderError     = "Log-Shelter-Error"
+)
+
+// HeaderDecryptToken carries one of the decrypt tokens of the server, on
+// NATS requests and HTTP ones alike. Encrypted logs are only returned in
+// clear to requests holding it.
+const HeaderDecryptToken = "Log-Shelter-Decrypt-Token"